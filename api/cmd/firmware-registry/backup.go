@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"firmware-registry-api/internal/backup"
+	"firmware-registry-api/internal/firmware"
+	"firmware-registry-api/internal/webhook"
+
+	"github.com/rs/zerolog/log"
+)
+
+// runExport implements `firmware-registry export [-o file] [-gzip=false]`.
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	out := fs.String("o", "", "output file (default firmware-registry-<timestamp>.tar.gz)")
+	compress := fs.Bool("gzip", true, "gzip-compress the archive")
+	_ = fs.Parse(args)
+
+	name := *out
+	if name == "" {
+		name = "firmware-registry-" + time.Now().UTC().Format("20060102-150405") + ".tar"
+		if *compress {
+			name += ".gz"
+		}
+	}
+
+	cfg, database := bootstrap()
+	defer func() {
+		_ = database.Close()
+	}()
+
+	f, err := os.Create(name)
+	if err != nil {
+		log.Fatal().Err(err).Str("file", name).Msg("Failed to create archive file")
+	}
+	if err := newArchiver(cfg.StorageDir, database).Export(context.Background(), f, *compress); err != nil {
+		_ = f.Close()
+		_ = os.Remove(name)
+		log.Fatal().Err(err).Msg("Registry export failed")
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal().Err(err).Str("file", name).Msg("Failed to write archive file")
+	}
+	fmt.Println(name)
+}
+
+// runImport implements `firmware-registry import [-conflict skip|overwrite|fail] <archive|->`.
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	conflict := fs.String("conflict", "skip", "how to handle releases that exist with a different binary: skip, overwrite or fail")
+	_ = fs.Parse(args)
+
+	policy, ok := backup.ParseConflictPolicy(*conflict)
+	if !ok || fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: firmware-registry import [-conflict skip|overwrite|fail] <archive|->")
+		os.Exit(2)
+	}
+
+	cfg, database := bootstrap()
+	defer func() {
+		_ = database.Close()
+	}()
+
+	var in io.Reader = os.Stdin
+	if name := fs.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			log.Fatal().Err(err).Str("file", name).Msg("Failed to open archive")
+		}
+		defer func(f *os.File) {
+			_ = f.Close()
+		}(f)
+		in = f
+	}
+
+	report, err := newArchiver(cfg.StorageDir, database).Import(context.Background(), in, policy)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Registry import failed")
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(report)
+}
+
+func newArchiver(storageDir string, database *sql.DB) *backup.Archiver {
+	return &backup.Archiver{
+		DB:            database,
+		Storage:       firmware.Storage{BaseDir: storageDir},
+		Firmware:      &firmware.SQLiteRepo{DB: database},
+		Webhooks:      &webhook.SQLiteRepo{DB: database},
+		MigrationsDir: "./migrations",
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+
+	"firmware-registry-api/internal/firmware"
+
+	"github.com/rs/zerolog/log"
+)
+
+// runFsck implements `firmware-registry fsck [-repair]`.
+// The report is printed as JSON to stdout; the exit code is 1 when
+// unrepaired issues remain.
+func runFsck(args []string) {
+	fs := flag.NewFlagSet("fsck", flag.ExitOnError)
+	repair := fs.Bool("repair", false, "delete dangling rows and remove orphaned and temporary files")
+	_ = fs.Parse(args)
+
+	cfg, database := bootstrap()
+	defer func() {
+		_ = database.Close()
+	}()
+
+	svc := &firmware.Service{
+		Repo:    &firmware.SQLiteRepo{DB: database},
+		Storage: firmware.Storage{BaseDir: cfg.StorageDir},
+	}
+
+	report, err := svc.Fsck(context.Background(), *repair)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Integrity check failed")
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(report)
+
+	if report.Unrepaired() > 0 {
+		_ = database.Close()
+		os.Exit(1)
+	}
+}
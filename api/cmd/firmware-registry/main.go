@@ -2,19 +2,32 @@ package main
 
 import (
 	"context"
-	"net"
+	"database/sql"
+	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
 	"firmware-registry-api/internal/api"
 	"firmware-registry-api/internal/api/handlers"
+	"firmware-registry-api/internal/apikey"
+	"firmware-registry-api/internal/audit"
 	"firmware-registry-api/internal/auth"
+	"firmware-registry-api/internal/clientip"
 	"firmware-registry-api/internal/config"
 	"firmware-registry-api/internal/db"
 	"firmware-registry-api/internal/firmware"
 	"firmware-registry-api/internal/logging"
+	"firmware-registry-api/internal/metrics"
+	"firmware-registry-api/internal/presign"
+	"firmware-registry-api/internal/ratelimit"
+	"firmware-registry-api/internal/stats"
+	"firmware-registry-api/internal/tlsconfig"
+	"firmware-registry-api/internal/tracing"
 	"firmware-registry-api/internal/webhook"
 
 	"github.com/rs/zerolog/log"
@@ -47,12 +60,42 @@ import (
 // @name X-Device-Key
 // @description Device API key for firmware download operations
 
+// @securityDefinitions.apikey ManagedKeyAuth
+// @in header
+// @name X-API-Key
+// @description Managed API key (fwk_...) created via /keys; access depends on its scopes
+
 // @securityDefinitions.apikey BearerAuth
 // @in header
 // @name Authorization
 // @description JWT Bearer token from OIDC provider (format: "Bearer {token}")
 
 func main() {
+	cmd, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+
+	switch cmd {
+	case "serve":
+		serve()
+	case "fsck":
+		runFsck(args)
+	case "export":
+		runExport(args)
+	case "import":
+		runImport(args)
+	case "validate-config":
+		runValidateConfig(args)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q (available: serve, fsck, export, import, validate-config)\n", cmd)
+		os.Exit(2)
+	}
+}
+
+// bootstrap loads config, initializes logging and opens the migrated database.
+// Shared by the server and all maintenance subcommands.
+func bootstrap() (config.Config, *sql.DB) {
 	cfgPath := os.Getenv("FW_CONFIG_FILE")
 	cfg, err := config.Load(cfgPath)
 	if err != nil {
@@ -64,10 +107,17 @@ func main() {
 		log.Fatal().Err(err).Msg("Logger setup failed")
 	}
 
-	log.Info().
-		Str("version", "1.0.0").
-		Str("listen_addr", cfg.ListenAddr).
-		Msg("Firmware Registry API starting")
+	problems := cfg.Validate()
+	for _, p := range problems {
+		event := log.Warn()
+		if p.Severity == config.SeverityError {
+			event = log.Error()
+		}
+		event.Str("field", p.Field).Msg("Config " + string(p.Severity) + ": " + p.Message)
+	}
+	if errs := problems.Errors(); len(errs) > 0 && cfg.Strict {
+		log.Fatal().Int("errors", len(errs)).Msg("Invalid configuration (strict mode), run validate-config for details")
+	}
 
 	// Ensure directories exist
 	if err := os.MkdirAll(cfg.StorageDir, 0o755); err != nil {
@@ -82,6 +132,30 @@ func main() {
 	database := db.OpenSQLite(cfg.DBPath)
 	log.Info().Msg("Running database migrations")
 	db.RunMigrations(cfg.DBPath, "./migrations")
+	if err := (&firmware.SQLiteRepo{DB: database}).BackfillVersionKeys(); err != nil {
+		log.Fatal().Err(err).Msg("Failed to backfill firmware version sort keys")
+	}
+
+	return cfg, database
+}
+
+func serve() {
+	cfg, database := bootstrap()
+	loaded := cfg
+
+	log.Info().
+		Str("version", "1.0.0").
+		Str("listen_addr", cfg.ListenAddr).
+		Msg("Firmware Registry API starting")
+
+	// Cancelled on SIGTERM/SIGINT; background workers stop with it.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Tracing setup failed")
+	}
 
 	// Firmware layer
 	fwRepo := &firmware.SQLiteRepo{DB: database}
@@ -94,126 +168,304 @@ func main() {
 	// Webhook layer
 	whRepo := &webhook.SQLiteRepo{DB: database}
 	whSvc := &webhook.Service{
-		Repo:       whRepo,
-		Secret:     cfg.Webhooks.Secret,
-		TimeoutSec: cfg.Webhooks.TimeoutSec,
-		Retries:    cfg.Webhooks.Retries,
+		Repo:      whRepo,
+		Settings:  webhookSettings(cfg),
+		Workers:   cfg.Webhooks.Workers,
+		Retention: time.Duration(cfg.Webhooks.RetentionDays) * 24 * time.Hour,
 	}
+	whSvc.Start()
 
-	// Initialize OIDC verifier if enabled
+	// Initialize OIDC verifier if enabled. Providers that cannot be reached
+	// now are retried in the background; cached keys are used meanwhile.
 	var oidcVerifier *auth.OIDCVerifier
 	if cfg.OIDC.Enabled {
-		log.Info().Str("issuer", cfg.OIDC.IssuerURL).Msg("Initializing OIDC authentication")
-		ctx := context.Background()
-		var err error
-		oidcVerifier, err = auth.NewOIDCVerifier(
-			ctx,
-			cfg.OIDC.IssuerURL,
-			cfg.OIDC.ClientID,
-			cfg.OIDC.Audience,
-			cfg.OIDC.AdminRole,
-			cfg.OIDC.DeviceRole,
-		)
-		if err != nil {
-			log.Warn().
+		providers := oidcProviders(cfg)
+		log.Info().Int("providers", len(providers)).Msg("Initializing OIDC authentication")
+		oidcVerifier = auth.NewOIDCVerifier(providers, cfg.JWKSCacheDirOrDefault(),
+			time.Duration(cfg.OIDC.JWKSCacheSec)*time.Second)
+		if err := oidcVerifier.Discover(ctx); err != nil && cfg.Strict {
+			log.Fatal().Err(err).Msg("OIDC enabled but failed to initialize (strict mode)")
+		} else if err != nil {
+			log.Error().
 				Err(err).
-				Msg("OIDC enabled but failed to initialize, falling back to API key authentication only")
-			cfg.OIDC.Enabled = false
-		} else {
+				Msg("OIDC provider unavailable, retrying in the background; its tokens are only accepted with cached keys")
+		}
+		for _, p := range providers {
 			log.Info().
-				Str("issuer", cfg.OIDC.IssuerURL).
-				Str("client_id", cfg.OIDC.ClientID).
-				Str("admin_role", cfg.OIDC.AdminRole).
-				Str("device_role", cfg.OIDC.DeviceRole).
+				Str("issuer", p.IssuerURL).
+				Strs("client_ids", p.ClientIDs).
+				Strs("audiences", p.Audiences).
+				Strs("role_claims", p.RoleClaims).
+				Str("admin_role", p.AdminRole).
+				Str("device_role", p.DeviceRole).
+				Str("jwks", p.JWKS).
 				Msg("OIDC authentication enabled")
 		}
+		go oidcVerifier.Run(ctx)
 	}
 
-	// Parse comma-separated NoAuthIPs (supports both individual IPs and CIDR subnets)
-	var noAuthIPs []net.IP
-	var noAuthSubnets []*net.IPNet
-	if cfg.NoAuthIPs != "" {
-		for _, entry := range strings.Split(cfg.NoAuthIPs, ",") {
-			entry = strings.TrimSpace(entry)
-			if entry == "" {
-				continue
-			}
-
-			// Check if it's a CIDR subnet (contains "/")
-			if strings.Contains(entry, "/") {
-				_, subnet, err := net.ParseCIDR(entry)
-				if err != nil {
-					log.Warn().
-						Str("entry", entry).
-						Err(err).
-						Msg("Invalid CIDR subnet in FW_NOAUTH_IPS, skipping")
-					continue
-				}
-				noAuthSubnets = append(noAuthSubnets, subnet)
-			} else {
-				// Parse as individual IP
-				ip := net.ParseIP(entry)
-				if ip == nil {
-					log.Warn().
-						Str("entry", entry).
-						Msg("Invalid IP address in FW_NOAUTH_IPS, skipping")
-					continue
-				}
-				noAuthIPs = append(noAuthIPs, ip)
-			}
-		}
+	var introspector *auth.Introspector
+	if cfg.Introspection.URL != "" {
+		introspector = auth.NewIntrospector(introspectionConfig(cfg))
+		log.Info().
+			Str("url", cfg.Introspection.URL).
+			Str("client_id", cfg.Introspection.ClientID).
+			Str("admin_role", cfg.Introspection.AdminRole).
+			Str("device_role", cfg.Introspection.DeviceRole).
+			Msg("Token introspection enabled")
+	}
 
-		if len(noAuthIPs) > 0 || len(noAuthSubnets) > 0 {
-			logEvent := log.Info()
-			if len(noAuthIPs) > 0 {
-				ipStrs := make([]string, len(noAuthIPs))
-				for i, ip := range noAuthIPs {
-					ipStrs[i] = ip.String()
-				}
-				logEvent = logEvent.Strs("ips", ipStrs)
-			}
-			if len(noAuthSubnets) > 0 {
-				subnetStrs := make([]string, len(noAuthSubnets))
-				for i, subnet := range noAuthSubnets {
-					subnetStrs[i] = subnet.String()
-				}
-				logEvent = logEvent.Strs("subnets", subnetStrs)
-			}
-			logEvent.Msg("No-auth IP/subnet whitelist configured")
-		}
+	initialAuth, err := authSettings(cfg, false)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid network restrictions")
 	}
+	authStore := auth.NewStore(initialAuth)
+	initialProxies, _ := clientIPSettings(cfg, false)
+	resolver := clientip.NewResolver(initialProxies)
+
+	auditRepo := &audit.SQLiteRepo{DB: database}
+	auditLog := &audit.Log{Repo: auditRepo}
+	keySvc := &apikey.Service{Repo: &apikey.SQLiteRepo{DB: database}}
 
 	authHandler := auth.Auth{
-		AdminKey:      cfg.AdminKey,
-		DeviceKey:     cfg.DeviceKey,
-		NoAuthIPs:     noAuthIPs,
-		NoAuthSubnets: noAuthSubnets,
-		OIDCEnabled:   cfg.OIDC.Enabled,
-		OIDCVerifier:  oidcVerifier,
+		Settings:     authStore,
+		OIDCEnabled:  cfg.OIDC.Enabled,
+		OIDCVerifier: oidcVerifier,
+		Introspector: introspector,
+		Audit:        auditLog,
+		Keys:         keySvc,
+	}
+	if cfg.RateLimit.Enabled {
+		authHandler.Limiter = ratelimit.NewLimiter(map[ratelimit.Class]ratelimit.Limit{
+			ratelimit.ClassRead:     ratelimit.Limit(cfg.RateLimit.Read),
+			ratelimit.ClassDownload: ratelimit.Limit(cfg.RateLimit.Download),
+			ratelimit.ClassWrite:    ratelimit.Limit(cfg.RateLimit.Write),
+		})
+		log.Info().
+			Interface("read", cfg.RateLimit.Read).
+			Interface("download", cfg.RateLimit.Download).
+			Interface("write", cfg.RateLimit.Write).
+			Msg("Rate limiting enabled")
+	}
+	if cfg.Ban.Failures > 0 {
+		authHandler.Bans = ratelimit.NewBans(cfg.Ban.Failures,
+			time.Duration(cfg.Ban.WindowSec)*time.Second, time.Duration(cfg.Ban.DurationSec)*time.Second)
+		log.Info().
+			Int("failures", cfg.Ban.Failures).
+			Int("window_sec", cfg.Ban.WindowSec).
+			Int("duration_sec", cfg.Ban.DurationSec).
+			Msg("Bans after repeated authentication failures enabled")
+	}
+	if cfg.Audit.FailuresPerMin > 0 {
+		authHandler.AuditLimiter = ratelimit.NewLimiter(map[ratelimit.Class]ratelimit.Limit{
+			ratelimit.ClassAudit: {PerMin: cfg.Audit.FailuresPerMin, Burst: cfg.Audit.FailuresPerMin},
+		})
+	}
+	if cfg.Audit.RetentionDays > 0 {
+		go auditLog.RunRetention(ctx, time.Duration(cfg.Audit.RetentionDays)*24*time.Hour)
+	}
+
+	// Download statistics
+	statsRepo := &stats.SQLiteRepo{DB: database}
+	var statsRecorder *stats.Recorder
+	if cfg.Stats.Enabled {
+		statsRecorder = stats.NewRecorder(
+			statsRepo,
+			cfg.Stats.BatchSize,
+			time.Duration(cfg.Stats.FlushInterval)*time.Second,
+		)
 	}
 
 	fwHandler := &handlers.FirmwareHandler{
 		Auth:     authHandler,
 		Service:  fwSvc,
 		Webhooks: whSvc,
+		Stats:    statsRecorder,
+		Audit:    auditLog,
 		MaxBytes: cfg.MaxUploadMB * 1024 * 1024,
 	}
+	if cfg.Presign.Secret != "" {
+		fwHandler.Presign = &presign.Service{
+			Secret:     []byte(cfg.Presign.Secret),
+			Repo:       &presign.SQLiteRepo{DB: database},
+			DefaultTTL: time.Duration(cfg.Presign.DefaultTTLSec) * time.Second,
+			MaxTTL:     time.Duration(cfg.Presign.MaxTTLSec) * time.Second,
+			InListings: cfg.Presign.DownloadURLs,
+		}
+		log.Info().
+			Int("default_ttl_sec", cfg.Presign.DefaultTTLSec).
+			Int("max_ttl_sec", cfg.Presign.MaxTTLSec).
+			Bool("download_urls", cfg.Presign.DownloadURLs).
+			Msg("Pre-signed download URLs enabled")
+	}
 	whHandler := &handlers.WebhookHandler{
+		Auth:       authHandler,
+		Repo:       whRepo,
+		Service:    whSvc,
+		Audit:      auditLog,
+		PublicBase: cfg.PublicBaseURL,
+	}
+	// Keys, allowlist, trusted proxies, webhook settings and log level follow
+	// SIGHUP and changes to FW_CONFIG_FILE
+	reloader := config.NewReloader(os.Getenv("FW_CONFIG_FILE"),
+		time.Duration(cfg.Reload.WatchSec)*time.Second, loaded, applyConfig(authStore, resolver, whSvc))
+	go reloader.Run(ctx)
+
+	adminHandler := &handlers.AdminHandler{
+		Auth:     authHandler,
+		Service:  fwSvc,
+		Webhooks: whSvc,
+		Backup:   newArchiver(cfg.StorageDir, database),
+		Audit:    auditLog,
+		Config:   reloader,
+	}
+
+	if cfg.Scrub.IntervalMin > 0 {
+		scrubber := &firmware.Scrubber{
+			Service:  fwSvc,
+			Interval: time.Duration(cfg.Scrub.IntervalMin) * time.Minute,
+			Repair:   cfg.Scrub.Repair,
+			OnReport: func(ctx context.Context, report firmware.FsckReport) {
+				whSvc.Dispatch(ctx, "storage.integrity_issues", report)
+			},
+		}
+		go scrubber.Run(ctx)
+	}
+
+	statsHandler := &handlers.StatsHandler{
 		Auth: authHandler,
-		Repo: whRepo,
+		Repo: statsRepo,
+	}
+
+	auditHandler := &handlers.AuditHandler{
+		Auth:       authHandler,
+		Repo:       auditRepo,
+		PublicBase: cfg.PublicBaseURL,
+	}
+
+	keysHandler := &handlers.KeysHandler{
+		Auth:  authHandler,
+		Keys:  keySvc,
+		Audit: auditLog,
+	}
+
+	whoamiHandler := &handlers.WhoAmIHandler{Auth: authHandler}
+
+	var metricsHandler http.Handler
+	if cfg.Metrics.Enabled {
+		metrics.RegisterInventory(func() ([]metrics.TypeInventory, error) {
+			inv, err := fwRepo.Inventory(context.Background())
+			out := make([]metrics.TypeInventory, 0, len(inv))
+			for _, t := range inv {
+				out = append(out, metrics.TypeInventory{Type: t.Type, Count: t.Count, Bytes: t.Bytes})
+			}
+			return out, err
+		})
+		metricsHandler = metrics.Handler(cfg.Metrics.Token)
 	}
 
-	router := api.NewRouter(fwHandler, whHandler)
+	router := api.NewRouter(fwHandler, whHandler, adminHandler, statsHandler, auditHandler, keysHandler, whoamiHandler, metricsHandler)
 
-	// Apply middlewares: logging first, then CORS
-	handler := logging.HTTPLogger(router)
+	// Apply middlewares: rate limits and route network restrictions,
+	// logging, tracing (so request logs carry the trace ID), client address
+	// resolution (used by logs and auth), then CORS
+	handler := authHandler.RateLimit(router)
+	handler = authHandler.RestrictRoutes(handler)
+	handler = logging.HTTPLogger(handler)
+	handler = tracing.Middleware(handler)
+	handler = resolver.Middleware(handler)
 	handler = api.CORSMiddleware(handler)
 
-	log.Info().
-		Str("listen_addr", cfg.ListenAddr).
-		Msg("Firmware Registry API listening")
+	srv := &http.Server{
+		Addr:              cfg.ListenAddr,
+		Handler:           handler,
+		ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeoutSec) * time.Second,
+		ReadTimeout:       time.Duration(cfg.Server.ReadTimeoutSec) * time.Second,
+		WriteTimeout:      time.Duration(cfg.Server.WriteTimeoutSec) * time.Second,
+		IdleTimeout:       time.Duration(cfg.Server.IdleTimeoutSec) * time.Second,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+	}
+
+	// Optional plain HTTP listener that only redirects to HTTPS
+	var redirectSrv *http.Server
+	if cfg.TLSEnabled() {
+		reloader, err := tlsconfig.NewReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile,
+			time.Duration(cfg.TLS.ReloadIntervalSec)*time.Second)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to load TLS certificate")
+		}
+		srv.TLSConfig, err = tlsconfig.Server(cfg, reloader)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid TLS configuration")
+		}
+		go reloader.Run(ctx)
+
+		if cfg.TLS.RedirectAddr != "" {
+			redirectSrv = &http.Server{
+				Addr:              cfg.TLS.RedirectAddr,
+				Handler:           tlsconfig.RedirectHandler(cfg.ListenAddr),
+				ReadHeaderTimeout: srv.ReadHeaderTimeout,
+				IdleTimeout:       srv.IdleTimeout,
+				MaxHeaderBytes:    srv.MaxHeaderBytes,
+			}
+		}
+	} else if cfg.TLS.CertFile != "" || cfg.TLS.KeyFile != "" || cfg.TLS.RedirectAddr != "" {
+		log.Warn().Msg("TLS needs both a certificate and a key file, serving plain HTTP")
+	}
+
+	serveErr := make(chan error, 2)
+	go func() {
+		log.Info().
+			Str("listen_addr", cfg.ListenAddr).
+			Bool("tls", srv.TLSConfig != nil).
+			Msg("Firmware Registry API listening")
+		if srv.TLSConfig != nil {
+			serveErr <- srv.ListenAndServeTLS("", "")
+		} else {
+			serveErr <- srv.ListenAndServe()
+		}
+	}()
+	if redirectSrv != nil {
+		go func() {
+			log.Info().
+				Str("listen_addr", redirectSrv.Addr).
+				Msg("HTTP to HTTPS redirect listening")
+			serveErr <- redirectSrv.ListenAndServe()
+		}()
+	}
 
-	if err := http.ListenAndServe(cfg.ListenAddr, handler); err != nil {
+	select {
+	case err := <-serveErr:
 		log.Fatal().Err(err).Msg("HTTP server failed")
+	case <-ctx.Done():
+	}
+	stop()
+
+	grace := time.Duration(cfg.Server.ShutdownGraceSec) * time.Second
+	log.Info().Dur("grace", grace).Msg("Shutting down, draining requests")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	// Stop accepting connections and wait for in-flight uploads and
+	// downloads; whatever is still running at the deadline is cut off.
+	if redirectSrv != nil {
+		_ = redirectSrv.Shutdown(shutdownCtx)
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Warn().Err(err).Msg("Requests still running at shutdown deadline, closing connections")
+		_ = srv.Close()
+	}
+	if err := whSvc.Close(shutdownCtx); err != nil {
+		log.Warn().Err(err).Msg("Webhook deliveries still running at shutdown deadline, requeued")
+	}
+	if statsRecorder != nil {
+		statsRecorder.Close()
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Warn().Err(err).Msg("Failed to flush traces")
+	}
+	if err := database.Close(); err != nil {
+		log.Error().Err(err).Msg("Failed to close database")
 	}
+	log.Info().Msg("Shutdown complete")
 }
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"firmware-registry-api/internal/auth"
+	"firmware-registry-api/internal/config"
+)
+
+// oidcProviders converts the configured issuers for auth.NewOIDCVerifier.
+func oidcProviders(cfg config.Config) []auth.OIDCProviderConfig {
+	var out []auth.OIDCProviderConfig
+	for _, p := range cfg.OIDCProviders() {
+		out = append(out, auth.OIDCProviderConfig{
+			IssuerURL:  p.IssuerURL,
+			ClientIDs:  splitList(p.ClientID),
+			Audiences:  splitList(p.Audience),
+			RoleClaims: splitList(p.RoleClaims),
+			AdminRole:  p.AdminRole,
+			DeviceRole: p.DeviceRole,
+			JWKS:       p.JWKS,
+		})
+	}
+	return out
+}
+
+// introspectionConfig converts the introspection settings for
+// auth.NewIntrospector.
+func introspectionConfig(cfg config.Config) auth.IntrospectionConfig {
+	in := cfg.Introspection
+	return auth.IntrospectionConfig{
+		URL:          in.URL,
+		ClientID:     in.ClientID,
+		ClientSecret: in.ClientSecret,
+		Audiences:    splitList(in.Audience),
+		RoleClaims:   splitList(in.RoleClaims),
+		AdminRole:    in.AdminRole,
+		DeviceRole:   in.DeviceRole,
+		CacheTTL:     time.Duration(in.CacheSec) * time.Second,
+	}
+}
+
+// splitList splits a comma-separated setting, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
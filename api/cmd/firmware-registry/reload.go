@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"firmware-registry-api/internal/auth"
+	"firmware-registry-api/internal/clientip"
+	"firmware-registry-api/internal/config"
+	"firmware-registry-api/internal/logging"
+	"firmware-registry-api/internal/rbac"
+	"firmware-registry-api/internal/webhook"
+
+	"github.com/rs/zerolog/log"
+)
+
+// authSettings builds the reloadable auth settings from cfg. At startup
+// (strict=false) invalid no-auth allowlist entries are skipped with a
+// warning and invalid RBAC roles leave none defined, which only narrows
+// access; on reload they reject the new config. Invalid admin or route
+// network restrictions are always an error: skipping them would widen
+// access.
+func authSettings(cfg config.Config, strict bool) (auth.Settings, error) {
+	var lists [3]clientip.Networks
+	for i, l := range []struct {
+		field, env, list, msg string
+		restricts             bool
+	}{
+		{"noauth_ips", "FW_NOAUTH_IPS", cfg.NoAuthIPs, "No-auth IP/subnet whitelist configured", false},
+		{"device_noauth_ips", "FW_DEVICE_NOAUTH_IPS", cfg.DeviceNoAuthIPs, "Device no-auth IP/subnet whitelist configured", false},
+		{"admin_networks", "FW_ADMIN_NETWORKS", cfg.AdminNetworks, "Admin access restricted to networks", true},
+	} {
+		networks, invalid := clientip.ParseNetworks(l.list)
+		if len(invalid) > 0 {
+			if strict || l.restricts {
+				return auth.Settings{}, fmt.Errorf("invalid entries in %s: %s", l.field, strings.Join(invalid, ", "))
+			}
+			for _, entry := range invalid {
+				log.Warn().
+					Str("entry", entry).
+					Msg("Invalid IP address or CIDR subnet in " + l.env + ", skipping")
+			}
+		}
+		if !networks.Empty() {
+			log.Info().Strs("networks", networks.Strings()).Msg(l.msg)
+		}
+		lists[i] = networks
+	}
+
+	routes, err := clientip.ParseRouteRules(cfg.RouteNetworks)
+	if err != nil {
+		return auth.Settings{}, fmt.Errorf("invalid route_networks: %w", err)
+	}
+	for _, rule := range routes {
+		log.Info().
+			Str("route", rule.Route).
+			Strs("networks", rule.Networks.Strings()).
+			Msg("Route restricted to networks")
+	}
+
+	roles, err := rbac.ParseRoles(cfg.RBAC.Roles)
+	if err != nil {
+		if strict {
+			return auth.Settings{}, fmt.Errorf("invalid rbac.roles: %w", err)
+		}
+		log.Error().Err(err).Msg("Invalid RBAC roles, no roles defined")
+		roles = nil
+	} else if len(roles) > 0 {
+		names := make([]string, 0, len(roles))
+		for name := range roles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		log.Info().Strs("roles", names).Msg("RBAC roles configured")
+	}
+
+	return auth.Settings{
+		AdminKey:      cfg.AdminKey,
+		DeviceKey:     cfg.DeviceKey,
+		NoAuth:        lists[0],
+		DeviceNoAuth:  lists[1],
+		AdminNetworks: lists[2],
+		Routes:        routes,
+		Roles:         roles,
+	}, nil
+}
+
+// clientIPSettings builds the client address resolver settings from cfg. At
+// startup (strict=false) an invalid setting trusts no proxies, so forwarding
+// headers are ignored; on reload it rejects the new config.
+func clientIPSettings(cfg config.Config, strict bool) (clientip.Settings, error) {
+	s, err := clientip.ParseSettings(cfg.TrustedProxies, cfg.ClientIPHeader)
+	if err != nil {
+		if strict {
+			return clientip.Settings{}, err
+		}
+		log.Error().Err(err).Msg("Invalid client IP settings, trusting no proxies")
+		return clientip.Settings{Header: clientip.HeaderXForwardedFor}, nil
+	}
+	if len(s.Trusted) > 0 {
+		proxies := make([]string, len(s.Trusted))
+		for i, n := range s.Trusted {
+			proxies[i] = n.String()
+		}
+		log.Info().
+			Strs("trusted_proxies", proxies).
+			Str("header", s.Header).
+			Msg("Trusted proxies configured")
+	}
+	return s, nil
+}
+
+func webhookSettings(cfg config.Config) webhook.Settings {
+	return webhook.Settings{
+		Secret:     cfg.Webhooks.Secret,
+		TimeoutSec: cfg.Webhooks.TimeoutSec,
+		Retries:    cfg.Webhooks.Retries,
+	}
+}
+
+// applyConfig returns the config.Reloader callback. Everything is validated
+// before anything is swapped, so a rejected config changes nothing.
+func applyConfig(authStore *auth.Store, resolver *clientip.Resolver, whSvc *webhook.Service) func(config.Config) error {
+	return func(cfg config.Config) error {
+		settings, err := authSettings(cfg, true)
+		if err != nil {
+			return err
+		}
+		proxies, err := clientIPSettings(cfg, true)
+		if err != nil {
+			return err
+		}
+		if cfg.Webhooks.TimeoutSec <= 0 || cfg.Webhooks.Retries < 0 {
+			return fmt.Errorf("invalid webhook settings: timeout_sec must be > 0 and retries >= 0")
+		}
+		if err := logging.SetLevel(cfg.Logging.Level); err != nil {
+			return err
+		}
+
+		authStore.Set(settings)
+		resolver.Set(proxies)
+		whSvc.Update(webhookSettings(cfg))
+		return nil
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"firmware-registry-api/internal/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// runValidateConfig implements `firmware-registry validate-config [-config file] [-strict]`.
+// It loads the config exactly like the server, lists every problem on stderr
+// and prints the effective config with secrets redacted on stdout. The exit
+// code is 1 if there are errors (or, with -strict, any problems at all).
+func runValidateConfig(args []string) {
+	fs := flag.NewFlagSet("validate-config", flag.ExitOnError)
+	path := fs.String("config", os.Getenv("FW_CONFIG_FILE"), "YAML config file (default $FW_CONFIG_FILE)")
+	strict := fs.Bool("strict", false, "treat warnings as errors")
+	_ = fs.Parse(args)
+
+	cfg, err := config.Load(*path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	problems := cfg.Validate()
+	for _, p := range problems {
+		fmt.Fprintln(os.Stderr, p)
+	}
+
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg.Redacted()); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	_ = enc.Close()
+
+	errs := len(problems.Errors())
+	fmt.Fprintf(os.Stderr, "%d errors, %d warnings\n", errs, len(problems)-errs)
+	if errs > 0 || (*strict && len(problems) > 0) {
+		os.Exit(1)
+	}
+}
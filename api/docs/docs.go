@@ -24,40 +24,31 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/firmware/{type}": {
+        "/admin/bans": {
             "get": {
                 "security": [
                     {
-                        "DeviceKeyAuth": []
+                        "ApiKeyAuth": []
                     },
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Get all firmware versions for a specific type, sorted by semantic version (newest first)",
+                "description": "Client IPs refused after repeated authentication failures (see FW_BAN_FAILURES). Bans are held in memory and lifted on restart.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "firmware"
-                ],
-                "summary": "List firmware versions",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Firmware type (e.g., esp32-main)",
-                        "name": "type",
-                        "in": "path",
-                        "required": true
-                    }
+                    "admin"
                 ],
+                "summary": "List banned client IPs",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/firmware-registry-api_internal_firmware.FirmwareDTO"
+                                "$ref": "#/definitions/firmware-registry-api_internal_ratelimit.Ban"
                             }
                         }
                     },
@@ -66,114 +57,68 @@ const docTemplate = `{
                         "schema": {
                             "type": "string"
                         }
-                    },
-                    "500": {
-                        "description": "Database error",
-                        "schema": {
-                            "type": "string"
-                        }
                     }
                 }
-            }
-        },
-        "/firmware/{type}/latest": {
-            "get": {
+            },
+            "delete": {
                 "security": [
                     {
-                        "DeviceKeyAuth": []
+                        "ApiKeyAuth": []
                     },
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Get the latest firmware version for a specific type based on semantic versioning",
-                "produces": [
-                    "application/json"
-                ],
+                "description": "Lift every ban and forget recorded authentication failures",
                 "tags": [
-                    "firmware"
-                ],
-                "summary": "Get latest firmware",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Firmware type (e.g., esp32-main)",
-                        "name": "type",
-                        "in": "path",
-                        "required": true
-                    }
+                    "admin"
                 ],
+                "summary": "Lift all bans",
                 "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/firmware-registry-api_internal_firmware.FirmwareDTO"
-                        }
+                    "204": {
+                        "description": "No Content"
                     },
                     "401": {
                         "description": "Unauthorized",
                         "schema": {
                             "type": "string"
                         }
-                    },
-                    "404": {
-                        "description": "No firmware found",
-                        "schema": {
-                            "type": "string"
-                        }
                     }
                 }
             }
         },
-        "/firmware/{type}/{version}": {
-            "get": {
+        "/admin/bans/{ip}": {
+            "delete": {
                 "security": [
                     {
-                        "DeviceKeyAuth": []
+                        "ApiKeyAuth": []
                     },
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Download the firmware binary for a specific type and version",
-                "produces": [
-                    "application/octet-stream"
-                ],
+                "description": "Lift the ban on a client IP and forget its authentication failures",
                 "tags": [
-                    "firmware"
+                    "admin"
                 ],
-                "summary": "Download firmware",
+                "summary": "Lift a ban",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Firmware type (e.g., esp32-main)",
-                        "name": "type",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Semantic version (e.g., 1.2.3)",
-                        "name": "version",
+                        "description": "Client IP",
+                        "name": "ip",
                         "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Firmware binary file",
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid IP address",
                         "schema": {
-                            "type": "file"
-                        },
-                        "headers": {
-                            "X-Firmware-Sha256": {
-                                "type": "string",
-                                "description": "SHA256 checksum of the firmware"
-                            },
-                            "X-Firmware-Version": {
-                                "type": "string",
-                                "description": "Firmware version"
-                            }
+                            "type": "string"
                         }
                     },
                     "401": {
@@ -183,14 +128,16 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "Firmware not found",
+                        "description": "IP is not banned",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
-            },
-            "post": {
+            }
+        },
+        "/admin/config": {
+            "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
@@ -199,51 +146,19 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Upload a new firmware binary for a specific type and version",
-                "consumes": [
-                    "multipart/form-data"
-                ],
+                "description": "Report when the running config was loaded and the outcome of the last reload attempt. The config is reloaded on SIGHUP and when FW_CONFIG_FILE changes; applied without a restart are the admin and device keys, the no-auth and device allowlists, admin and route networks, trusted proxies and the client IP header, RBAC roles, webhook delivery settings and the log level; restartRequired is set when other settings changed and need a restart.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "firmware"
-                ],
-                "summary": "Upload firmware",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Firmware type (e.g., esp32-main)",
-                        "name": "type",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Semantic version (e.g., 1.2.3)",
-                        "name": "version",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "file",
-                        "description": "Firmware binary file",
-                        "name": "file",
-                        "in": "formData",
-                        "required": true
-                    }
+                    "admin"
                 ],
+                "summary": "Config reload status",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/firmware-registry-api_internal_firmware.FirmwareDTO"
-                        }
-                    },
-                    "400": {
-                        "description": "Invalid multipart or missing file",
-                        "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/firmware-registry-api_internal_config.ReloadStatus"
                         }
                     },
                     "401": {
@@ -251,16 +166,12 @@ const docTemplate = `{
                         "schema": {
                             "type": "string"
                         }
-                    },
-                    "500": {
-                        "description": "Save failed",
-                        "schema": {
-                            "type": "string"
-                        }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/admin/export": {
+            "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
@@ -269,38 +180,28 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Delete a firmware binary and its metadata",
+                "description": "Stream a tar archive containing a consistent database snapshot, every firmware binary and a manifest of SHA256 checksums",
                 "produces": [
-                    "application/json"
+                    "application/gzip",
+                    "application/x-tar"
                 ],
                 "tags": [
-                    "firmware"
+                    "admin"
                 ],
-                "summary": "Delete firmware",
+                "summary": "Export registry",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Firmware type (e.g., esp32-main)",
-                        "name": "type",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Semantic version (e.g., 1.2.3)",
-                        "name": "version",
-                        "in": "path",
-                        "required": true
+                        "type": "boolean",
+                        "description": "Compress the archive (default true)",
+                        "name": "gzip",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Deletion confirmation",
+                        "description": "Registry archive",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "boolean"
-                            }
+                            "type": "file"
                         }
                     },
                     "401": {
@@ -308,17 +209,11 @@ const docTemplate = `{
                         "schema": {
                             "type": "string"
                         }
-                    },
-                    "404": {
-                        "description": "Firmware not found",
-                        "schema": {
-                            "type": "string"
-                        }
                     }
                 }
             }
         },
-        "/webhooks": {
+        "/admin/fsck": {
             "get": {
                 "security": [
                     {
@@ -328,22 +223,27 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Get all registered webhooks",
+                "description": "Cross-check database rows against stored binaries. GET only reports; POST with repair=true also deletes rows without binaries and removes orphaned and temporary files. Size and checksum mismatches are never repaired.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "webhooks"
+                    "admin"
+                ],
+                "summary": "Check storage integrity",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Repair issues (POST only)",
+                        "name": "repair",
+                        "in": "query"
+                    }
                 ],
-                "summary": "List webhooks",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/firmware-registry-api_internal_webhook.WebhookDTO"
-                            }
+                            "$ref": "#/definitions/firmware-registry-api_internal_firmware.FsckReport"
                         }
                     },
                     "401": {
@@ -353,7 +253,7 @@ const docTemplate = `{
                         }
                     },
                     "500": {
-                        "description": "Database error",
+                        "description": "Integrity check failed",
                         "schema": {
                             "type": "string"
                         }
@@ -369,42 +269,27 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Register a new webhook endpoint",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Cross-check database rows against stored binaries. GET only reports; POST with repair=true also deletes rows without binaries and removes orphaned and temporary files. Size and checksum mismatches are never repaired.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "webhooks"
+                    "admin"
                 ],
-                "summary": "Create webhook",
+                "summary": "Check storage integrity",
                 "parameters": [
                     {
-                        "description": "Webhook configuration",
-                        "name": "webhook",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/firmware-registry-api_internal_webhook.WebhookDTO"
-                        }
+                        "type": "boolean",
+                        "description": "Repair issues (POST only)",
+                        "name": "repair",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Created webhook ID",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "integer"
-                            }
-                        }
-                    },
-                    "400": {
-                        "description": "Invalid JSON or missing required fields",
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/firmware-registry-api_internal_firmware.FsckReport"
                         }
                     },
                     "401": {
@@ -414,7 +299,7 @@ const docTemplate = `{
                         }
                     },
                     "500": {
-                        "description": "Database error",
+                        "description": "Integrity check failed",
                         "schema": {
                             "type": "string"
                         }
@@ -422,8 +307,8 @@ const docTemplate = `{
                 }
             }
         },
-        "/webhooks/{id}": {
-            "put": {
+        "/admin/import": {
+            "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
@@ -432,47 +317,35 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Update an existing webhook configuration",
+                "description": "Restore an archive produced by the export endpoint (tar or tar.gz request body). Every entry is verified against the manifest before anything is written. New releases are added, identical ones left alone and conflicting ones handled according to the conflict policy. Webhooks are merged by URL.",
                 "consumes": [
-                    "application/json"
+                    "application/gzip",
+                    "application/x-tar"
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "webhooks"
+                    "admin"
                 ],
-                "summary": "Update webhook",
+                "summary": "Import registry",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Webhook ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "Updated webhook configuration",
-                        "name": "webhook",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/firmware-registry-api_internal_webhook.WebhookDTO"
-                        }
+                        "type": "string",
+                        "description": "Conflict policy: skip (default), overwrite or fail",
+                        "name": "conflict",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Update confirmation",
+                        "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "boolean"
-                            }
+                            "$ref": "#/definitions/firmware-registry-api_internal_backup.ImportReport"
                         }
                     },
                     "400": {
-                        "description": "Invalid JSON or webhook ID",
+                        "description": "Invalid conflict policy",
                         "schema": {
                             "type": "string"
                         }
@@ -483,15 +356,17 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
-                    "500": {
-                        "description": "Database error",
+                    "422": {
+                        "description": "Invalid archive or conflicting releases",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/audit": {
+            "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
@@ -500,35 +375,370 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Remove a webhook from the registry",
+                "description": "Page through audit events, newest first. The total number of matches is returned in X-Total-Count and the next page in a Link header (rel=\"next\").",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "webhooks"
+                    "audit"
                 ],
-                "summary": "Delete webhook",
+                "summary": "List audit events",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Webhook ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    }
-                ],
+                        "type": "string",
+                        "description": "API key name, JWT subject or client IP",
+                        "name": "actor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "e.g. firmware.upload, firmware.delete, webhook.create, auth.failure",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Target firmware type",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Target firmware version",
+                        "name": "version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Target webhook ID",
+                        "name": "webhook_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "success or failure",
+                        "name": "outcome",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Inclusive start, RFC3339",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Inclusive end, RFC3339",
+                        "name": "until",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 100, max 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only events with a smaller ID (cursor from the Link header)",
+                        "name": "before",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
-                        "description": "Deletion confirmation",
+                        "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "boolean"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/firmware-registry-api_internal_audit.Event"
+                            }
+                        },
+                        "headers": {
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Events matching the filters"
                             }
                         }
                     },
                     "400": {
-                        "description": "Invalid webhook ID",
+                        "description": "Invalid filter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Database error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/audit/export": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Stream every matching audit event as JSON Lines, oldest first",
+                "produces": [
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "audit"
+                ],
+                "summary": "Export audit events",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API key name, JWT subject or client IP",
+                        "name": "actor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Action",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Target firmware type",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Target firmware version",
+                        "name": "version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Target webhook ID",
+                        "name": "webhook_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "success or failure",
+                        "name": "outcome",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Inclusive start, RFC3339",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Inclusive end, RFC3339",
+                        "name": "until",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "One audit.Event per line",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid filter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/firmware/by-sha256/{hash}": {
+            "get": {
+                "security": [
+                    {
+                        "DeviceKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ManagedKeyAuth": []
+                    }
+                ],
+                "description": "Return every type/version whose binary has the given SHA256",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "firmware"
+                ],
+                "summary": "Find firmware by checksum",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "SHA256 checksum (64 hex characters)",
+                        "name": "hash",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/firmware-registry-api_internal_firmware.FirmwareDTO"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid checksum",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Database error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/firmware/{type}": {
+            "get": {
+                "security": [
+                    {
+                        "DeviceKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ManagedKeyAuth": []
+                    }
+                ],
+                "description": "Page through the firmware versions of a type. Results are sorted newest semantic version first unless sort says otherwise. The total number of matches is returned in X-Total-Count and the next page in a Link header (rel=\"next\").",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "firmware"
+                ],
+                "summary": "List firmware versions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Firmware type (e.g., esp32-main)",
+                        "name": "type",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 100, max 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Opaque cursor from the previous page's Link header",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "version_desc (default), version_asc, created_desc or created_asc",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Lowest semantic version to include",
+                        "name": "min_version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Highest semantic version to include",
+                        "name": "max_version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only uploads at or after this RFC3339 time",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only uploads at or before this RFC3339 time",
+                        "name": "until",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only releases in this channel",
+                        "name": "channel",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only releases carrying this label",
+                        "name": "label",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/firmware-registry-api_internal_firmware.FirmwareDTO"
+                            }
+                        },
+                        "headers": {
+                            "Link": {
+                                "type": "string",
+                                "description": "URL of the next page"
+                            },
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Number of releases matching the filters"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameter",
                         "schema": {
                             "type": "string"
                         }
@@ -539,6 +749,12 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "403": {
+                        "description": "Missing read permission for this firmware type",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "500": {
                         "description": "Database error",
                         "schema": {
@@ -547,12 +763,1730 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
+        },
+        "/firmware/{type}/latest": {
+            "get": {
+                "security": [
+                    {
+                        "DeviceKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ManagedKeyAuth": []
+                    }
+                ],
+                "description": "Get the latest firmware version for a specific type based on semantic versioning",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "firmware"
+                ],
+                "summary": "Get latest firmware",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Firmware type (e.g., esp32-main)",
+                        "name": "type",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only consider releases in this channel",
+                        "name": "channel",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/firmware-registry-api_internal_firmware.FirmwareDTO"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing read permission for this firmware type",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No firmware found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/firmware/{type}/{version}": {
+            "get": {
+                "security": [
+                    {
+                        "DeviceKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ManagedKeyAuth": []
+                    }
+                ],
+                "description": "Download the firmware binary for a specific type and version",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "firmware"
+                ],
+                "summary": "Download firmware",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Firmware type (e.g., esp32-main)",
+                        "name": "type",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Semantic version (e.g., 1.2.3)",
+                        "name": "version",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Device identity for download statistics",
+                        "name": "X-Device-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Device identity for clients that cannot set headers",
+                        "name": "device_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Pre-signed URLs: expiry (Unix time)",
+                        "name": "expires",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Pre-signed URLs: single-use nonce",
+                        "name": "nonce",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Pre-signed URLs: bound client IP",
+                        "name": "ip",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Pre-signed URLs: signature; no other authentication is needed",
+                        "name": "sig",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Firmware binary file",
+                        "schema": {
+                            "type": "file"
+                        },
+                        "headers": {
+                            "X-Firmware-Sha256": {
+                                "type": "string",
+                                "description": "SHA256 checksum of the firmware"
+                            },
+                            "X-Firmware-Version": {
+                                "type": "string",
+                                "description": "Firmware version"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing read permission for this firmware type, or invalid, expired or used pre-signed URL",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Firmware not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ManagedKeyAuth": []
+                    }
+                ],
+                "description": "Upload a new firmware binary for a specific type and version",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "firmware"
+                ],
+                "summary": "Upload firmware",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Firmware type (e.g., esp32-main)",
+                        "name": "type",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Semantic version (e.g., 1.2.3)",
+                        "name": "version",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "Firmware binary file",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Release channel (default stable)",
+                        "name": "channel",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated release labels",
+                        "name": "labels",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/firmware-registry-api_internal_firmware.FirmwareDTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid multipart, missing file or invalid channel/labels",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing permission for this firmware type",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Save failed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ManagedKeyAuth": []
+                    }
+                ],
+                "description": "Delete a firmware binary and its metadata",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "firmware"
+                ],
+                "summary": "Delete firmware",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Firmware type (e.g., esp32-main)",
+                        "name": "type",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Semantic version (e.g., 1.2.3)",
+                        "name": "version",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deletion confirmation",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "boolean"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing permission for this firmware type",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Firmware not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Delete failed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/firmware/{type}/{version}/presign": {
+            "post": {
+                "security": [
+                    {
+                        "DeviceKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ManagedKeyAuth": []
+                    }
+                ],
+                "description": "Mint an HMAC-signed URL that downloads this release without credentials until it expires, for bootloaders and tools that cannot set headers. It can be limited to one download and to one client IP. Requires read permission on the type and FW_PRESIGN_SECRET.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "firmware"
+                ],
+                "summary": "Pre-sign a download URL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Firmware type (e.g., esp32-main)",
+                        "name": "type",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Semantic version (e.g., 1.2.3)",
+                        "name": "version",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Lifetime, single use and IP binding",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/firmware-registry-api_internal_presign.CreateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/firmware-registry-api_internal_presign.URL"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing read permission for this firmware type",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Firmware not found or pre-signed URLs disabled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/keys": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List managed API keys. Secrets are never returned; prefix identifies a key.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "keys"
+                ],
+                "summary": "List API keys",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Include revoked keys",
+                        "name": "includeRevoked",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/firmware-registry-api_internal_apikey.Key"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Database error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create a named API key with scopes and/or RBAC roles and an optional expiry. A scope is a grant such as esp32-*:upload, or a bare action (upload, delete, read, webhooks:manage) for all firmware types. The secret is returned only in this response; store it immediately.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "keys"
+                ],
+                "summary": "Create API key",
+                "parameters": [
+                    {
+                        "description": "Key name, scopes, roles and expiry",
+                        "name": "key",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/firmware-registry-api_internal_apikey.CreateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/firmware-registry-api_internal_apikey.CreatedKey"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid name, scopes, roles or expiry",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "An active key with this name exists",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Database error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/keys/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Revoke a managed API key immediately. The key stays listed with includeRevoked=true.",
+                "tags": [
+                    "keys"
+                ],
+                "summary": "Revoke API key",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Key ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Revoked"
+                    },
+                    "400": {
+                        "description": "Invalid key ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Key not found or already revoked",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Database error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/search": {
+            "get": {
+                "security": [
+                    {
+                        "DeviceKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ManagedKeyAuth": []
+                    }
+                ],
+                "description": "Search all types for releases whose type, version, filename, channel, labels or checksum contain every whitespace-separated word of the query as a case-insensitive substring",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "firmware"
+                ],
+                "summary": "Search firmware",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Search text (e.g., esp32 1.2)",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum results (default 50, max 500)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/firmware-registry-api_internal_firmware.FirmwareDTO"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Missing query",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Database error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/stats/{type}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Downloads, completions, abort rate and unique devices for a firmware type, in total and per version",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stats"
+                ],
+                "summary": "Download summary",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Firmware type (e.g., esp32-main)",
+                        "name": "type",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start of period, RFC3339 (default 30 days ago)",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of period, RFC3339 (default now)",
+                        "name": "until",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/firmware-registry-api_internal_stats.Summary"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid time range",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Database error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/stats/{type}/daily": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Downloads per version per UTC day for a firmware type",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stats"
+                ],
+                "summary": "Daily downloads",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Firmware type (e.g., esp32-main)",
+                        "name": "type",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only this version",
+                        "name": "version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start of period, RFC3339 (default 30 days ago)",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of period, RFC3339 (default now)",
+                        "name": "until",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/firmware-registry-api_internal_stats.DailyStats"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid time range",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Database error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ManagedKeyAuth": []
+                    }
+                ],
+                "description": "Get all registered webhooks",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "List webhooks",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/firmware-registry-api_internal_webhook.WebhookDTO"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing webhooks:manage permission",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Database error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ManagedKeyAuth": []
+                    }
+                ],
+                "description": "Register a new webhook endpoint",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Create webhook",
+                "parameters": [
+                    {
+                        "description": "Webhook configuration",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/firmware-registry-api_internal_webhook.WebhookDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Created webhook ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "integer"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid JSON or missing required fields",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing webhooks:manage permission",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Database error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ManagedKeyAuth": []
+                    }
+                ],
+                "description": "Get a webhook with the health of its deliveries: queued deliveries, counts over the last 24 hours, and the last success and failure. A webhook is failing while its most recent attempt failed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Get webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/firmware-registry-api_internal_webhook.WebhookDetailDTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid webhook ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing webhooks:manage permission",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Webhook not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Database error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ManagedKeyAuth": []
+                    }
+                ],
+                "description": "Update an existing webhook configuration",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Update webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated webhook configuration",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/firmware-registry-api_internal_webhook.WebhookDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Update confirmation",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "boolean"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid JSON or webhook ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing webhooks:manage permission",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Database error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ManagedKeyAuth": []
+                    }
+                ],
+                "description": "Remove a webhook from the registry",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Delete webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deletion confirmation",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "boolean"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid webhook ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing webhooks:manage permission",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Database error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}/deliveries": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ManagedKeyAuth": []
+                    }
+                ],
+                "description": "Page through the deliveries of a webhook, newest first, each with its payload and every attempt: request headers, response status, the first 4 KiB of the response body, latency and error. Finished deliveries are kept for FW_WEBHOOK_RETENTION_DAYS. The total number of matches is returned in X-Total-Count and the next page in a Link header (rel=\"next\").",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "List webhook deliveries",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "pending, in_progress, delivered or failed",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 50, max 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only deliveries with a smaller ID (cursor from the Link header)",
+                        "name": "before",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/firmware-registry-api_internal_webhook.DeliveryDTO"
+                            }
+                        },
+                        "headers": {
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Deliveries matching the filters"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid webhook ID or filter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing webhooks:manage permission",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Webhook not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Database error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}/deliveries/{deliveryId}/redeliver": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ManagedKeyAuth": []
+                    }
+                ],
+                "description": "Queue the payload of a delivery again as a new delivery, with its own attempts and retries. The new delivery references the original in redeliveryOf. The webhook must be enabled.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Redeliver webhook event",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Delivery ID",
+                        "name": "deliveryId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Queued delivery",
+                        "schema": {
+                            "$ref": "#/definitions/firmware-registry-api_internal_webhook.DeliveryDTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid webhook or delivery ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing webhooks:manage permission",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Webhook or delivery not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Webhook is disabled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Database error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/whoami": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "DeviceKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ManagedKeyAuth": []
+                    }
+                ],
+                "description": "Show how the request was authenticated and the caller's effective permissions, including grants from RBAC roles. Accepts any valid credential.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Show caller identity",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/firmware-registry-api_internal_auth.Identity"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "firmware-registry-api_internal_apikey.CreateRequest": {
+            "type": "object",
+            "properties": {
+                "expiresAt": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "ci-esp32-main"
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "esp32-team"
+                    ]
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "esp32-*:upload",
+                        "read"
+                    ]
+                }
+            }
+        },
+        "firmware-registry-api_internal_apikey.CreatedKey": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "createdBy": {
+                    "type": "string",
+                    "example": "admin/api_key/admin-key"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 7
+                },
+                "lastUsedAt": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "ci-esp32-main"
+                },
+                "prefix": {
+                    "type": "string",
+                    "example": "fwk_Xr3kQ9aB"
+                },
+                "revokedAt": {
+                    "type": "string"
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "esp32-team"
+                    ]
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "esp32-*:upload",
+                        "read"
+                    ]
+                },
+                "secret": {
+                    "type": "string",
+                    "example": "fwk_Xr3kQ9aB..."
+                }
+            }
+        },
+        "firmware-registry-api_internal_apikey.Key": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "createdBy": {
+                    "type": "string",
+                    "example": "admin/api_key/admin-key"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 7
+                },
+                "lastUsedAt": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "ci-esp32-main"
+                },
+                "prefix": {
+                    "type": "string",
+                    "example": "fwk_Xr3kQ9aB"
+                },
+                "revokedAt": {
+                    "type": "string"
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "esp32-team"
+                    ]
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "esp32-*:upload",
+                        "read"
+                    ]
+                }
+            }
+        },
+        "firmware-registry-api_internal_audit.Action": {
+            "type": "string",
+            "enum": [
+                "firmware.upload",
+                "firmware.delete",
+                "webhook.create",
+                "webhook.update",
+                "webhook.delete",
+                "webhook.redeliver",
+                "registry.import",
+                "storage.repair",
+                "apikey.create",
+                "apikey.revoke",
+                "auth.failure",
+                "auth.denied",
+                "auth.ban",
+                "ban.clear"
+            ],
+            "x-enum-varnames": [
+                "ActionFirmwareUpload",
+                "ActionFirmwareDelete",
+                "ActionWebhookCreate",
+                "ActionWebhookUpdate",
+                "ActionWebhookDelete",
+                "ActionWebhookRedeliver",
+                "ActionRegistryImport",
+                "ActionStorageRepair",
+                "ActionAPIKeyCreate",
+                "ActionAPIKeyRevoke",
+                "ActionAuthFailure",
+                "ActionAuthDenied",
+                "ActionAuthBan",
+                "ActionBanClear"
+            ]
+        },
+        "firmware-registry-api_internal_audit.Event": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/firmware-registry-api_internal_audit.Action"
+                        }
+                    ],
+                    "example": "firmware.upload"
+                },
+                "actor": {
+                    "type": "string",
+                    "example": "alice"
+                },
+                "actorEmail": {
+                    "type": "string",
+                    "example": "alice@example.com"
+                },
+                "actorType": {
+                    "type": "string",
+                    "example": "jwt"
+                },
+                "clientIp": {
+                    "type": "string",
+                    "example": "10.0.0.5"
+                },
+                "detail": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 42
+                },
+                "outcome": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/firmware-registry-api_internal_audit.Outcome"
+                        }
+                    ],
+                    "example": "success"
+                },
+                "requestId": {
+                    "type": "string",
+                    "example": "4f1c2a9e0b7d4e3f8a6b5c4d3e2f1a0b"
+                },
+                "role": {
+                    "type": "string",
+                    "example": "admin"
+                },
+                "targetType": {
+                    "type": "string",
+                    "example": "esp32-main"
+                },
+                "targetVersion": {
+                    "type": "string",
+                    "example": "1.2.3"
+                },
+                "time": {
+                    "type": "string"
+                },
+                "webhookId": {
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "firmware-registry-api_internal_audit.Outcome": {
+            "type": "string",
+            "enum": [
+                "success",
+                "failure"
+            ],
+            "x-enum-varnames": [
+                "OutcomeSuccess",
+                "OutcomeFailure"
+            ]
+        },
+        "firmware-registry-api_internal_auth.Identity": {
+            "type": "object",
+            "properties": {
+                "admin": {
+                    "type": "boolean"
+                },
+                "email": {
+                    "type": "string",
+                    "example": "alice@example.com"
+                },
+                "issuer": {
+                    "type": "string",
+                    "example": "https://keycloak.example.com/realms/firmware"
+                },
+                "method": {
+                    "type": "string",
+                    "example": "api_key"
+                },
+                "permissions": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "esp32-*:upload",
+                        "read"
+                    ]
+                },
+                "role": {
+                    "type": "string",
+                    "example": "scoped"
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "esp32-team"
+                    ]
+                },
+                "subject": {
+                    "type": "string",
+                    "example": "ci-esp32"
+                }
+            }
+        },
+        "firmware-registry-api_internal_backup.ImportReport": {
+            "type": "object",
+            "properties": {
+                "imported": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "overwritten": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "skipped": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "unchanged": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "webhooksImported": {
+                    "type": "integer"
+                },
+                "webhooksSkipped": {
+                    "type": "integer"
+                }
+            }
+        },
+        "firmware-registry-api_internal_config.ReloadStatus": {
+            "type": "object",
+            "properties": {
+                "lastAttemptAt": {
+                    "type": "string"
+                },
+                "lastError": {
+                    "type": "string"
+                },
+                "loadedAt": {
+                    "type": "string"
+                },
+                "reloads": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "restartRequired": {
+                    "description": "RestartRequired is set when the config in effect differs from the\nstartup config in settings that are only applied at startup.",
+                    "type": "boolean"
+                },
+                "source": {
+                    "type": "string",
+                    "example": "/etc/firmware-registry/config.yaml"
+                }
+            }
+        },
         "firmware-registry-api_internal_firmware.FirmwareDTO": {
             "type": "object",
             "properties": {
+                "channel": {
+                    "type": "string",
+                    "example": "stable"
+                },
                 "createdAt": {
                     "type": "string",
                     "example": "2024-01-15T10:30:00Z"
@@ -565,6 +2499,16 @@ const docTemplate = `{
                     "type": "string",
                     "example": "firmware.bin"
                 },
+                "labels": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "nightly",
+                        "qa-passed"
+                    ]
+                },
                 "sha256": {
                     "type": "string",
                     "example": "abc123..."
@@ -583,6 +2527,403 @@ const docTemplate = `{
                 }
             }
         },
+        "firmware-registry-api_internal_firmware.FsckReport": {
+            "type": "object",
+            "properties": {
+                "checked": {
+                    "type": "integer"
+                },
+                "finishedAt": {
+                    "type": "string"
+                },
+                "issues": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/firmware-registry-api_internal_firmware.Issue"
+                    }
+                },
+                "repair": {
+                    "type": "boolean"
+                },
+                "startedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "firmware-registry-api_internal_firmware.Issue": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "type": "string"
+                },
+                "kind": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/firmware-registry-api_internal_firmware.IssueKind"
+                        }
+                    ],
+                    "example": "missing_binary"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "repaired": {
+                    "type": "boolean"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "esp32-main"
+                },
+                "version": {
+                    "type": "string",
+                    "example": "1.2.3"
+                }
+            }
+        },
+        "firmware-registry-api_internal_firmware.IssueKind": {
+            "type": "string",
+            "enum": [
+                "missing_binary",
+                "orphan_binary",
+                "temp_file",
+                "size_mismatch",
+                "checksum_mismatch",
+                "unreadable_binary"
+            ],
+            "x-enum-comments": {
+                "IssueChecksumMismatch": "file hash differs from sha256",
+                "IssueMissingBinary": "DB row without firmware.bin",
+                "IssueOrphanBinary": "firmware.bin without DB row",
+                "IssueSizeMismatch": "file size differs from size_bytes",
+                "IssueTempFile": "leftover *.tmp from an interrupted upload",
+                "IssueUnreadable": "firmware.bin exists but cannot be read"
+            },
+            "x-enum-descriptions": [
+                "DB row without firmware.bin",
+                "firmware.bin without DB row",
+                "leftover *.tmp from an interrupted upload",
+                "file size differs from size_bytes",
+                "file hash differs from sha256",
+                "firmware.bin exists but cannot be read"
+            ],
+            "x-enum-varnames": [
+                "IssueMissingBinary",
+                "IssueOrphanBinary",
+                "IssueTempFile",
+                "IssueSizeMismatch",
+                "IssueChecksumMismatch",
+                "IssueUnreadable"
+            ]
+        },
+        "firmware-registry-api_internal_presign.CreateRequest": {
+            "type": "object",
+            "properties": {
+                "ip": {
+                    "type": "string",
+                    "example": "203.0.113.7"
+                },
+                "singleUse": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "ttlSec": {
+                    "type": "integer",
+                    "example": 900
+                }
+            }
+        },
+        "firmware-registry-api_internal_presign.URL": {
+            "type": "object",
+            "properties": {
+                "expiresAt": {
+                    "type": "string"
+                },
+                "ip": {
+                    "type": "string",
+                    "example": "203.0.113.7"
+                },
+                "singleUse": {
+                    "type": "boolean"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://fw.example.com/api/firmware/esp32-main/1.2.3?expires=1767225600\u0026sig=..."
+                }
+            }
+        },
+        "firmware-registry-api_internal_ratelimit.Ban": {
+            "type": "object",
+            "properties": {
+                "failures": {
+                    "type": "integer",
+                    "example": 10
+                },
+                "ip": {
+                    "type": "string",
+                    "example": "203.0.113.7"
+                },
+                "since": {
+                    "type": "string"
+                },
+                "until": {
+                    "type": "string"
+                }
+            }
+        },
+        "firmware-registry-api_internal_stats.Counts": {
+            "type": "object",
+            "properties": {
+                "abortRate": {
+                    "type": "number",
+                    "example": 0.0417
+                },
+                "aborted": {
+                    "type": "integer",
+                    "example": 50
+                },
+                "bytesServed": {
+                    "type": "integer",
+                    "example": 629145600
+                },
+                "completed": {
+                    "type": "integer",
+                    "example": 1150
+                },
+                "downloads": {
+                    "type": "integer",
+                    "example": 1200
+                },
+                "uniqueDevices": {
+                    "type": "integer",
+                    "example": 800
+                }
+            }
+        },
+        "firmware-registry-api_internal_stats.DailyStats": {
+            "type": "object",
+            "properties": {
+                "abortRate": {
+                    "type": "number",
+                    "example": 0.0417
+                },
+                "aborted": {
+                    "type": "integer",
+                    "example": 50
+                },
+                "bytesServed": {
+                    "type": "integer",
+                    "example": 629145600
+                },
+                "completed": {
+                    "type": "integer",
+                    "example": 1150
+                },
+                "day": {
+                    "type": "string",
+                    "example": "2024-01-15"
+                },
+                "downloads": {
+                    "type": "integer",
+                    "example": 1200
+                },
+                "uniqueDevices": {
+                    "type": "integer",
+                    "example": 800
+                },
+                "version": {
+                    "type": "string",
+                    "example": "1.2.3"
+                }
+            }
+        },
+        "firmware-registry-api_internal_stats.Summary": {
+            "type": "object",
+            "properties": {
+                "since": {
+                    "type": "string"
+                },
+                "total": {
+                    "$ref": "#/definitions/firmware-registry-api_internal_stats.Counts"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "esp32-main"
+                },
+                "until": {
+                    "type": "string"
+                },
+                "versions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/firmware-registry-api_internal_stats.VersionStats"
+                    }
+                }
+            }
+        },
+        "firmware-registry-api_internal_stats.VersionStats": {
+            "type": "object",
+            "properties": {
+                "abortRate": {
+                    "type": "number",
+                    "example": 0.0417
+                },
+                "aborted": {
+                    "type": "integer",
+                    "example": 50
+                },
+                "bytesServed": {
+                    "type": "integer",
+                    "example": 629145600
+                },
+                "completed": {
+                    "type": "integer",
+                    "example": 1150
+                },
+                "downloads": {
+                    "type": "integer",
+                    "example": 1200
+                },
+                "uniqueDevices": {
+                    "type": "integer",
+                    "example": 800
+                },
+                "version": {
+                    "type": "string",
+                    "example": "1.2.3"
+                }
+            }
+        },
+        "firmware-registry-api_internal_webhook.Attempt": {
+            "type": "object",
+            "properties": {
+                "attempt": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "durationMs": {
+                    "type": "number",
+                    "example": 84.5
+                },
+                "error": {
+                    "type": "string",
+                    "example": "non-2xx status: 503 Service Unavailable"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 17
+                },
+                "requestHeaders": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "responseBody": {
+                    "type": "string"
+                },
+                "responseStatus": {
+                    "type": "integer",
+                    "example": 200
+                },
+                "time": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://example.com/webhook"
+                }
+            }
+        },
+        "firmware-registry-api_internal_webhook.DeliveryDTO": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/firmware-registry-api_internal_webhook.Attempt"
+                    }
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "event": {
+                    "type": "string",
+                    "example": "firmware.uploaded"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 42
+                },
+                "nextAttemptAt": {
+                    "type": "string"
+                },
+                "redeliveryOf": {
+                    "type": "integer",
+                    "example": 41
+                },
+                "requestBody": {
+                    "type": "object"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "delivered"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "webhookId": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "firmware-registry-api_internal_webhook.Health": {
+            "type": "object",
+            "properties": {
+                "attempts24h": {
+                    "type": "integer",
+                    "example": 13
+                },
+                "avgDurationMs24h": {
+                    "type": "number",
+                    "example": 84.5
+                },
+                "consecutiveFailures": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "delivered24h": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "failed24h": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "failedAttempts24h": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "lastError": {
+                    "type": "string",
+                    "example": "non-2xx status: 503 Service Unavailable"
+                },
+                "lastFailureAt": {
+                    "type": "string"
+                },
+                "lastSuccessAt": {
+                    "type": "string"
+                },
+                "pending": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "status": {
+                    "type": "string",
+                    "example": "healthy"
+                }
+            }
+        },
         "firmware-registry-api_internal_webhook.WebhookDTO": {
             "type": "object",
             "properties": {
@@ -609,6 +2950,36 @@ const docTemplate = `{
                     "example": "https://example.com/webhook"
                 }
             }
+        },
+        "firmware-registry-api_internal_webhook.WebhookDetailDTO": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "firmware.uploaded",
+                        "firmware.deleted"
+                    ]
+                },
+                "health": {
+                    "$ref": "#/definitions/firmware-registry-api_internal_webhook.Health"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://example.com/webhook"
+                }
+            }
         }
     },
     "securityDefinitions": {
@@ -629,6 +3000,12 @@ const docTemplate = `{
             "type": "apiKey",
             "name": "X-Device-Key",
             "in": "header"
+        },
+        "ManagedKeyAuth": {
+            "description": "Managed API key (fwk_...) created via /keys; access depends on its scopes",
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
         }
     }
 }`
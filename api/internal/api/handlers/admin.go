@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"firmware-registry-api/internal/audit"
+	"firmware-registry-api/internal/auth"
+	"firmware-registry-api/internal/backup"
+	"firmware-registry-api/internal/config"
+	"firmware-registry-api/internal/firmware"
+	"firmware-registry-api/internal/ratelimit"
+	"firmware-registry-api/internal/util"
+	"firmware-registry-api/internal/webhook"
+
+	"github.com/rs/zerolog"
+)
+
+// AdminHandler serves registry maintenance endpoints.
+type AdminHandler struct {
+	Auth     auth.Auth
+	Service  *firmware.Service
+	Webhooks *webhook.Service
+	Backup   *backup.Archiver
+	Audit    *audit.Log
+	Config   *config.Reloader
+}
+
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/"), "/")
+
+	switch path {
+	case "fsck":
+		h.Auth.RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet:
+				h.fsck(w, r, false)
+			case http.MethodPost:
+				h.fsck(w, r, r.URL.Query().Get("repair") == "true")
+			default:
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			}
+		})(w, r)
+	case "export":
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.Auth.RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+			h.export(w, r)
+		})(w, r)
+	case "import":
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.Auth.RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+			h.importArchive(w, r)
+		})(w, r)
+	case "config":
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.Auth.RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+			h.config(w, r)
+		})(w, r)
+	case "bans":
+		h.Auth.RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet:
+				h.listBans(w, r)
+			case http.MethodDelete:
+				h.clearBans(w, r)
+			default:
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			}
+		})(w, r)
+	default:
+		if ip, ok := strings.CutPrefix(path, "bans/"); ok && ip != "" && !strings.Contains(ip, "/") {
+			if r.Method != http.MethodDelete {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			h.Auth.RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+				h.clearBan(w, r, ip)
+			})(w, r)
+			return
+		}
+		http.Error(w, "invalid admin route", http.StatusNotFound)
+	}
+}
+
+// fsck godoc
+// @Summary      Check storage integrity
+// @Description  Cross-check database rows against stored binaries. GET only reports; POST with repair=true also deletes rows without binaries and removes orphaned and temporary files. Size and checksum mismatches are never repaired.
+// @Tags         admin
+// @Produce      json
+// @Param        repair  query     bool  false  "Repair issues (POST only)"
+// @Success      200     {object}  firmware.FsckReport
+// @Failure      401     {string}  string  "Unauthorized"
+// @Failure      500     {string}  string  "Integrity check failed"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /admin/fsck [get]
+// @Router       /admin/fsck [post]
+func (h *AdminHandler) fsck(w http.ResponseWriter, r *http.Request, repair bool) {
+	report, err := h.Service.Fsck(r.Context(), repair)
+	if repair {
+		recordAudit(h.Audit, r, audit.Event{Action: audit.ActionStorageRepair,
+			Detail: strconv.Itoa(len(report.Issues)-report.Unrepaired()) + " issues repaired"}, err)
+	}
+	if err != nil {
+		http.Error(w, "integrity check failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if h.Webhooks != nil && len(report.Issues) > 0 {
+		h.Webhooks.Dispatch(r.Context(), "storage.integrity_issues", report)
+	}
+
+	util.WriteJSON(w, report)
+}
+
+// export godoc
+// @Summary      Export registry
+// @Description  Stream a tar archive containing a consistent database snapshot, every firmware binary and a manifest of SHA256 checksums
+// @Tags         admin
+// @Produce      application/gzip
+// @Produce      application/x-tar
+// @Param        gzip  query     bool    false  "Compress the archive (default true)"
+// @Success      200   {file}    binary  "Registry archive"
+// @Failure      401   {string}  string  "Unauthorized"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /admin/export [get]
+func (h *AdminHandler) export(w http.ResponseWriter, r *http.Request) {
+	compress := r.URL.Query().Get("gzip") != "false"
+
+	name := "firmware-registry-" + time.Now().UTC().Format("20060102-150405") + ".tar"
+	contentType := "application/x-tar"
+	if compress {
+		name += ".gz"
+		contentType = "application/gzip"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+
+	// Headers are already sent once streaming starts, so a failure can only
+	// be logged; the client sees a truncated archive.
+	if err := h.Backup.Export(r.Context(), w, compress); err != nil {
+		zerolog.Ctx(r.Context()).Error().Err(err).Msg("Registry export failed")
+	}
+}
+
+// importArchive godoc
+// @Summary      Import registry
+// @Description  Restore an archive produced by the export endpoint (tar or tar.gz request body). Every entry is verified against the manifest before anything is written. New releases are added, identical ones left alone and conflicting ones handled according to the conflict policy. Webhooks are merged by URL.
+// @Tags         admin
+// @Accept       application/gzip
+// @Accept       application/x-tar
+// @Produce      json
+// @Param        conflict  query     string  false  "Conflict policy: skip (default), overwrite or fail"
+// @Success      200       {object}  backup.ImportReport
+// @Failure      400       {string}  string  "Invalid conflict policy"
+// @Failure      401       {string}  string  "Unauthorized"
+// @Failure      422       {string}  string  "Invalid archive or conflicting releases"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /admin/import [post]
+func (h *AdminHandler) importArchive(w http.ResponseWriter, r *http.Request) {
+	policy, ok := backup.ParseConflictPolicy(r.URL.Query().Get("conflict"))
+	if !ok {
+		http.Error(w, "invalid conflict policy", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.Backup.Import(r.Context(), r.Body, policy)
+	recordAudit(h.Audit, r, audit.Event{Action: audit.ActionRegistryImport,
+		Detail: fmt.Sprintf("conflict=%s imported=%d overwritten=%d", policy, len(report.Imported), len(report.Overwritten))}, err)
+	if err != nil {
+		http.Error(w, "import failed: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	util.WriteJSON(w, report)
+}
+
+// config godoc
+// @Summary      Config reload status
+// @Description  Report when the running config was loaded and the outcome of the last reload attempt. The config is reloaded on SIGHUP and when FW_CONFIG_FILE changes; applied without a restart are the admin and device keys, the no-auth and device allowlists, admin and route networks, trusted proxies and the client IP header, RBAC roles, webhook delivery settings and the log level; restartRequired is set when other settings changed and need a restart.
+// @Tags         admin
+// @Produce      json
+// @Success      200  {object}  config.ReloadStatus
+// @Failure      401  {string}  string  "Unauthorized"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /admin/config [get]
+func (h *AdminHandler) config(w http.ResponseWriter, r *http.Request) {
+	util.WriteJSON(w, h.Config.Status())
+}
+
+// listBans godoc
+// @Summary      List banned client IPs
+// @Description  Client IPs refused after repeated authentication failures (see FW_BAN_FAILURES). Bans are held in memory and lifted on restart.
+// @Tags         admin
+// @Produce      json
+// @Success      200  {array}   ratelimit.Ban
+// @Failure      401  {string}  string  "Unauthorized"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /admin/bans [get]
+func (h *AdminHandler) listBans(w http.ResponseWriter, r *http.Request) {
+	bans := []ratelimit.Ban{}
+	if h.Auth.Bans != nil {
+		bans = h.Auth.Bans.List(time.Now())
+	}
+	util.WriteJSON(w, bans)
+}
+
+// clearBans godoc
+// @Summary      Lift all bans
+// @Description  Lift every ban and forget recorded authentication failures
+// @Tags         admin
+// @Success      204
+// @Failure      401  {string}  string  "Unauthorized"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /admin/bans [delete]
+func (h *AdminHandler) clearBans(w http.ResponseWriter, r *http.Request) {
+	n := h.Auth.Bans.ClearAll(time.Now())
+	recordAudit(h.Audit, r, audit.Event{Action: audit.ActionBanClear, Detail: strconv.Itoa(n) + " bans lifted"}, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clearBan godoc
+// @Summary      Lift a ban
+// @Description  Lift the ban on a client IP and forget its authentication failures
+// @Tags         admin
+// @Param        ip   path      string  true  "Client IP"
+// @Success      204
+// @Failure      400  {string}  string  "Invalid IP address"
+// @Failure      401  {string}  string  "Unauthorized"
+// @Failure      404  {string}  string  "IP is not banned"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /admin/bans/{ip} [delete]
+func (h *AdminHandler) clearBan(w http.ResponseWriter, r *http.Request, ip string) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		http.Error(w, "invalid IP address", http.StatusBadRequest)
+		return
+	}
+	ip = parsed.String()
+	if !h.Auth.Bans.Clear(ip, time.Now()) {
+		http.Error(w, "not banned", http.StatusNotFound)
+		return
+	}
+	recordAudit(h.Audit, r, audit.Event{Action: audit.ActionBanClear, Detail: ip}, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
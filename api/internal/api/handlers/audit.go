@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"firmware-registry-api/internal/audit"
+	"firmware-registry-api/internal/auth"
+	"firmware-registry-api/internal/clientip"
+	"firmware-registry-api/internal/logging"
+	"firmware-registry-api/internal/util"
+
+	"github.com/rs/zerolog"
+)
+
+// AuditHandler serves the audit log.
+type AuditHandler struct {
+	Auth       auth.Auth
+	Repo       audit.Repository
+	PublicBase string
+}
+
+func (h *AuditHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/audit"), "/") {
+	case "":
+		h.Auth.RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+			h.list(w, r)
+		})(w, r)
+	case "export":
+		h.Auth.RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+			h.export(w, r)
+		})(w, r)
+	default:
+		http.Error(w, "invalid audit route", http.StatusNotFound)
+	}
+}
+
+// list godoc
+// @Summary      List audit events
+// @Description  Page through audit events, newest first. The total number of matches is returned in X-Total-Count and the next page in a Link header (rel="next").
+// @Tags         audit
+// @Produce      json
+// @Param        actor       query     string  false  "API key name, JWT subject or client IP"
+// @Param        action      query     string  false  "e.g. firmware.upload, firmware.delete, webhook.create, auth.failure"
+// @Param        type        query     string  false  "Target firmware type"
+// @Param        version     query     string  false  "Target firmware version"
+// @Param        webhook_id  query     int     false  "Target webhook ID"
+// @Param        outcome     query     string  false  "success or failure"
+// @Param        since       query     string  false  "Inclusive start, RFC3339"
+// @Param        until       query     string  false  "Inclusive end, RFC3339"
+// @Param        limit       query     int     false  "Page size (default 100, max 1000)"
+// @Param        before      query     int     false  "Only events with a smaller ID (cursor from the Link header)"
+// @Success      200         {array}   audit.Event
+// @Header       200         {integer} X-Total-Count  "Events matching the filters"
+// @Failure      400         {string}  string  "Invalid filter"
+// @Failure      401         {string}  string  "Unauthorized"
+// @Failure      500         {string}  string  "Database error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /audit [get]
+func (h *AuditHandler) list(w http.ResponseWriter, r *http.Request) {
+	f, err := parseAuditFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events, total, err := h.Repo.Query(r.Context(), f)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	limit := f.Limit
+	if limit <= 0 {
+		limit = audit.DefaultPageSize
+	}
+	if len(events) == min(limit, audit.MaxPageSize) {
+		next := *r.URL
+		query := next.Query()
+		query.Set("before", strconv.FormatInt(events[len(events)-1].ID, 10))
+		next.RawQuery = query.Encode()
+		w.Header().Set("Link", "<"+strings.TrimRight(h.PublicBase, "/")+next.RequestURI()+`>; rel="next"`)
+	}
+
+	util.WriteJSON(w, events)
+}
+
+// export godoc
+// @Summary      Export audit events
+// @Description  Stream every matching audit event as JSON Lines, oldest first
+// @Tags         audit
+// @Produce      application/x-ndjson
+// @Param        actor       query     string  false  "API key name, JWT subject or client IP"
+// @Param        action      query     string  false  "Action"
+// @Param        type        query     string  false  "Target firmware type"
+// @Param        version     query     string  false  "Target firmware version"
+// @Param        webhook_id  query     int     false  "Target webhook ID"
+// @Param        outcome     query     string  false  "success or failure"
+// @Param        since       query     string  false  "Inclusive start, RFC3339"
+// @Param        until       query     string  false  "Inclusive end, RFC3339"
+// @Success      200         {file}    binary  "One audit.Event per line"
+// @Failure      400         {string}  string  "Invalid filter"
+// @Failure      401         {string}  string  "Unauthorized"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /audit/export [get]
+func (h *AuditHandler) export(w http.ResponseWriter, r *http.Request) {
+	f, err := parseAuditFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	name := "audit-" + time.Now().UTC().Format("20060102-150405") + ".jsonl"
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+
+	enc := json.NewEncoder(w)
+	if err := h.Repo.Each(r.Context(), f, func(e audit.Event) error {
+		return enc.Encode(e)
+	}); err != nil {
+		// Streaming has started, so the client sees a truncated file.
+		zerolog.Ctx(r.Context()).Error().Err(err).Msg("Audit export failed")
+	}
+}
+
+// parseAuditFilter maps query parameters onto an audit.Filter.
+func parseAuditFilter(r *http.Request) (audit.Filter, error) {
+	v := r.URL.Query()
+	f := audit.Filter{
+		Actor:         v.Get("actor"),
+		Action:        audit.Action(v.Get("action")),
+		TargetType:    v.Get("type"),
+		TargetVersion: v.Get("version"),
+		Outcome:       audit.Outcome(v.Get("outcome")),
+	}
+
+	for _, p := range []struct {
+		name string
+		dst  *int64
+	}{{"webhook_id", &f.WebhookID}, {"before", &f.BeforeID}} {
+		if s := v.Get(p.name); s != "" {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || n <= 0 {
+				return f, errors.New("invalid " + p.name)
+			}
+			*p.dst = n
+		}
+	}
+	if s := v.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return f, errors.New("invalid limit")
+		}
+		f.Limit = n
+	}
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &f.Since}, {"until", &f.Until}} {
+		if s := v.Get(p.name); s != "" {
+			ts, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return f, errors.New("invalid " + p.name + " (want RFC3339)")
+			}
+			*p.dst = ts
+		}
+	}
+	return f, nil
+}
+
+// recordAudit writes e to the audit log on behalf of the request's
+// principal. A non-nil err marks the action as failed and is appended to
+// the detail.
+func recordAudit(l *audit.Log, r *http.Request, e audit.Event, err error) {
+	if p, ok := auth.PrincipalFrom(r.Context()); ok {
+		e.Actor, e.ActorType, e.ActorEmail, e.Role = p.Subject, p.Method, p.Email, p.Role
+	}
+	e.ClientIP = clientip.String(r)
+	e.RequestID = logging.RequestID(r.Context())
+	e.Outcome = audit.OutcomeSuccess
+	if err != nil {
+		e.Outcome = audit.OutcomeFailure
+		if e.Detail != "" {
+			e.Detail += ": "
+		}
+		e.Detail += err.Error()
+	}
+	l.Record(r.Context(), e)
+}
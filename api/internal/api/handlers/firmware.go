@@ -1,18 +1,30 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"os"
-	"sort"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
+	"firmware-registry-api/internal/audit"
 	"firmware-registry-api/internal/auth"
+	"firmware-registry-api/internal/clientip"
 	"firmware-registry-api/internal/firmware"
+	"firmware-registry-api/internal/metrics"
+	"firmware-registry-api/internal/presign"
+	"firmware-registry-api/internal/rbac"
+	"firmware-registry-api/internal/stats"
+	"firmware-registry-api/internal/tracing"
 	"firmware-registry-api/internal/util"
 	"firmware-registry-api/internal/webhook"
+
+	"github.com/rs/zerolog"
 )
 
 // FirmwareHandler translates HTTP to firmware service calls.
@@ -20,7 +32,10 @@ type FirmwareHandler struct {
 	Auth     auth.Auth
 	Service  *firmware.Service
 	Webhooks *webhook.Service
+	Stats    *stats.Recorder
+	Audit    *audit.Log
 	MaxBytes int64
+	Presign  *presign.Service // pre-signed download URLs; nil disables them
 }
 
 func (h *FirmwareHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -32,18 +47,30 @@ func (h *FirmwareHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	t := parts[0]
 
+	// GET /api/firmware/by-sha256/{hash}
+	if t == "by-sha256" {
+		if len(parts) != 2 || r.Method != http.MethodGet {
+			http.Error(w, "invalid firmware route", http.StatusNotFound)
+			return
+		}
+		h.Auth.RequireDevice(func(w http.ResponseWriter, r *http.Request) {
+			h.bySHA256(w, r, parts[1])
+		})(w, r)
+		return
+	}
+
 	// GET /api/firmware/{type}
 	if len(parts) == 1 && r.Method == http.MethodGet {
-		h.Auth.RequireDevice(func(w http.ResponseWriter, r *http.Request) {
-			h.list(w, t)
+		h.Auth.RequirePermission(rbac.Read, t, func(w http.ResponseWriter, r *http.Request) {
+			h.list(w, r, t)
 		})(w, r)
 		return
 	}
 
 	// GET /api/firmware/{type}/latest
 	if len(parts) == 2 && parts[1] == "latest" && r.Method == http.MethodGet {
-		h.Auth.RequireDevice(func(w http.ResponseWriter, r *http.Request) {
-			h.latest(w, t)
+		h.Auth.RequirePermission(rbac.Read, t, func(w http.ResponseWriter, r *http.Request) {
+			h.latest(w, r, t)
 		})(w, r)
 		return
 	}
@@ -53,16 +80,20 @@ func (h *FirmwareHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		v := parts[1]
 		switch r.Method {
 		case http.MethodPost:
-			h.Auth.RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+			h.Auth.RequirePermission(rbac.Upload, t, func(w http.ResponseWriter, r *http.Request) {
 				h.upload(w, r, t, v)
 			})(w, r)
 		case http.MethodGet:
-			h.Auth.RequireDevice(func(w http.ResponseWriter, r *http.Request) {
-				h.download(w, t, v)
+			if r.URL.Query().Has(presign.ParamSignature) {
+				h.presignedDownload(w, r, t, v)
+				return
+			}
+			h.Auth.RequirePermission(rbac.Read, t, func(w http.ResponseWriter, r *http.Request) {
+				h.download(w, r, t, v)
 			})(w, r)
 		case http.MethodDelete:
-			h.Auth.RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
-				h.delete(w, t, v)
+			h.Auth.RequirePermission(rbac.Delete, t, func(w http.ResponseWriter, r *http.Request) {
+				h.delete(w, r, t, v)
 			})(w, r)
 		default:
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -70,6 +101,18 @@ func (h *FirmwareHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// POST /api/firmware/{type}/{version}/presign
+	if len(parts) == 3 && parts[2] == "presign" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.Auth.RequirePermission(rbac.Read, t, func(w http.ResponseWriter, r *http.Request) {
+			h.presignURL(w, r, t, parts[1])
+		})(w, r)
+		return
+	}
+
 	http.Error(w, "invalid firmware route", http.StatusNotFound)
 }
 
@@ -79,21 +122,30 @@ func (h *FirmwareHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // @Tags         firmware
 // @Accept       multipart/form-data
 // @Produce      json
-// @Param        type     path      string  true  "Firmware type (e.g., esp32-main)"
-// @Param        version  path      string  true  "Semantic version (e.g., 1.2.3)"
-// @Param        file     formData  file    true  "Firmware binary file"
+// @Param        type     path      string  true   "Firmware type (e.g., esp32-main)"
+// @Param        version  path      string  true   "Semantic version (e.g., 1.2.3)"
+// @Param        file     formData  file    true   "Firmware binary file"
+// @Param        channel  formData  string  false  "Release channel (default stable)"
+// @Param        labels   formData  string  false  "Comma-separated release labels"
 // @Success      200      {object}  firmware.FirmwareDTO
-// @Failure      400      {string}  string  "Invalid multipart or missing file"
+// @Failure      400      {string}  string  "Invalid multipart, missing file or invalid channel/labels"
 // @Failure      401      {string}  string  "Unauthorized"
+// @Failure      403      {string}  string  "Missing permission for this firmware type"
 // @Failure      500      {string}  string  "Save failed"
 // @Security     ApiKeyAuth
 // @Security     BearerAuth
+// @Security     ManagedKeyAuth
 // @Router       /firmware/{type}/{version} [post]
 func (h *FirmwareHandler) upload(w http.ResponseWriter, r *http.Request, t, v string) {
 	maxN := h.MaxBytes
 	r.Body = http.MaxBytesReader(w, r.Body, maxN)
 
-	if err := r.ParseMultipartForm(maxN); err != nil {
+	// Receiving the body is usually the slowest part of an upload, so it
+	// gets its own span.
+	_, span := tracing.Start(r.Context(), "firmware.receive")
+	err := r.ParseMultipartForm(maxN)
+	span.End()
+	if err != nil {
 		http.Error(w, "invalid multipart", http.StatusBadRequest)
 		return
 	}
@@ -107,16 +159,29 @@ func (h *FirmwareHandler) upload(w http.ResponseWriter, r *http.Request, t, v st
 		_ = file.Close()
 	}(file)
 
-	rec, err := h.Service.SaveFirmware(t, v, header.Filename, file)
+	info := firmware.ReleaseInfo{Channel: strings.TrimSpace(r.FormValue("channel"))}
+	for _, l := range strings.Split(r.FormValue("labels"), ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			info.Labels = append(info.Labels, l)
+		}
+	}
+
+	rec, err := h.Service.SaveFirmware(r.Context(), t, v, header.Filename, info, file)
+	recordAudit(h.Audit, r, audit.Event{Action: audit.ActionFirmwareUpload, TargetType: t, TargetVersion: v}, err)
+	if errors.Is(err, firmware.ErrInvalidRelease) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, "save failed: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	metrics.AddUploadBytes(t, rec.SizeBytes)
 	dto := rec.ToDTO(h.Service.DownloadURL(t, v))
 
 	if h.Webhooks != nil {
-		h.Webhooks.Dispatch("firmware.uploaded", dto)
+		h.Webhooks.Dispatch(r.Context(), "firmware.uploaded", dto)
 	}
 
 	util.WriteJSON(w, dto)
@@ -127,25 +192,32 @@ func (h *FirmwareHandler) upload(w http.ResponseWriter, r *http.Request, t, v st
 // @Description  Download the firmware binary for a specific type and version
 // @Tags         firmware
 // @Produce      octet-stream
-// @Param        type     path      string  true  "Firmware type (e.g., esp32-main)"
-// @Param        version  path      string  true  "Semantic version (e.g., 1.2.3)"
+// @Param        type         path      string  true   "Firmware type (e.g., esp32-main)"
+// @Param        version      path      string  true   "Semantic version (e.g., 1.2.3)"
+// @Param        X-Device-ID  header    string  false  "Device identity for download statistics"
+// @Param        device_id    query     string  false  "Device identity for clients that cannot set headers"
+// @Param        expires      query     int     false  "Pre-signed URLs: expiry (Unix time)"
+// @Param        nonce        query     string  false  "Pre-signed URLs: single-use nonce"
+// @Param        ip           query     string  false  "Pre-signed URLs: bound client IP"
+// @Param        sig          query     string  false  "Pre-signed URLs: signature; no other authentication is needed"
 // @Success      200      {file}    binary  "Firmware binary file"
 // @Header       200      {string}  X-Firmware-Sha256   "SHA256 checksum of the firmware"
 // @Header       200      {string}  X-Firmware-Version  "Firmware version"
 // @Failure      404      {string}  string  "Firmware not found"
 // @Failure      401      {string}  string  "Unauthorized"
+// @Failure      403      {string}  string  "Missing read permission for this firmware type, or invalid, expired or used pre-signed URL"
 // @Security     DeviceKeyAuth
 // @Security     BearerAuth
+// @Security     ManagedKeyAuth
 // @Router       /firmware/{type}/{version} [get]
-func (h *FirmwareHandler) download(w http.ResponseWriter, t, v string) {
-	rec, err := h.Service.Repo.Get(t, v)
+func (h *FirmwareHandler) download(w http.ResponseWriter, r *http.Request, t, v string) {
+	rec, err := h.Service.Repo.Get(r.Context(), t, v)
 	if err != nil {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
 
-	path := h.Service.DownloadPath(t, v)
-	f, err := os.Open(path)
+	f, err := os.Open(h.Service.DownloadPath(t, v))
 	if err != nil {
 		http.Error(w, "missing binary", http.StatusNotFound)
 		return
@@ -154,12 +226,175 @@ func (h *FirmwareHandler) download(w http.ResponseWriter, t, v string) {
 		_ = f.Close()
 	}(f)
 
+	h.serve(w, r, rec, f)
+}
+
+// serve streams the binary of rec from f and records the download.
+func (h *FirmwareHandler) serve(w http.ResponseWriter, r *http.Request, rec firmware.Firmware, f *os.File) {
 	w.Header().Set("Content-Type", "application/octet-stream")
 	w.Header().Set("Content-Length", strconv.FormatInt(rec.SizeBytes, 10))
 	w.Header().Set("X-Firmware-Sha256", rec.SHA256)
 	w.Header().Set("X-Firmware-Version", rec.Version)
 
-	_, _ = io.Copy(w, f)
+	n, err := io.Copy(w, f)
+	metrics.AddDownloadBytes(rec.Type, n)
+
+	if h.Stats != nil {
+		deviceID := r.Header.Get("X-Device-ID")
+		if deviceID == "" {
+			deviceID = r.URL.Query().Get("device_id")
+		}
+		h.Stats.Record(stats.DownloadEvent{
+			Type:      rec.Type,
+			Version:   rec.Version,
+			DeviceID:  deviceID,
+			ClientIP:  clientip.String(r),
+			Bytes:     n,
+			Completed: err == nil && n == rec.SizeBytes,
+		})
+	}
+}
+
+// presignedDownload serves a download authorized by a pre-signed URL
+// instead of credentials. A single-use link is only used up once the
+// release and its binary are known to exist.
+func (h *FirmwareHandler) presignedDownload(w http.ResponseWriter, r *http.Request, t, v string) {
+	if h.Presign == nil {
+		http.Error(w, "pre-signed URLs are disabled", http.StatusForbidden)
+		return
+	}
+	link, err := h.Presign.Verify(t, v, r.URL.Query(), clientip.IP(r), time.Now())
+	if err != nil {
+		rejectPresigned(w, r, err)
+		return
+	}
+
+	rec, err := h.Service.Repo.Get(r.Context(), t, v)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	path := h.Service.DownloadPath(t, v)
+	if _, err := os.Stat(path); err != nil {
+		http.Error(w, "missing binary", http.StatusNotFound)
+		return
+	}
+
+	if err := h.Presign.Use(r.Context(), link); err != nil {
+		rejectPresigned(w, r, err)
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if err := h.Presign.Release(r.Context(), link); err != nil {
+			zerolog.Ctx(r.Context()).Error().Err(err).Msg("Failed to release pre-signed link")
+		}
+		http.Error(w, "missing binary", http.StatusNotFound)
+		return
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	subject := "presigned"
+	if link.Nonce != "" {
+		subject += ":" + link.Nonce
+	}
+	p := auth.Principal{
+		Role:        "device",
+		Method:      "presigned",
+		Subject:     subject,
+		Permissions: rbac.Permissions{Grants: []rbac.Grant{{Pattern: t, Action: rbac.Read}}},
+	}
+	h.serve(w, r.WithContext(auth.WithPrincipal(r.Context(), p)), rec, f)
+}
+
+// rejectPresigned answers a pre-signed request that failed verification or
+// whose single-use link was already used.
+func rejectPresigned(w http.ResponseWriter, r *http.Request, err error) {
+	metrics.AuthFailed("device", "presigned")
+	zerolog.Ctx(r.Context()).Warn().
+		Err(err).
+		Str("path", r.URL.Path).
+		Str("client_ip", clientip.String(r)).
+		Msg("Pre-signed download rejected")
+	switch {
+	case errors.Is(err, presign.ErrInvalid), errors.Is(err, presign.ErrExpired),
+		errors.Is(err, presign.ErrUsed), errors.Is(err, presign.ErrWrongIP):
+		http.Error(w, "forbidden ("+err.Error()+")", http.StatusForbidden)
+	default:
+		http.Error(w, "db error", http.StatusInternalServerError)
+	}
+}
+
+// presignURL godoc
+// @Summary      Pre-sign a download URL
+// @Description  Mint an HMAC-signed URL that downloads this release without credentials until it expires, for bootloaders and tools that cannot set headers. It can be limited to one download and to one client IP. Requires read permission on the type and FW_PRESIGN_SECRET.
+// @Tags         firmware
+// @Accept       json
+// @Produce      json
+// @Param        type     path      string                 true   "Firmware type (e.g., esp32-main)"
+// @Param        version  path      string                 true   "Semantic version (e.g., 1.2.3)"
+// @Param        request  body      presign.CreateRequest  false  "Lifetime, single use and IP binding"
+// @Success      200      {object}  presign.URL
+// @Failure      400      {string}  string  "Invalid request"
+// @Failure      401      {string}  string  "Unauthorized"
+// @Failure      403      {string}  string  "Missing read permission for this firmware type"
+// @Failure      404      {string}  string  "Firmware not found or pre-signed URLs disabled"
+// @Security     DeviceKeyAuth
+// @Security     BearerAuth
+// @Security     ManagedKeyAuth
+// @Router       /firmware/{type}/{version}/presign [post]
+func (h *FirmwareHandler) presignURL(w http.ResponseWriter, r *http.Request, t, v string) {
+	if h.Presign == nil {
+		http.Error(w, "pre-signed URLs are disabled", http.StatusNotFound)
+		return
+	}
+	var req presign.CreateRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if _, err := h.Service.Repo.Get(r.Context(), t, v); err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	link, err := h.Presign.Create(req, t, v, time.Now())
+	if err != nil {
+		if errors.Is(err, presign.ErrInvalidRequest) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "failed to sign URL", http.StatusInternalServerError)
+		return
+	}
+	util.WriteJSON(w, presign.URL{
+		URL:       h.signedURL(link),
+		ExpiresAt: link.Expires.UTC(),
+		SingleUse: link.Nonce != "",
+		IP:        link.IP,
+	})
+}
+
+// signedURL returns the URL of link: absolute if a public base URL is
+// configured, otherwise a path.
+func (h *FirmwareHandler) signedURL(link presign.Link) string {
+	u := h.Service.DownloadURL(link.Type, link.Version)
+	if u == "" {
+		u = "/api/firmware/" + link.Type + "/" + link.Version
+	}
+	return u + "?" + h.Presign.Query(link).Encode()
+}
+
+// listingURL is the downloadUrl of releases in listings: pre-signed with an
+// expiry only if configured, so the JSON is usable without credentials.
+func (h *FirmwareHandler) listingURL(t, v string) string {
+	u := h.Service.DownloadURL(t, v)
+	if u == "" || h.Presign == nil || !h.Presign.InListings {
+		return u
+	}
+	return h.signedURL(h.Presign.Listing(t, v, time.Now()))
 }
 
 // delete godoc
@@ -172,23 +407,30 @@ func (h *FirmwareHandler) download(w http.ResponseWriter, t, v string) {
 // @Success      200      {object}  map[string]bool  "Deletion confirmation"
 // @Failure      404      {string}  string  "Firmware not found"
 // @Failure      401      {string}  string  "Unauthorized"
+// @Failure      403      {string}  string  "Missing permission for this firmware type"
+// @Failure      500      {string}  string  "Delete failed"
 // @Security     ApiKeyAuth
 // @Security     BearerAuth
+// @Security     ManagedKeyAuth
 // @Router       /firmware/{type}/{version} [delete]
-func (h *FirmwareHandler) delete(w http.ResponseWriter, t, v string) {
-	rec, err := h.Service.Repo.Get(t, v)
+func (h *FirmwareHandler) delete(w http.ResponseWriter, r *http.Request, t, v string) {
+	rec, err := h.Service.Repo.Get(r.Context(), t, v)
 	if err != nil {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
 
-	_ = os.RemoveAll(h.Service.Storage.Dir(t, v))
-	_ = h.Service.Repo.Delete(t, v)
+	err = h.Service.DeleteFirmware(r.Context(), t, v)
+	recordAudit(h.Audit, r, audit.Event{Action: audit.ActionFirmwareDelete, TargetType: t, TargetVersion: v}, err)
+	if err != nil {
+		http.Error(w, "delete failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	dto := rec.ToDTO(h.Service.DownloadURL(t, v))
 
 	if h.Webhooks != nil {
-		h.Webhooks.Dispatch("firmware.deleted", dto)
+		h.Webhooks.Dispatch(r.Context(), "firmware.deleted", dto)
 	}
 
 	util.WriteJSON(w, map[string]any{"deleted": true})
@@ -196,32 +438,57 @@ func (h *FirmwareHandler) delete(w http.ResponseWriter, t, v string) {
 
 // list godoc
 // @Summary      List firmware versions
-// @Description  Get all firmware versions for a specific type, sorted by semantic version (newest first)
+// @Description  Page through the firmware versions of a type. Results are sorted newest semantic version first unless sort says otherwise. The total number of matches is returned in X-Total-Count and the next page in a Link header (rel="next").
 // @Tags         firmware
 // @Produce      json
-// @Param        type  path      string  true  "Firmware type (e.g., esp32-main)"
-// @Success      200   {array}   firmware.FirmwareDTO
-// @Failure      401   {string}  string  "Unauthorized"
-// @Failure      500   {string}  string  "Database error"
+// @Param        type         path      string  true   "Firmware type (e.g., esp32-main)"
+// @Param        limit        query     int     false  "Page size (default 100, max 1000)"
+// @Param        cursor       query     string  false  "Opaque cursor from the previous page's Link header"
+// @Param        sort         query     string  false  "version_desc (default), version_asc, created_desc or created_asc"
+// @Param        min_version  query     string  false  "Lowest semantic version to include"
+// @Param        max_version  query     string  false  "Highest semantic version to include"
+// @Param        since        query     string  false  "Only uploads at or after this RFC3339 time"
+// @Param        until        query     string  false  "Only uploads at or before this RFC3339 time"
+// @Param        channel      query     string  false  "Only releases in this channel"
+// @Param        label        query     string  false  "Only releases carrying this label"
+// @Success      200          {array}   firmware.FirmwareDTO
+// @Header       200          {integer} X-Total-Count  "Number of releases matching the filters"
+// @Header       200          {string}  Link           "URL of the next page"
+// @Failure      400          {string}  string  "Invalid query parameter"
+// @Failure      401          {string}  string  "Unauthorized"
+// @Failure      403          {string}  string  "Missing read permission for this firmware type"
+// @Failure      500          {string}  string  "Database error"
 // @Security     DeviceKeyAuth
 // @Security     BearerAuth
+// @Security     ManagedKeyAuth
 // @Router       /firmware/{type} [get]
-func (h *FirmwareHandler) list(w http.ResponseWriter, t string) {
-	list, err := h.Service.Repo.List(t)
+func (h *FirmwareHandler) list(w http.ResponseWriter, r *http.Request, t string) {
+	q, err := parseListQuery(r, t)
 	if err != nil {
-		http.Error(w, "db error", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	sort.Slice(list, func(i, j int) bool {
-		return util.CompareSemver(list[i].Version, list[j].Version) > 0
-	})
+	page, err := h.Service.Repo.Query(r.Context(), q)
+	if errors.Is(err, firmware.ErrInvalidQuery) {
+		http.Error(w, "invalid cursor or version filter", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
 
-	out := make([]firmware.FirmwareDTO, 0, len(list))
-	for _, f := range list {
-		out = append(out, f.ToDTO(h.Service.DownloadURL(f.Type, f.Version)))
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		next := *r.URL
+		query := next.Query()
+		query.Set("cursor", page.NextCursor)
+		next.RawQuery = query.Encode()
+		w.Header().Set("Link", "<"+strings.TrimRight(h.Service.PublicBase, "/")+next.RequestURI()+`>; rel="next"`)
 	}
-	util.WriteJSON(w, out)
+
+	h.writeList(w, page.Items)
 }
 
 // latest godoc
@@ -229,26 +496,156 @@ func (h *FirmwareHandler) list(w http.ResponseWriter, t string) {
 // @Description  Get the latest firmware version for a specific type based on semantic versioning
 // @Tags         firmware
 // @Produce      json
-// @Param        type  path      string  true  "Firmware type (e.g., esp32-main)"
-// @Success      200   {object}  firmware.FirmwareDTO
-// @Failure      404   {string}  string  "No firmware found"
-// @Failure      401   {string}  string  "Unauthorized"
+// @Param        type     path      string  true   "Firmware type (e.g., esp32-main)"
+// @Param        channel  query     string  false  "Only consider releases in this channel"
+// @Success      200      {object}  firmware.FirmwareDTO
+// @Failure      404      {string}  string  "No firmware found"
+// @Failure      401      {string}  string  "Unauthorized"
+// @Failure      403      {string}  string  "Missing read permission for this firmware type"
 // @Security     DeviceKeyAuth
 // @Security     BearerAuth
+// @Security     ManagedKeyAuth
 // @Router       /firmware/{type}/latest [get]
-func (h *FirmwareHandler) latest(w http.ResponseWriter, t string) {
-	list, err := h.Service.Repo.List(t)
-	if err != nil || len(list) == 0 {
+func (h *FirmwareHandler) latest(w http.ResponseWriter, r *http.Request, t string) {
+	f, err := h.Service.Repo.Latest(r.Context(), t, r.URL.Query().Get("channel"))
+	if err != nil {
 		http.Error(w, "no firmware", http.StatusNotFound)
 		return
 	}
 
-	sort.Slice(list, func(i, j int) bool {
-		return util.CompareSemver(list[i].Version, list[j].Version) > 0
-	})
+	util.WriteJSON(w, f.ToDTO(h.listingURL(f.Type, f.Version)))
+}
+
+// bySHA256 godoc
+// @Summary      Find firmware by checksum
+// @Description  Return every type/version whose binary has the given SHA256
+// @Tags         firmware
+// @Produce      json
+// @Param        hash  path      string  true  "SHA256 checksum (64 hex characters)"
+// @Success      200   {array}   firmware.FirmwareDTO
+// @Failure      400   {string}  string  "Invalid checksum"
+// @Failure      401   {string}  string  "Unauthorized"
+// @Failure      500   {string}  string  "Database error"
+// @Security     DeviceKeyAuth
+// @Security     BearerAuth
+// @Security     ManagedKeyAuth
+// @Router       /firmware/by-sha256/{hash} [get]
+func (h *FirmwareHandler) bySHA256(w http.ResponseWriter, r *http.Request, sum string) {
+	if !sha256Pattern.MatchString(sum) {
+		http.Error(w, "invalid sha256", http.StatusBadRequest)
+		return
+	}
+
+	list, err := h.Service.Repo.FindBySHA256(r.Context(), sum)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	h.writeList(w, readable(r, list))
+}
+
+// Search godoc
+// @Summary      Search firmware
+// @Description  Search all types for releases whose type, version, filename, channel, labels or checksum contain every whitespace-separated word of the query as a case-insensitive substring
+// @Tags         firmware
+// @Produce      json
+// @Param        q      query     string  true   "Search text (e.g., esp32 1.2)"
+// @Param        limit  query     int     false  "Maximum results (default 50, max 500)"
+// @Success      200    {array}   firmware.FirmwareDTO
+// @Failure      400    {string}  string  "Missing query"
+// @Failure      401    {string}  string  "Unauthorized"
+// @Failure      500    {string}  string  "Database error"
+// @Security     DeviceKeyAuth
+// @Security     BearerAuth
+// @Security     ManagedKeyAuth
+// @Router       /search [get]
+func (h *FirmwareHandler) Search(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.Auth.RequireDevice(func(w http.ResponseWriter, r *http.Request) {
+		text := strings.TrimSpace(r.URL.Query().Get("q"))
+		if text == "" {
+			http.Error(w, "missing q", http.StatusBadRequest)
+			return
+		}
+		limit := 50
+		if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+			limit = min(n, 500)
+		}
+
+		p, _ := auth.PrincipalFrom(r.Context())
+		list, err := h.Service.Repo.Search(r.Context(), text, limit, func(t string) bool {
+			return p.Permissions.Allows(rbac.Read, t)
+		})
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		h.writeList(w, list)
+	})(w, r)
+}
 
-	f := list[0]
-	util.WriteJSON(w, f.ToDTO(h.Service.DownloadURL(f.Type, f.Version)))
+func (h *FirmwareHandler) writeList(w http.ResponseWriter, list []firmware.Firmware) {
+	out := make([]firmware.FirmwareDTO, 0, len(list))
+	for _, f := range list {
+		out = append(out, f.ToDTO(h.listingURL(f.Type, f.Version)))
+	}
+	util.WriteJSON(w, out)
+}
+
+// readable drops releases of types the caller may not read, for endpoints
+// that span types.
+func readable(r *http.Request, list []firmware.Firmware) []firmware.Firmware {
+	p, _ := auth.PrincipalFrom(r.Context())
+	out := list[:0]
+	for _, f := range list {
+		if p.Permissions.Allows(rbac.Read, f.Type) {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+var sha256Pattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// parseListQuery maps query parameters onto a firmware.ListQuery.
+func parseListQuery(r *http.Request, t string) (firmware.ListQuery, error) {
+	v := r.URL.Query()
+	q := firmware.ListQuery{
+		Type:       t,
+		MinVersion: v.Get("min_version"),
+		MaxVersion: v.Get("max_version"),
+		Channel:    v.Get("channel"),
+		Label:      v.Get("label"),
+		Cursor:     v.Get("cursor"),
+	}
+
+	var ok bool
+	if q.Sort, ok = firmware.ParseSortOrder(v.Get("sort")); !ok {
+		return q, errors.New("invalid sort")
+	}
+	if s := v.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return q, errors.New("invalid limit")
+		}
+		q.Limit = n
+	}
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &q.Since}, {"until", &q.Until}} {
+		if s := v.Get(p.name); s != "" {
+			ts, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return q, errors.New("invalid " + p.name + " (want RFC3339)")
+			}
+			*p.dst = ts
+		}
+	}
+	return q, nil
 }
 
 func filterEmpty(in []string) []string {
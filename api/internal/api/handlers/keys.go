@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"firmware-registry-api/internal/apikey"
+	"firmware-registry-api/internal/audit"
+	"firmware-registry-api/internal/auth"
+	"firmware-registry-api/internal/util"
+)
+
+// KeysHandler manages API keys. Only admins (the config admin key, OIDC
+// admins, allowlisted clients) can manage keys; managed keys cannot.
+type KeysHandler struct {
+	Auth  auth.Auth
+	Keys  *apikey.Service
+	Audit *audit.Log
+}
+
+func (h *KeysHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/keys" {
+		switch r.Method {
+		case http.MethodGet:
+			h.Auth.RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+				h.list(w, r)
+			})(w, r)
+		case http.MethodPost:
+			h.Auth.RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+				h.create(w, r)
+			})(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	// /api/keys/{id}
+	id, _ := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/keys/"), 10, 64)
+	if id <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.Auth.RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		h.revoke(w, r, id)
+	})(w, r)
+}
+
+// list godoc
+// @Summary      List API keys
+// @Description  List managed API keys. Secrets are never returned; prefix identifies a key.
+// @Tags         keys
+// @Produce      json
+// @Param        includeRevoked  query     bool  false  "Include revoked keys"
+// @Success      200             {array}   apikey.Key
+// @Failure      401             {string}  string  "Unauthorized"
+// @Failure      500             {string}  string  "Database error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /keys [get]
+func (h *KeysHandler) list(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.Keys.List(r.Context(), r.URL.Query().Get("includeRevoked") == "true")
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	util.WriteJSON(w, keys)
+}
+
+// create godoc
+// @Summary      Create API key
+// @Description  Create a named API key with scopes and/or RBAC roles and an optional expiry. A scope is a grant such as esp32-*:upload, or a bare action (upload, delete, read, webhooks:manage) for all firmware types. The secret is returned only in this response; store it immediately.
+// @Tags         keys
+// @Accept       json
+// @Produce      json
+// @Param        key  body      apikey.CreateRequest  true  "Key name, scopes, roles and expiry"
+// @Success      201  {object}  apikey.CreatedKey
+// @Failure      400  {string}  string  "Invalid name, scopes, roles or expiry"
+// @Failure      401  {string}  string  "Unauthorized"
+// @Failure      409  {string}  string  "An active key with this name exists"
+// @Failure      500  {string}  string  "Database error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /keys [post]
+func (h *KeysHandler) create(w http.ResponseWriter, r *http.Request) {
+	var req apikey.CreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+
+	var createdBy string
+	if p, ok := auth.PrincipalFrom(r.Context()); ok {
+		createdBy = p.String()
+	}
+	created, err := h.Keys.Create(r.Context(), req, h.Auth.Settings.Load().Roles, createdBy)
+	recordAudit(h.Audit, r, audit.Event{Action: audit.ActionAPIKeyCreate,
+		Detail: fmt.Sprintf("%s scopes=%s roles=%s", req.Name, strings.Join(req.Scopes, ","), strings.Join(req.Roles, ","))}, err)
+	switch {
+	case errors.Is(err, apikey.ErrInvalidRequest):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, apikey.ErrNameInUse):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	// The secret is in this response only; keep it out of caches.
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	util.WriteJSON(w, created)
+}
+
+// revoke godoc
+// @Summary      Revoke API key
+// @Description  Revoke a managed API key immediately. The key stays listed with includeRevoked=true.
+// @Tags         keys
+// @Param        id   path      int     true  "Key ID"
+// @Success      204  "Revoked"
+// @Failure      400  {string}  string  "Invalid key ID"
+// @Failure      401  {string}  string  "Unauthorized"
+// @Failure      404  {string}  string  "Key not found or already revoked"
+// @Failure      500  {string}  string  "Database error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /keys/{id} [delete]
+func (h *KeysHandler) revoke(w http.ResponseWriter, r *http.Request, id int64) {
+	k, err := h.Keys.Revoke(r.Context(), id)
+	recordAudit(h.Audit, r, audit.Event{Action: audit.ActionAPIKeyRevoke,
+		Detail: fmt.Sprintf("%s (id %d)", k.Name, id)}, err)
+	switch {
+	case errors.Is(err, apikey.ErrNotFound):
+		http.Error(w, "api key not found", http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
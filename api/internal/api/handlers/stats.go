@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"firmware-registry-api/internal/auth"
+	"firmware-registry-api/internal/stats"
+	"firmware-registry-api/internal/util"
+)
+
+// defaultStatsWindow is used when a statistics request has no since.
+const defaultStatsWindow = 30 * 24 * time.Hour
+
+// StatsHandler serves aggregated download statistics.
+type StatsHandler struct {
+	Auth auth.Auth
+	Repo stats.Repository
+}
+
+func (h *StatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/stats/")
+	parts := filterEmpty(strings.Split(path, "/"))
+	if len(parts) == 0 {
+		http.Error(w, "missing firmware type", http.StatusBadRequest)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	t := parts[0]
+
+	switch {
+	case len(parts) == 1:
+		h.Auth.RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+			h.summary(w, r, t)
+		})(w, r)
+	case len(parts) == 2 && parts[1] == "daily":
+		h.Auth.RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+			h.daily(w, r, t)
+		})(w, r)
+	default:
+		http.Error(w, "invalid stats route", http.StatusNotFound)
+	}
+}
+
+// summary godoc
+// @Summary      Download summary
+// @Description  Downloads, completions, abort rate and unique devices for a firmware type, in total and per version
+// @Tags         stats
+// @Produce      json
+// @Param        type   path      string  true   "Firmware type (e.g., esp32-main)"
+// @Param        since  query     string  false  "Start of period, RFC3339 (default 30 days ago)"
+// @Param        until  query     string  false  "End of period, RFC3339 (default now)"
+// @Success      200    {object}  stats.Summary
+// @Failure      400    {string}  string  "Invalid time range"
+// @Failure      401    {string}  string  "Unauthorized"
+// @Failure      500    {string}  string  "Database error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /stats/{type} [get]
+func (h *StatsHandler) summary(w http.ResponseWriter, r *http.Request, t string) {
+	since, until, ok := parseStatsRange(w, r)
+	if !ok {
+		return
+	}
+
+	s, err := h.Repo.Summary(r.Context(), t, since, until)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	util.WriteJSON(w, s)
+}
+
+// daily godoc
+// @Summary      Daily downloads
+// @Description  Downloads per version per UTC day for a firmware type
+// @Tags         stats
+// @Produce      json
+// @Param        type     path      string  true   "Firmware type (e.g., esp32-main)"
+// @Param        version  query     string  false  "Only this version"
+// @Param        since    query     string  false  "Start of period, RFC3339 (default 30 days ago)"
+// @Param        until    query     string  false  "End of period, RFC3339 (default now)"
+// @Success      200      {array}   stats.DailyStats
+// @Failure      400      {string}  string  "Invalid time range"
+// @Failure      401      {string}  string  "Unauthorized"
+// @Failure      500      {string}  string  "Database error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /stats/{type}/daily [get]
+func (h *StatsHandler) daily(w http.ResponseWriter, r *http.Request, t string) {
+	since, until, ok := parseStatsRange(w, r)
+	if !ok {
+		return
+	}
+
+	days, err := h.Repo.Daily(r.Context(), t, r.URL.Query().Get("version"), since, until)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	util.WriteJSON(w, days)
+}
+
+func parseStatsRange(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, bool) {
+	until := time.Now().UTC()
+	if s := r.URL.Query().Get("until"); s != "" {
+		ts, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			http.Error(w, "invalid until (want RFC3339)", http.StatusBadRequest)
+			return time.Time{}, time.Time{}, false
+		}
+		until = ts
+	}
+	since := until.Add(-defaultStatsWindow)
+	if s := r.URL.Query().Get("since"); s != "" {
+		ts, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			http.Error(w, "invalid since (want RFC3339)", http.StatusBadRequest)
+			return time.Time{}, time.Time{}, false
+		}
+		since = ts
+	}
+	if !since.Before(until) {
+		http.Error(w, "since must be before until", http.StatusBadRequest)
+		return time.Time{}, time.Time{}, false
+	}
+	return since, until, true
+}
@@ -2,30 +2,37 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"firmware-registry-api/internal/audit"
 	"firmware-registry-api/internal/auth"
+	"firmware-registry-api/internal/rbac"
 	"firmware-registry-api/internal/util"
 	"firmware-registry-api/internal/webhook"
 )
 
-// WebhookHandler manages webhook CRUD.
+// WebhookHandler manages webhook CRUD and delivery history.
 type WebhookHandler struct {
-	Auth auth.Auth
-	Repo webhook.Repository
+	Auth       auth.Auth
+	Repo       webhook.Repository
+	Service    *webhook.Service
+	Audit      *audit.Log
+	PublicBase string // for absolute Link headers
 }
 
 func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path == "/api/webhooks" {
 		switch r.Method {
 		case http.MethodGet:
-			h.Auth.RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
-				h.list(w)
+			h.Auth.RequirePermission(rbac.WebhooksManage, "", func(w http.ResponseWriter, r *http.Request) {
+				h.list(w, r)
 			})(w, r)
 		case http.MethodPost:
-			h.Auth.RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+			h.Auth.RequirePermission(rbac.WebhooksManage, "", func(w http.ResponseWriter, r *http.Request) {
 				h.create(w, r)
 			})(w, r)
 		default:
@@ -34,22 +41,46 @@ func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// /api/webhooks/{id}
-	idStr := strings.TrimPrefix(r.URL.Path, "/api/webhooks/")
-	id, _ := strconv.ParseInt(idStr, 10, 64)
+	// /api/webhooks/{id}[/deliveries[/{deliveryId}/redeliver]]
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/webhooks/"), "/")
+	id, _ := strconv.ParseInt(parts[0], 10, 64)
 	if id <= 0 {
 		http.Error(w, "invalid id", http.StatusBadRequest)
 		return
 	}
 
-	h.Auth.RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodPut:
-			h.update(w, r, id)
-		case http.MethodDelete:
-			h.delete(w, id)
+	h.Auth.RequirePermission(rbac.WebhooksManage, "", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case len(parts) == 1:
+			switch r.Method {
+			case http.MethodGet:
+				h.get(w, r, id)
+			case http.MethodPut:
+				h.update(w, r, id)
+			case http.MethodDelete:
+				h.delete(w, r, id)
+			default:
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			}
+		case len(parts) == 2 && parts[1] == "deliveries":
+			if r.Method != http.MethodGet {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			h.deliveries(w, r, id)
+		case len(parts) == 4 && parts[1] == "deliveries" && parts[3] == "redeliver":
+			deliveryID, _ := strconv.ParseInt(parts[2], 10, 64)
+			if deliveryID <= 0 {
+				http.Error(w, "invalid delivery id", http.StatusBadRequest)
+				return
+			}
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			h.redeliver(w, r, id, deliveryID)
 		default:
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			http.NotFound(w, r)
 		}
 	})(w, r)
 }
@@ -61,12 +92,14 @@ func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // @Produce      json
 // @Success      200  {array}   webhook.WebhookDTO
 // @Failure      401  {string}  string  "Unauthorized"
+// @Failure      403  {string}  string  "Missing webhooks:manage permission"
 // @Failure      500  {string}  string  "Database error"
 // @Security     ApiKeyAuth
 // @Security     BearerAuth
+// @Security     ManagedKeyAuth
 // @Router       /webhooks [get]
-func (h *WebhookHandler) list(w http.ResponseWriter) {
-	hooks, err := h.Repo.List()
+func (h *WebhookHandler) list(w http.ResponseWriter, r *http.Request) {
+	hooks, err := h.Repo.List(r.Context())
 	if err != nil {
 		http.Error(w, "db error", http.StatusInternalServerError)
 		return
@@ -81,6 +114,162 @@ func (h *WebhookHandler) list(w http.ResponseWriter) {
 	util.WriteJSON(w, out)
 }
 
+// get godoc
+// @Summary      Get webhook
+// @Description  Get a webhook with the health of its deliveries: queued deliveries, counts over the last 24 hours, and the last success and failure. A webhook is failing while its most recent attempt failed.
+// @Tags         webhooks
+// @Produce      json
+// @Param        id   path      int                       true  "Webhook ID"
+// @Success      200  {object}  webhook.WebhookDetailDTO
+// @Failure      400  {string}  string  "Invalid webhook ID"
+// @Failure      401  {string}  string  "Unauthorized"
+// @Failure      403  {string}  string  "Missing webhooks:manage permission"
+// @Failure      404  {string}  string  "Webhook not found"
+// @Failure      500  {string}  string  "Database error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Security     ManagedKeyAuth
+// @Router       /webhooks/{id} [get]
+func (h *WebhookHandler) get(w http.ResponseWriter, r *http.Request, id int64) {
+	hook, err := h.Repo.Get(r.Context(), id)
+	if errors.Is(err, webhook.ErrNotFound) {
+		http.Error(w, "webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	health, err := h.Repo.Health(r.Context(), id, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	util.WriteJSON(w, webhook.WebhookDetailDTO{
+		WebhookDTO: webhook.WebhookDTO{ID: hook.ID, URL: hook.URL, Events: hook.Events, Enabled: hook.Enabled},
+		Health:     health,
+	})
+}
+
+// deliveries godoc
+// @Summary      List webhook deliveries
+// @Description  Page through the deliveries of a webhook, newest first, each with its payload and every attempt: request headers, response status, the first 4 KiB of the response body, latency and error. Finished deliveries are kept for FW_WEBHOOK_RETENTION_DAYS. The total number of matches is returned in X-Total-Count and the next page in a Link header (rel="next").
+// @Tags         webhooks
+// @Produce      json
+// @Param        id      path      int     true   "Webhook ID"
+// @Param        status  query     string  false  "pending, in_progress, delivered or failed"
+// @Param        limit   query     int     false  "Page size (default 50, max 500)"
+// @Param        before  query     int     false  "Only deliveries with a smaller ID (cursor from the Link header)"
+// @Success      200     {array}   webhook.DeliveryDTO
+// @Header       200     {integer} X-Total-Count  "Deliveries matching the filters"
+// @Failure      400     {string}  string  "Invalid webhook ID or filter"
+// @Failure      401     {string}  string  "Unauthorized"
+// @Failure      403     {string}  string  "Missing webhooks:manage permission"
+// @Failure      404     {string}  string  "Webhook not found"
+// @Failure      500     {string}  string  "Database error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Security     ManagedKeyAuth
+// @Router       /webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) deliveries(w http.ResponseWriter, r *http.Request, id int64) {
+	v := r.URL.Query()
+	f := webhook.DeliveryFilter{Status: v.Get("status")}
+	switch f.Status {
+	case "", webhook.StatusPending, webhook.StatusInProgress, webhook.StatusDelivered, webhook.StatusFailed:
+	default:
+		http.Error(w, "invalid status", http.StatusBadRequest)
+		return
+	}
+	if s := v.Get("before"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid before", http.StatusBadRequest)
+			return
+		}
+		f.BeforeID = n
+	}
+	if s := v.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		f.Limit = n
+	}
+
+	if _, err := h.Repo.Get(r.Context(), id); errors.Is(err, webhook.ErrNotFound) {
+		http.Error(w, "webhook not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	deliveries, total, err := h.Repo.Deliveries(r.Context(), id, f)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	limit := f.Limit
+	if limit <= 0 {
+		limit = webhook.DefaultPageSize
+	}
+	if len(deliveries) == min(limit, webhook.MaxPageSize) {
+		next := *r.URL
+		query := next.Query()
+		query.Set("before", strconv.FormatInt(deliveries[len(deliveries)-1].ID, 10))
+		next.RawQuery = query.Encode()
+		w.Header().Set("Link", "<"+strings.TrimRight(h.PublicBase, "/")+next.RequestURI()+`>; rel="next"`)
+	}
+
+	out := make([]webhook.DeliveryDTO, 0, len(deliveries))
+	for _, d := range deliveries {
+		out = append(out, d.DTO())
+	}
+	util.WriteJSON(w, out)
+}
+
+// redeliver godoc
+// @Summary      Redeliver webhook event
+// @Description  Queue the payload of a delivery again as a new delivery, with its own attempts and retries. The new delivery references the original in redeliveryOf. The webhook must be enabled.
+// @Tags         webhooks
+// @Produce      json
+// @Param        id          path      int  true  "Webhook ID"
+// @Param        deliveryId  path      int  true  "Delivery ID"
+// @Success      202         {object}  webhook.DeliveryDTO  "Queued delivery"
+// @Failure      400         {string}  string  "Invalid webhook or delivery ID"
+// @Failure      401         {string}  string  "Unauthorized"
+// @Failure      403         {string}  string  "Missing webhooks:manage permission"
+// @Failure      404         {string}  string  "Webhook or delivery not found"
+// @Failure      409         {string}  string  "Webhook is disabled"
+// @Failure      500         {string}  string  "Database error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Security     ManagedKeyAuth
+// @Router       /webhooks/{id}/deliveries/{deliveryId}/redeliver [post]
+func (h *WebhookHandler) redeliver(w http.ResponseWriter, r *http.Request, id, deliveryID int64) {
+	d, err := h.Service.Redeliver(r.Context(), id, deliveryID)
+	recordAudit(h.Audit, r, audit.Event{Action: audit.ActionWebhookRedeliver, WebhookID: id,
+		Detail: "delivery " + strconv.FormatInt(deliveryID, 10)}, err)
+	switch {
+	case errors.Is(err, webhook.ErrNotFound):
+		http.Error(w, "delivery not found", http.StatusNotFound)
+		return
+	case errors.Is(err, webhook.ErrDisabled):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	util.WriteJSON(w, d.DTO())
+}
+
 // create godoc
 // @Summary      Create webhook
 // @Description  Register a new webhook endpoint
@@ -91,9 +280,11 @@ func (h *WebhookHandler) list(w http.ResponseWriter) {
 // @Success      200      {object}  map[string]int      "Created webhook ID"
 // @Failure      400      {string}  string              "Invalid JSON or missing required fields"
 // @Failure      401      {string}  string              "Unauthorized"
+// @Failure      403      {string}  string              "Missing webhooks:manage permission"
 // @Failure      500      {string}  string              "Database error"
 // @Security     ApiKeyAuth
 // @Security     BearerAuth
+// @Security     ManagedKeyAuth
 // @Router       /webhooks [post]
 func (h *WebhookHandler) create(w http.ResponseWriter, r *http.Request) {
 	var dto webhook.WebhookDTO
@@ -109,9 +300,10 @@ func (h *WebhookHandler) create(w http.ResponseWriter, r *http.Request) {
 		dto.Enabled = true
 	}
 
-	id, err := h.Repo.Create(webhook.Webhook{
+	id, err := h.Repo.Create(r.Context(), webhook.Webhook{
 		URL: dto.URL, Events: dto.Events, Enabled: dto.Enabled,
 	})
+	recordAudit(h.Audit, r, audit.Event{Action: audit.ActionWebhookCreate, WebhookID: id, Detail: dto.URL}, err)
 	if err != nil {
 		http.Error(w, "db error", http.StatusInternalServerError)
 		return
@@ -131,9 +323,11 @@ func (h *WebhookHandler) create(w http.ResponseWriter, r *http.Request) {
 // @Success      200      {object}  map[string]bool     "Update confirmation"
 // @Failure      400      {string}  string              "Invalid JSON or webhook ID"
 // @Failure      401      {string}  string              "Unauthorized"
+// @Failure      403      {string}  string              "Missing webhooks:manage permission"
 // @Failure      500      {string}  string              "Database error"
 // @Security     ApiKeyAuth
 // @Security     BearerAuth
+// @Security     ManagedKeyAuth
 // @Router       /webhooks/{id} [put]
 func (h *WebhookHandler) update(w http.ResponseWriter, r *http.Request, id int64) {
 	var dto webhook.WebhookDTO
@@ -142,9 +336,11 @@ func (h *WebhookHandler) update(w http.ResponseWriter, r *http.Request, id int64
 		return
 	}
 
-	if err := h.Repo.Update(id, webhook.Webhook{
+	err := h.Repo.Update(r.Context(), id, webhook.Webhook{
 		URL: dto.URL, Events: dto.Events, Enabled: dto.Enabled,
-	}); err != nil {
+	})
+	recordAudit(h.Audit, r, audit.Event{Action: audit.ActionWebhookUpdate, WebhookID: id, Detail: dto.URL}, err)
+	if err != nil {
 		http.Error(w, "db error", http.StatusInternalServerError)
 		return
 	}
@@ -161,12 +357,16 @@ func (h *WebhookHandler) update(w http.ResponseWriter, r *http.Request, id int64
 // @Success      200  {object}  map[string]bool  "Deletion confirmation"
 // @Failure      400  {string}  string           "Invalid webhook ID"
 // @Failure      401  {string}  string           "Unauthorized"
+// @Failure      403  {string}  string           "Missing webhooks:manage permission"
 // @Failure      500  {string}  string           "Database error"
 // @Security     ApiKeyAuth
 // @Security     BearerAuth
+// @Security     ManagedKeyAuth
 // @Router       /webhooks/{id} [delete]
-func (h *WebhookHandler) delete(w http.ResponseWriter, id int64) {
-	if err := h.Repo.Delete(id); err != nil {
+func (h *WebhookHandler) delete(w http.ResponseWriter, r *http.Request, id int64) {
+	err := h.Repo.Delete(r.Context(), id)
+	recordAudit(h.Audit, r, audit.Event{Action: audit.ActionWebhookDelete, WebhookID: id}, err)
+	if err != nil {
 		http.Error(w, "db error", http.StatusInternalServerError)
 		return
 	}
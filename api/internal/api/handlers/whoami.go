@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+
+	"firmware-registry-api/internal/auth"
+	"firmware-registry-api/internal/util"
+)
+
+// WhoAmIHandler reports how the caller was authenticated and what it may do.
+type WhoAmIHandler struct {
+	Auth auth.Auth
+}
+
+// ServeHTTP godoc
+// @Summary      Show caller identity
+// @Description  Show how the request was authenticated and the caller's effective permissions, including grants from RBAC roles. Accepts any valid credential.
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  auth.Identity
+// @Failure      401  {string}  string  "Unauthorized"
+// @Security     ApiKeyAuth
+// @Security     DeviceKeyAuth
+// @Security     BearerAuth
+// @Security     ManagedKeyAuth
+// @Router       /whoami [get]
+func (h *WhoAmIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.Auth.RequireAuthenticated(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := auth.PrincipalFrom(r.Context())
+		util.WriteJSON(w, p.Identity())
+	})(w, r)
+}
@@ -7,7 +7,8 @@ func CORSMiddleware(next http.Handler) http.Handler {
 
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Admin-Key, X-Device-Key")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Admin-Key, X-Device-Key, X-Device-ID, X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Total-Count, Link, X-Firmware-Sha256, X-Firmware-Version, X-Request-ID")
 		w.Header().Set("Access-Control-Max-Age", "86400")
 
 		// Handle preflight OPTIONS
@@ -7,13 +7,26 @@ import (
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
-// NewRouter wires HTTP routes to handlers.
-func NewRouter(fh *handlers.FirmwareHandler, wh *handlers.WebhookHandler) http.Handler {
+// NewRouter wires HTTP routes to handlers. metricsHandler may be nil to
+// disable /metrics.
+func NewRouter(fh *handlers.FirmwareHandler, wh *handlers.WebhookHandler, ah *handlers.AdminHandler, sh *handlers.StatsHandler, auh *handlers.AuditHandler, kh *handlers.KeysHandler, wai *handlers.WhoAmIHandler, metricsHandler http.Handler) http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/api/health", handlers.Health)
 	mux.Handle("/api/firmware/", fh)
+	mux.HandleFunc("/api/search", fh.Search)
 	mux.Handle("/api/webhooks", wh)
 	mux.Handle("/api/webhooks/", wh)
+	mux.Handle("/api/admin/", ah)
+	mux.Handle("/api/stats/", sh)
+	mux.Handle("/api/audit", auh)
+	mux.Handle("/api/audit/", auh)
+	mux.Handle("/api/keys", kh)
+	mux.Handle("/api/keys/", kh)
+	mux.Handle("/api/whoami", wai)
+
+	if metricsHandler != nil {
+		mux.Handle("/metrics", metricsHandler)
+	}
 
 	// Swagger UI at /swagger/index.html
 	mux.HandleFunc("/swagger/", httpSwagger.Handler(
@@ -0,0 +1,76 @@
+// Package apikey manages named, scoped API keys whose secrets are stored
+// hashed in the database.
+package apikey
+
+import (
+	"errors"
+	"regexp"
+	"time"
+
+	"firmware-registry-api/internal/rbac"
+)
+
+// SecretPrefix starts every generated secret, so keys are recognizable in
+// headers and by secret scanners.
+const SecretPrefix = "fwk_"
+
+var (
+	ErrInvalidRequest = errors.New("invalid API key request")
+	ErrInvalidKey     = errors.New("invalid API key")
+	ErrExpired        = errors.New("API key expired")
+	ErrRevoked        = errors.New("API key revoked")
+	ErrNotFound       = errors.New("API key not found")
+	ErrNameInUse      = errors.New("an active API key with this name already exists")
+)
+
+// Key is a managed API key. The secret itself is never stored or returned
+// after creation.
+type Key struct {
+	ID         int64      `json:"id" example:"7"`
+	Name       string     `json:"name" example:"ci-esp32-main"`
+	Prefix     string     `json:"prefix" example:"fwk_Xr3kQ9aB" doc:"Start of the secret, to recognize the key"`
+	Scopes     []string   `json:"scopes" example:"esp32-*:upload,read" doc:"Grants: <type-pattern>:<action> or a bare action (upload, delete, read, webhooks:manage) for all types"`
+	Roles      []string   `json:"roles" example:"esp32-team" doc:"RBAC roles from the config whose grants the key also has"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	CreatedBy  string     `json:"createdBy" example:"admin/api_key/admin-key"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// Permissions returns the key's grants plus those of its roles.
+func (k Key) Permissions(roles rbac.Roles) rbac.Permissions {
+	var p rbac.Permissions
+	grants, _ := rbac.ParseGrants(k.Scopes) // validated on creation
+	p.Add(roles, k.Roles, grants...)
+	return p
+}
+
+// CreateRequest is the body of POST /api/keys.
+type CreateRequest struct {
+	Name      string     `json:"name" example:"ci-esp32-main"`
+	Scopes    []string   `json:"scopes" example:"esp32-*:upload,read"`
+	Roles     []string   `json:"roles" example:"esp32-team"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" doc:"Optional expiry (RFC 3339)"`
+}
+
+// CreatedKey is returned once, on creation; Secret cannot be retrieved later.
+type CreatedKey struct {
+	Key
+	Secret string `json:"secret" example:"fwk_Xr3kQ9aB..." doc:"The API key. Shown only once."`
+}
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// ReservedNames are used for the bootstrap keys from the config.
+var ReservedNames = []string{"admin-key", "device-key"}
+
+// ValidName reports whether s is usable as a key name.
+func ValidName(s string) bool {
+	for _, r := range ReservedNames {
+		if s == r {
+			return false
+		}
+	}
+	return namePattern.MatchString(s)
+}
@@ -0,0 +1,164 @@
+package apikey
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"firmware-registry-api/internal/db"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Repository persists API keys.
+type Repository interface {
+	// Insert stores k with the hash of its secret and returns the new ID.
+	// It returns ErrNameInUse if an active key has the same name.
+	Insert(ctx context.Context, k Key, secretHash string) (int64, error)
+	List(ctx context.Context, includeRevoked bool) ([]Key, error)
+	Get(ctx context.Context, id int64) (Key, error)
+	// FindByHash returns the key whose secret hashes to secretHash, revoked
+	// or not, or ErrNotFound.
+	FindByHash(ctx context.Context, secretHash string) (Key, error)
+	Revoke(ctx context.Context, id int64, at time.Time) error
+	TouchLastUsed(ctx context.Context, id int64, at time.Time) error
+}
+
+// SQLiteRepo implements Repository over SQLite.
+type SQLiteRepo struct {
+	DB *sql.DB
+}
+
+const keyColumns = `id, name, prefix, scopes, roles, created_at, created_by, expires_at, last_used_at, revoked_at`
+
+func (r *SQLiteRepo) Insert(ctx context.Context, k Key, secretHash string) (int64, error) {
+	ctx, done := db.Observe(ctx, "apikey.insert")
+	defer done()
+
+	scopes, _ := json.Marshal(k.Scopes)
+	roles, _ := json.Marshal(k.Roles)
+	res, err := r.DB.ExecContext(ctx, `
+INSERT INTO api_keys(name, prefix, secret_hash, scopes, roles, created_at, created_by, expires_at)
+VALUES(?,?,?,?,?,?,?,?)
+`, k.Name, k.Prefix, secretHash, string(scopes), string(roles), formatTime(&k.CreatedAt), k.CreatedBy, formatTime(k.ExpiresAt))
+	var se sqlite3.Error
+	if errors.As(err, &se) && se.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return 0, ErrNameInUse
+	}
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (r *SQLiteRepo) List(ctx context.Context, includeRevoked bool) ([]Key, error) {
+	ctx, done := db.Observe(ctx, "apikey.list")
+	defer done()
+
+	q := `SELECT ` + keyColumns + ` FROM api_keys`
+	if !includeRevoked {
+		q += ` WHERE revoked_at IS NULL`
+	}
+	rows, err := r.DB.QueryContext(ctx, q+` ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	out := []Key{}
+	for rows.Next() {
+		k, err := scanKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, k)
+	}
+	return out, rows.Err()
+}
+
+func (r *SQLiteRepo) Get(ctx context.Context, id int64) (Key, error) {
+	ctx, done := db.Observe(ctx, "apikey.get")
+	defer done()
+
+	k, err := scanKey(r.DB.QueryRowContext(ctx, `SELECT `+keyColumns+` FROM api_keys WHERE id=?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return k, ErrNotFound
+	}
+	return k, err
+}
+
+func (r *SQLiteRepo) FindByHash(ctx context.Context, secretHash string) (Key, error) {
+	ctx, done := db.Observe(ctx, "apikey.find")
+	defer done()
+
+	k, err := scanKey(r.DB.QueryRowContext(ctx, `SELECT `+keyColumns+` FROM api_keys WHERE secret_hash=?`, secretHash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return k, ErrNotFound
+	}
+	return k, err
+}
+
+func (r *SQLiteRepo) Revoke(ctx context.Context, id int64, at time.Time) error {
+	ctx, done := db.Observe(ctx, "apikey.revoke")
+	defer done()
+
+	res, err := r.DB.ExecContext(ctx,
+		`UPDATE api_keys SET revoked_at=? WHERE id=? AND revoked_at IS NULL`, formatTime(&at), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *SQLiteRepo) TouchLastUsed(ctx context.Context, id int64, at time.Time) error {
+	ctx, done := db.Observe(ctx, "apikey.touch")
+	defer done()
+
+	_, err := r.DB.ExecContext(ctx, `UPDATE api_keys SET last_used_at=? WHERE id=?`, formatTime(&at), id)
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanKey(s scanner) (Key, error) {
+	var k Key
+	var scopes, roles, created string
+	var expires, lastUsed, revoked sql.NullString
+	if err := s.Scan(&k.ID, &k.Name, &k.Prefix, &scopes, &roles, &created, &k.CreatedBy, &expires, &lastUsed, &revoked); err != nil {
+		return k, err
+	}
+	_ = json.Unmarshal([]byte(scopes), &k.Scopes)
+	_ = json.Unmarshal([]byte(roles), &k.Roles)
+	k.CreatedAt, _ = time.Parse(time.RFC3339, created)
+	k.ExpiresAt = parseTime(expires)
+	k.LastUsedAt = parseTime(lastUsed)
+	k.RevokedAt = parseTime(revoked)
+	return k, nil
+}
+
+func formatTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func parseTime(s sql.NullString) *time.Time {
+	if !s.Valid {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, s.String)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
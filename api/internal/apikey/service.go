@@ -0,0 +1,136 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"firmware-registry-api/internal/rbac"
+
+	"github.com/rs/zerolog"
+)
+
+// lastUsedResolution limits last_used_at writes to one per key per minute.
+const lastUsedResolution = time.Minute
+
+// Service creates, verifies and revokes API keys.
+type Service struct {
+	Repo Repository
+}
+
+// Create generates a key and returns it with its secret. The secret is only
+// available here; the database keeps a SHA-256 hash of it. Scopes are stored
+// in canonical form and req.Roles must name roles in roles.
+func (s *Service) Create(ctx context.Context, req CreateRequest, roles rbac.Roles, createdBy string) (CreatedKey, error) {
+	if !ValidName(req.Name) {
+		return CreatedKey{}, fmt.Errorf("%w: invalid name %q", ErrInvalidRequest, req.Name)
+	}
+	if len(req.Scopes) == 0 && len(req.Roles) == 0 {
+		return CreatedKey{}, fmt.Errorf("%w: at least one scope or role is required", ErrInvalidRequest)
+	}
+	grants, err := rbac.ParseGrants(req.Scopes)
+	if err != nil {
+		return CreatedKey{}, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	scopes := make([]string, len(grants))
+	for i, g := range grants {
+		scopes[i] = g.String()
+	}
+	for _, role := range req.Roles {
+		if _, ok := roles[role]; !ok {
+			return CreatedKey{}, fmt.Errorf("%w: unknown role %q", ErrInvalidRequest, role)
+		}
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		return CreatedKey{}, fmt.Errorf("%w: expiresAt must be in the future", ErrInvalidRequest)
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return CreatedKey{}, err
+	}
+	secret := SecretPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	k := Key{
+		Name:      req.Name,
+		Prefix:    secret[:len(SecretPrefix)+8],
+		Scopes:    scopes,
+		Roles:     req.Roles,
+		CreatedAt: now,
+		CreatedBy: createdBy,
+		ExpiresAt: req.ExpiresAt,
+	}
+	id, err := s.Repo.Insert(ctx, k, hashSecret(secret))
+	if err != nil {
+		return CreatedKey{}, err
+	}
+	k.ID = id
+	return CreatedKey{Key: k, Secret: secret}, nil
+}
+
+// Authenticate returns the active key for secret. Unknown secrets yield
+// ErrInvalidKey; known but unusable ones ErrRevoked or ErrExpired.
+func (s *Service) Authenticate(ctx context.Context, secret string) (Key, error) {
+	if !strings.HasPrefix(secret, SecretPrefix) {
+		return Key{}, ErrInvalidKey
+	}
+	hash := hashSecret(secret)
+	k, err := s.Repo.FindByHash(ctx, hash)
+	if err == ErrNotFound {
+		return Key{}, ErrInvalidKey
+	}
+	if err != nil {
+		return Key{}, err
+	}
+
+	now := time.Now().UTC()
+	switch {
+	case k.RevokedAt != nil:
+		return k, ErrRevoked
+	case k.ExpiresAt != nil && !now.Before(*k.ExpiresAt):
+		return k, ErrExpired
+	}
+
+	if k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) >= lastUsedResolution {
+		if err := s.Repo.TouchLastUsed(context.WithoutCancel(ctx), k.ID, now); err != nil {
+			zerolog.Ctx(ctx).Warn().Err(err).Int64("key_id", k.ID).Msg("Failed to record API key use")
+		}
+	}
+	return k, nil
+}
+
+func (s *Service) List(ctx context.Context, includeRevoked bool) ([]Key, error) {
+	return s.Repo.List(ctx, includeRevoked)
+}
+
+// Revoke disables a key immediately. The row is kept for the audit trail.
+func (s *Service) Revoke(ctx context.Context, id int64) (Key, error) {
+	k, err := s.Repo.Get(ctx, id)
+	if err != nil {
+		return k, err
+	}
+	if err := s.Repo.Revoke(ctx, id, time.Now().UTC()); err != nil {
+		return k, err
+	}
+	return k, nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Equal compares two secrets in constant time, including when their lengths
+// differ.
+func Equal(presented, expected string) bool {
+	a := sha256.Sum256([]byte(presented))
+	b := sha256.Sum256([]byte(expected))
+	return subtle.ConstantTimeCompare(a[:], b[:]) == 1
+}
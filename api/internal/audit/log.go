@@ -0,0 +1,59 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// pruneInterval is how often events past the retention are deleted.
+const pruneInterval = time.Hour
+
+// Log records audit events. A nil *Log discards them, so callers that run
+// without an audit trail (tests, maintenance commands) need no checks.
+type Log struct {
+	Repo Repository
+}
+
+// Record stores e, stamping the current time if unset. Failures are logged
+// rather than returned: the audited operation has already happened.
+func (l *Log) Record(ctx context.Context, e Event) {
+	if l == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	if err := l.Repo.Insert(context.WithoutCancel(ctx), e); err != nil {
+		zerolog.Ctx(ctx).Error().
+			Err(err).
+			Str("action", string(e.Action)).
+			Str("actor", e.Actor).
+			Msg("Failed to write audit event")
+	}
+}
+
+// RunRetention deletes events older than retention now and then every hour
+// until ctx is done.
+func (l *Log) RunRetention(ctx context.Context, retention time.Duration) {
+	zerolog.Ctx(ctx).Info().
+		Dur("retention", retention).
+		Msg("Audit log retention enabled")
+
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		n, err := l.Repo.Prune(ctx, time.Now().Add(-retention))
+		if err != nil && ctx.Err() == nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("Failed to prune audit events")
+		} else if n > 0 {
+			zerolog.Ctx(ctx).Debug().Int64("events", n).Msg("Pruned audit events past retention")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Package audit records who performed which administrative action.
+package audit
+
+import "time"
+
+// Action names an audited operation.
+type Action string
+
+const (
+	ActionFirmwareUpload   Action = "firmware.upload"
+	ActionFirmwareDelete   Action = "firmware.delete"
+	ActionWebhookCreate    Action = "webhook.create"
+	ActionWebhookUpdate    Action = "webhook.update"
+	ActionWebhookDelete    Action = "webhook.delete"
+	ActionWebhookRedeliver Action = "webhook.redeliver"
+	ActionRegistryImport   Action = "registry.import"
+	ActionStorageRepair    Action = "storage.repair"
+	ActionAPIKeyCreate     Action = "apikey.create"
+	ActionAPIKeyRevoke     Action = "apikey.revoke"
+	ActionAuthFailure      Action = "auth.failure"
+	ActionAuthDenied       Action = "auth.denied"
+	ActionAuthBan          Action = "auth.ban"
+	ActionBanClear         Action = "ban.clear"
+)
+
+// Outcome is the result of an audited operation.
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeFailure Outcome = "failure"
+)
+
+// Event is one audit log entry.
+type Event struct {
+	ID            int64     `json:"id" example:"42"`
+	Time          time.Time `json:"time"`
+	Actor         string    `json:"actor" example:"alice" doc:"API key name, JWT subject or allowlisted client IP; empty for failed authentication"`
+	ActorType     string    `json:"actorType" example:"jwt" doc:"api_key, jwt, introspection, ip_allowlist or, for auth failures, the credential presented"`
+	ActorEmail    string    `json:"actorEmail,omitempty" example:"alice@example.com"`
+	Role          string    `json:"role" example:"admin"`
+	Action        Action    `json:"action" example:"firmware.upload"`
+	TargetType    string    `json:"targetType,omitempty" example:"esp32-main"`
+	TargetVersion string    `json:"targetVersion,omitempty" example:"1.2.3"`
+	WebhookID     int64     `json:"webhookId,omitempty" example:"3"`
+	Outcome       Outcome   `json:"outcome" example:"success"`
+	ClientIP      string    `json:"clientIp" example:"10.0.0.5"`
+	RequestID     string    `json:"requestId" example:"4f1c2a9e0b7d4e3f8a6b5c4d3e2f1a0b"`
+	Detail        string    `json:"detail,omitempty" doc:"Error message for failures"`
+}
+
+// Filter selects audit events. Zero values mean "no filter".
+type Filter struct {
+	Actor         string
+	Action        Action
+	TargetType    string
+	TargetVersion string
+	WebhookID     int64
+	Outcome       Outcome
+	Since         time.Time
+	Until         time.Time
+	BeforeID      int64 // keyset cursor: only events with a smaller ID
+	Limit         int
+}
+
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
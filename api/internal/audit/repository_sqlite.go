@@ -0,0 +1,183 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"firmware-registry-api/internal/db"
+)
+
+// Repository persists audit events.
+type Repository interface {
+	Insert(ctx context.Context, e Event) error
+	// Query returns one page of events, newest first, and the number of
+	// events matching the filter.
+	Query(ctx context.Context, f Filter) ([]Event, int, error)
+	// Each calls fn for every matching event, oldest first. Limit and
+	// BeforeID are ignored.
+	Each(ctx context.Context, f Filter, fn func(Event) error) error
+	// Prune deletes events recorded before the given time and returns how
+	// many were removed.
+	Prune(ctx context.Context, before time.Time) (int64, error)
+}
+
+// SQLiteRepo implements Repository over SQLite.
+type SQLiteRepo struct {
+	DB *sql.DB
+}
+
+const eventColumns = `id, created_at, actor, actor_type, actor_email, role, action,
+target_type, target_version, COALESCE(webhook_id, 0), outcome, client_ip, request_id, detail`
+
+func (r *SQLiteRepo) Insert(ctx context.Context, e Event) error {
+	ctx, done := db.Observe(ctx, "audit.insert")
+	defer done()
+
+	var webhookID any
+	if e.WebhookID != 0 {
+		webhookID = e.WebhookID
+	}
+	_, err := r.DB.ExecContext(ctx, `
+INSERT INTO audit_events(created_at, actor, actor_type, actor_email, role, action,
+  target_type, target_version, webhook_id, outcome, client_ip, request_id, detail)
+VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?)
+`, e.Time.UTC().Format(time.RFC3339), e.Actor, e.ActorType, e.ActorEmail, e.Role, string(e.Action),
+		e.TargetType, e.TargetVersion, webhookID, string(e.Outcome), e.ClientIP, e.RequestID, e.Detail)
+	return err
+}
+
+func (r *SQLiteRepo) Query(ctx context.Context, f Filter) ([]Event, int, error) {
+	ctx, done := db.Observe(ctx, "audit.query")
+	defer done()
+
+	where, args := f.where()
+
+	var total int
+	if err := r.DB.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM audit_events WHERE `+strings.Join(where, " AND "), args...,
+	).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	if f.BeforeID > 0 {
+		where = append(where, "id < ?")
+		args = append(args, f.BeforeID)
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	rows, err := r.DB.QueryContext(ctx, `SELECT `+eventColumns+` FROM audit_events WHERE `+
+		strings.Join(where, " AND ")+` ORDER BY id DESC LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	out := []Event{}
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		out = append(out, e)
+	}
+	return out, total, rows.Err()
+}
+
+func (r *SQLiteRepo) Each(ctx context.Context, f Filter, fn func(Event) error) error {
+	ctx, done := db.Observe(ctx, "audit.each")
+	defer done()
+
+	where, args := f.where()
+	rows, err := r.DB.QueryContext(ctx, `SELECT `+eventColumns+` FROM audit_events WHERE `+
+		strings.Join(where, " AND ")+` ORDER BY id`, args...)
+	if err != nil {
+		return err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *SQLiteRepo) Prune(ctx context.Context, before time.Time) (int64, error) {
+	ctx, done := db.Observe(ctx, "audit.prune")
+	defer done()
+
+	res, err := r.DB.ExecContext(ctx, `DELETE FROM audit_events WHERE created_at < ?`,
+		before.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (f Filter) where() ([]string, []any) {
+	where := []string{"1=1"}
+	var args []any
+	add := func(clause string, v any) {
+		where = append(where, clause)
+		args = append(args, v)
+	}
+	if f.Actor != "" {
+		add("actor = ?", f.Actor)
+	}
+	if f.Action != "" {
+		add("action = ?", string(f.Action))
+	}
+	if f.TargetType != "" {
+		add("target_type = ?", f.TargetType)
+	}
+	if f.TargetVersion != "" {
+		add("target_version = ?", f.TargetVersion)
+	}
+	if f.WebhookID != 0 {
+		add("webhook_id = ?", f.WebhookID)
+	}
+	if f.Outcome != "" {
+		add("outcome = ?", string(f.Outcome))
+	}
+	if !f.Since.IsZero() {
+		add("created_at >= ?", f.Since.UTC().Format(time.RFC3339))
+	}
+	if !f.Until.IsZero() {
+		add("created_at <= ?", f.Until.UTC().Format(time.RFC3339))
+	}
+	return where, args
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanEvent(row rowScanner) (Event, error) {
+	var e Event
+	var created, action, outcome string
+	err := row.Scan(&e.ID, &created, &e.Actor, &e.ActorType, &e.ActorEmail, &e.Role, &action,
+		&e.TargetType, &e.TargetVersion, &e.WebhookID, &outcome, &e.ClientIP, &e.RequestID, &e.Detail)
+	if err != nil {
+		return e, err
+	}
+	e.Time, _ = time.Parse(time.RFC3339, created)
+	e.Action, e.Outcome = Action(action), Outcome(outcome)
+	return e, nil
+}
@@ -1,246 +1,496 @@
 package auth
 
 import (
-	"context"
-	"net"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
-	"github.com/rs/zerolog/log"
+	"firmware-registry-api/internal/apikey"
+	"firmware-registry-api/internal/audit"
+	"firmware-registry-api/internal/clientip"
+	"firmware-registry-api/internal/logging"
+	"firmware-registry-api/internal/metrics"
+	"firmware-registry-api/internal/ratelimit"
+	"firmware-registry-api/internal/rbac"
+
+	"github.com/rs/zerolog"
 )
 
 // Auth enforces both API-key and OIDC/JWT based authentication
 type Auth struct {
-	AdminKey      string
-	DeviceKey     string
-	NoAuthIPs     []net.IP     // Individual IP addresses that bypass authentication
-	NoAuthSubnets []*net.IPNet // Subnets (CIDR) that bypass authentication
-	OIDCEnabled   bool
-	OIDCVerifier  *OIDCVerifier
-}
-
-// getClientIP extracts the client IP from the request, checking X-Forwarded-For first
-func getClientIP(r *http.Request) (net.IP, string) {
-	// Check X-Forwarded-For header first (used by reverse proxies)
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		// X-Forwarded-For can contain multiple IPs: "client, proxy1, proxy2"
-		// The first one is the original client IP
-		ips := strings.Split(xff, ",")
-		clientIPStr := strings.TrimSpace(ips[0])
-
-		clientIP := net.ParseIP(clientIPStr)
-		if clientIP != nil {
-			return clientIP, clientIPStr
-		}
-		log.Warn().Str("x_forwarded_for", xff).Msg("Failed to parse X-Forwarded-For IP")
-	}
+	Settings     *Store // keys and allowlist, reloadable
+	OIDCEnabled  bool
+	OIDCVerifier *OIDCVerifier
+	Introspector *Introspector   // opaque bearer tokens; may be nil
+	Audit        *audit.Log      // records authentication failures; may be nil
+	Keys         *apikey.Service // managed API keys; nil accepts only the config keys
+	// Limiter rate-limits requests by client IP (RateLimit) and by identity
+	// once authenticated; nil disables rate limiting.
+	Limiter *ratelimit.Limiter
+	// Bans temporarily refuse client IPs that keep failing authentication;
+	// nil disables bans.
+	Bans *ratelimit.Bans
+	// AuditLimiter caps the authentication failures and denials written to
+	// the audit log per client IP (ratelimit.ClassAudit); nil writes all.
+	AuditLimiter *ratelimit.Limiter
+}
 
-	// Fall back to RemoteAddr
-	// Extract IP from "IP:port" format using net.SplitHostPort
-	// This properly handles both IPv4 (127.0.0.1:8080) and IPv6 ([::1]:8080)
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		// If splitting fails, use the whole address (might not have a port)
-		host = r.RemoteAddr
-	}
+// APIKeyHeader carries a managed API key. Managed keys are also accepted in
+// X-Admin-Key and X-Device-Key, so existing clients only swap the value.
+const APIKeyHeader = "X-API-Key"
 
-	clientIP := net.ParseIP(host)
-	return clientIP, host
-}
+// Names recorded as the actor for the shared API keys.
+const (
+	AdminKeyName  = "admin-key"
+	DeviceKeyName = "device-key"
+)
 
-// isIPWhitelisted checks if the client IP is in the no-auth whitelist (IPs or subnets)
-func (a Auth) isIPWhitelisted(r *http.Request) bool {
-	if len(a.NoAuthIPs) == 0 && len(a.NoAuthSubnets) == 0 {
+// isIPWhitelisted checks if the client IP is in a no-auth allowlist (IPs or
+// subnets). list names the allowlist in logs.
+func (a Auth) isIPWhitelisted(r *http.Request, networks clientip.Networks, list string) bool {
+	if networks.Empty() {
 		return false
 	}
 
-	clientIP, clientIPStr := getClientIP(r)
+	clientIP := clientip.IP(r)
 	if clientIP == nil {
-		log.Warn().
+		zerolog.Ctx(r.Context()).Warn().
 			Str("remote_addr", r.RemoteAddr).
-			Str("x_forwarded_for", r.Header.Get("X-Forwarded-For")).
 			Msg("Failed to parse client IP")
 		return false
 	}
 
-	// Check against individual IPs
-	for _, allowedIP := range a.NoAuthIPs {
-		// Compare IPs (this handles IPv4/IPv6 equivalence like 127.0.0.1 == ::1)
-		if clientIP.Equal(allowedIP) {
-			log.Debug().
-				Str("client_ip", clientIPStr).
-				Str("matched_ip", allowedIP.String()).
-				Msg("Client IP matched whitelist")
-			return true
-		}
+	matched, ok := networks.Match(clientIP)
+	if ok {
+		zerolog.Ctx(r.Context()).Debug().
+			Str("client_ip", clientIP.String()).
+			Str("allowlist", list).
+			Str("matched", matched).
+			Msg("Client IP matched whitelist")
 	}
+	return ok
+}
 
-	// Check against subnets (CIDR ranges)
-	for _, subnet := range a.NoAuthSubnets {
-		if subnet.Contains(clientIP) {
-			log.Debug().
-				Str("client_ip", clientIPStr).
-				Str("matched_subnet", subnet.String()).
-				Msg("Client IP matched subnet whitelist")
-			return true
-		}
+// RequireAdmin admits callers with full admin rights: the admin key, OIDC
+// admins and allowlisted clients.
+func (a Auth) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return a.require("admin", "X-Admin-Key", "admin", "", func(p rbac.Permissions) bool {
+		return p.Admin
+	}, next)
+}
+
+// RequirePermission admits callers allowed action on firmware type typ. An
+// empty typ admits callers allowed action on any type; such handlers filter
+// their results with the principal's permissions.
+func (a Auth) RequirePermission(action rbac.Action, typ string, next http.HandlerFunc) http.HandlerFunc {
+	role, keyHeader := "admin", "X-Admin-Key"
+	if action == rbac.Read {
+		role, keyHeader = "device", "X-Device-Key"
 	}
+	return a.require(role, keyHeader, string(action), typ, func(p rbac.Permissions) bool {
+		if typ == "" {
+			return p.AllowsAny(action)
+		}
+		return p.Allows(action, typ)
+	}, next)
+}
 
-	return false
+// RequireDevice admits callers that may read at least one firmware type.
+func (a Auth) RequireDevice(next http.HandlerFunc) http.HandlerFunc {
+	return a.RequirePermission(rbac.Read, "", next)
 }
 
-func (a Auth) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Check if IP is whitelisted (bypass all authentication)
-		if a.isIPWhitelisted(r) {
-			clientIP, _ := getClientIP(r)
-			log.Debug().
-				Str("path", r.URL.Path).
-				Str("method", r.Method).
-				Str("client_ip", clientIP.String()).
-				Str("remote_addr", r.RemoteAddr).
-				Str("x_forwarded_for", r.Header.Get("X-Forwarded-For")).
-				Str("auth_type", "ip_whitelist").
-				Str("role", "admin").
-				Msg("Admin authentication bypassed via IP whitelist")
-			next(w, r)
-			return
-		}
+// RequireAuthenticated admits any caller with a valid credential, whatever
+// its permissions.
+func (a Auth) RequireAuthenticated(next http.HandlerFunc) http.HandlerFunc {
+	return a.require("any", "X-Admin-Key", "", "", func(rbac.Permissions) bool {
+		return true
+	}, next)
+}
 
-		// If OIDC is enabled, try JWT first, then fall back to API key
-		if a.OIDCEnabled && a.OIDCVerifier != nil {
-			if a.verifyJWT(w, r, a.OIDCVerifier.adminRole, "admin") {
-				log.Debug().
+// RestrictRoutes refuses requests to routes restricted to networks the
+// client is not in, before any authentication. A request must be allowed by
+// every rule matching it.
+func (a Auth) RestrictRoutes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, rule := range a.Settings.Load().Routes {
+			if !rule.Matches(r) {
+				continue
+			}
+			if _, ok := rule.Networks.Match(clientip.IP(r)); !ok {
+				a.recordDenial(r, Principal{}, "route", "", "client not allowed for route "+rule.Route)
+				zerolog.Ctx(r.Context()).Warn().
 					Str("path", r.URL.Path).
 					Str("method", r.Method).
-					Str("auth_type", "jwt").
-					Str("role", "admin").
-					Msg("Admin authentication successful via JWT")
-				next(w, r)
+					Str("client_ip", clientip.String(r)).
+					Str("rule", rule.Route).
+					Msg("Route access denied from this network")
+				http.Error(w, "forbidden (route not allowed from this network)", http.StatusForbidden)
 				return
 			}
 		}
+		next.ServeHTTP(w, r)
+	})
+}
 
-		// Fall back to API key authentication
-		if a.AdminKey != "" && r.Header.Get("X-Admin-Key") == a.AdminKey {
-			log.Debug().
-				Str("path", r.URL.Path).
-				Str("method", r.Method).
-				Str("auth_type", "api_key").
-				Str("role", "admin").
-				Msg("Admin authentication successful via API key")
-			next(w, r)
+// RateLimit refuses requests from banned client IPs and applies the rate
+// limit of the request's class per client IP, answering 429 with
+// Retry-After. Limits per identity are applied by require.
+func (a Auth) RateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		class := ratelimit.Classify(r)
+		if class == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ip := clientip.String(r)
+		now := time.Now()
+		if wait, banned := a.Bans.Banned(ip, now); banned {
+			tooManyRequests(w, r, class, "ban", wait)
 			return
 		}
+		if a.Limiter != nil {
+			if ok, wait := a.Limiter.Allow(class, "ip:"+ip, now); !ok {
+				tooManyRequests(w, r, class, "ip", wait)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
 
-		log.Warn().
-			Str("path", r.URL.Path).
-			Str("method", r.Method).
-			Str("remote_addr", r.RemoteAddr).
-			Str("role", "admin").
-			Msg("Admin authentication failed")
-		http.Error(w, "unauthorized (admin)", http.StatusUnauthorized)
+// tooManyRequests answers 429; by names the limit hit (ip, identity or ban).
+func tooManyRequests(w http.ResponseWriter, r *http.Request, class ratelimit.Class, by string, wait time.Duration) {
+	metrics.RateLimited(string(class), by)
+	zerolog.Ctx(r.Context()).Debug().
+		Str("class", string(class)).
+		Str("by", by).
+		Dur("retry_after", wait).
+		Msg("Request rate limited")
+	w.Header().Set("Retry-After", ratelimit.RetryAfter(wait))
+	msg := "too many requests"
+	if by == "ban" {
+		msg = "too many authentication failures, try again later"
 	}
+	http.Error(w, msg, http.StatusTooManyRequests)
 }
 
-func (a Auth) RequireDevice(next http.HandlerFunc) http.HandlerFunc {
+// require authenticates the request and checks allowed. Unauthenticated
+// requests get 401 and are recorded as auth failures for role;
+// authenticated requests that are not allowed get 403 and are audited as
+// denials of action on typ.
+func (a Auth) require(role, keyHeader, action, typ string, allowed func(rbac.Permissions) bool, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Check if IP is whitelisted (bypass all authentication)
-		if a.isIPWhitelisted(r) {
-			clientIP, _ := getClientIP(r)
-			log.Debug().
+		logger := zerolog.Ctx(r.Context())
+
+		s := a.Settings.Load()
+		p, ok := a.authenticate(r, s)
+		if !ok {
+			a.recordFailure(r, role, presentedCredential(r, keyHeader))
+			logger.Warn().
 				Str("path", r.URL.Path).
 				Str("method", r.Method).
-				Str("client_ip", clientIP.String()).
-				Str("remote_addr", r.RemoteAddr).
-				Str("x_forwarded_for", r.Header.Get("X-Forwarded-For")).
-				Str("auth_type", "ip_whitelist").
-				Str("role", "device").
-				Msg("Device authentication bypassed via IP whitelist")
-			next(w, r)
+				Str("client_ip", clientip.String(r)).
+				Str("role", role).
+				Msg("Authentication failed")
+			http.Error(w, "unauthorized ("+role+")", http.StatusUnauthorized)
 			return
 		}
 
-		// If OIDC is enabled, try JWT first, then fall back to API key
-		if a.OIDCEnabled && a.OIDCVerifier != nil {
-			if a.verifyJWT(w, r, a.OIDCVerifier.deviceRole, "device") {
-				log.Debug().
+		if !allowed(p.Permissions) {
+			grant := action
+			if typ != "" {
+				grant = typ + ":" + action
+			}
+			a.recordDenial(r, p, action, typ, "missing "+grant)
+			logger.Warn().
+				Str("path", r.URL.Path).
+				Str("method", r.Method).
+				Str("principal", p.String()).
+				Str("required", grant).
+				Msg("Permission denied")
+			http.Error(w, "forbidden (missing "+grant+")", http.StatusForbidden)
+			return
+		}
+
+		// Admin operations may be limited to some networks, whatever the
+		// credential
+		if role == "admin" && !s.AdminNetworks.Empty() {
+			if _, ok := s.AdminNetworks.Match(clientip.IP(r)); !ok {
+				a.recordDenial(r, p, action, typ, "client not in admin_networks")
+				logger.Warn().
 					Str("path", r.URL.Path).
 					Str("method", r.Method).
-					Str("auth_type", "jwt").
-					Str("role", "device").
-					Msg("Device authentication successful via JWT")
-				next(w, r)
+					Str("principal", p.String()).
+					Str("client_ip", clientip.String(r)).
+					Msg("Admin access denied from this network")
+				http.Error(w, "forbidden (admin access not allowed from this network)", http.StatusForbidden)
 				return
 			}
 		}
 
-		// Fall back to API key authentication
-		if a.DeviceKey != "" && r.Header.Get("X-Device-Key") == a.DeviceKey {
-			log.Debug().
-				Str("path", r.URL.Path).
-				Str("method", r.Method).
-				Str("auth_type", "api_key").
-				Str("role", "device").
-				Msg("Device authentication successful via API key")
-			next(w, r)
-			return
+		// Clients behind one address (NAT, a shared proxy) are also limited
+		// individually by identity
+		if a.Limiter != nil && p.Method != "ip_allowlist" {
+			class := ratelimit.Classify(r)
+			if ok, wait := a.Limiter.Allow(class, "id:"+p.String(), time.Now()); !ok {
+				tooManyRequests(w, r, class, "identity", wait)
+				return
+			}
 		}
 
-		log.Warn().
+		logger.Debug().
 			Str("path", r.URL.Path).
 			Str("method", r.Method).
-			Str("remote_addr", r.RemoteAddr).
-			Str("role", "device").
-			Msg("Device authentication failed")
-		http.Error(w, "unauthorized (device)", http.StatusUnauthorized)
+			Str("auth_type", p.Method).
+			Str("principal", p.String()).
+			Msg("Authentication successful")
+		serveAs(w, r, next, p)
 	}
 }
 
-// verifyJWT validates the JWT token from the Authorization header and checks for the required role
-func (a Auth) verifyJWT(w http.ResponseWriter, r *http.Request, requiredRole string, roleType string) bool {
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		return false
+// authenticate identifies the caller. Credentials are tried in order:
+// admin-allowlisted client IP, bearer token, the admin and device keys,
+// managed API keys and finally device-allowlisted client IP, so that
+// clients on a device allowlist can still authenticate for more. It returns
+// false if none is valid.
+func (a Auth) authenticate(r *http.Request, s Settings) (Principal, bool) {
+	// Allowlisted clients bypass all authentication
+	if a.isIPWhitelisted(r, s.NoAuth, "noauth_ips") {
+		clientIP := clientip.String(r)
+		zerolog.Ctx(r.Context()).Debug().
+			Str("client_ip", clientIP).
+			Str("remote_addr", r.RemoteAddr).
+			Msg("Authentication bypassed via IP whitelist")
+		return Principal{Role: "admin", Method: "ip_allowlist", Subject: clientIP, Permissions: rbac.All}, true
 	}
 
-	token := ExtractBearerToken(authHeader)
-	if token == "" {
-		log.Debug().Msg("No Bearer token found in Authorization header")
-		return false
+	// Bearer tokens (OIDC JWTs or introspected opaque tokens) come first,
+	// then API keys
+	if p, ok := a.verifyBearer(r, s); ok {
+		return p, true
+	}
+
+	if s.AdminKey != "" && apikey.Equal(r.Header.Get("X-Admin-Key"), s.AdminKey) {
+		return Principal{Role: "admin", Method: "api_key", Subject: AdminKeyName, Permissions: rbac.All}, true
+	}
+	if s.DeviceKey != "" && apikey.Equal(r.Header.Get("X-Device-Key"), s.DeviceKey) {
+		return Principal{Role: "device", Method: "api_key", Subject: DeviceKeyName, Permissions: devicePermissions}, true
+	}
+
+	if p, ok := a.managedKey(r, s); ok {
+		return p, true
 	}
 
-	ctx := context.Background()
-	idToken, err := a.OIDCVerifier.VerifyToken(ctx, token)
+	if a.isIPWhitelisted(r, s.DeviceNoAuth, "device_noauth_ips") {
+		clientIP := clientip.String(r)
+		zerolog.Ctx(r.Context()).Debug().
+			Str("client_ip", clientIP).
+			Str("remote_addr", r.RemoteAddr).
+			Msg("Device authentication bypassed via IP whitelist")
+		return Principal{Role: "device", Method: "ip_allowlist", Subject: clientIP, Permissions: devicePermissions}, true
+	}
+	return Principal{}, false
+}
+
+// devicePermissions are granted to the device key and OIDC device role.
+var devicePermissions = rbac.Permissions{Grants: []rbac.Grant{{Pattern: "*", Action: rbac.Read}}}
+
+// managedKey authenticates a managed API key from X-API-Key or, if one looks
+// like a managed key, from X-Admin-Key or X-Device-Key.
+func (a Auth) managedKey(r *http.Request, s Settings) (Principal, bool) {
+	if a.Keys == nil {
+		return Principal{}, false
+	}
+	secret := r.Header.Get(APIKeyHeader)
+	for _, h := range []string{"X-Admin-Key", "X-Device-Key"} {
+		if secret == "" && strings.HasPrefix(r.Header.Get(h), apikey.SecretPrefix) {
+			secret = r.Header.Get(h)
+		}
+	}
+	if secret == "" {
+		return Principal{}, false
+	}
+
+	k, err := a.Keys.Authenticate(r.Context(), secret)
 	if err != nil {
-		log.Warn().
+		zerolog.Ctx(r.Context()).Warn().
 			Err(err).
+			Str("key", k.Name).
 			Str("path", r.URL.Path).
-			Str("remote_addr", r.RemoteAddr).
-			Msg("JWT verification failed")
-		return false
+			Msg("API key rejected")
+		return Principal{}, false
 	}
+	return Principal{Role: "scoped", Method: "api_key", Subject: k.Name, Permissions: k.Permissions(s.Roles)}, true
+}
 
-	hasRole, err := a.OIDCVerifier.HasRole(idToken, requiredRole)
+// serveAs calls next with p stored in the request context and added to the
+// request logger.
+func serveAs(w http.ResponseWriter, r *http.Request, next http.HandlerFunc, p Principal) {
+	ctx := WithPrincipal(r.Context(), p)
+	ctx = logging.With(ctx, func(c zerolog.Context) zerolog.Context {
+		return c.Str("principal", p.String())
+	})
+	next(w, r.WithContext(ctx))
+}
+
+// recordFailure counts a rejected request. Requests that presented a
+// credential are written to the audit log and count towards a ban of the
+// client IP; requests without one (scanners, misconfigured clients) are only
+// counted, so they cannot fill the audit log.
+func (a Auth) recordFailure(r *http.Request, role, method string) {
+	metrics.AuthFailed(role, method)
+	if method == "none" {
+		return
+	}
+	ip := clientip.String(r)
+	if a.auditAllowed(ip) {
+		a.Audit.Record(r.Context(), audit.Event{
+			ActorType: method,
+			Role:      role,
+			Action:    audit.ActionAuthFailure,
+			Outcome:   audit.OutcomeFailure,
+			ClientIP:  ip,
+			RequestID: logging.RequestID(r.Context()),
+			Detail:    r.Method + " " + r.URL.Path,
+		})
+	}
+
+	ban, banned := a.Bans.Failure(ip, time.Now())
+	if !banned {
+		return
+	}
+	metrics.AuthBanned()
+	zerolog.Ctx(r.Context()).Warn().
+		Str("client_ip", ip).
+		Int("failures", ban.Failures).
+		Time("until", ban.Until).
+		Msg("Client IP banned after repeated authentication failures")
+	a.Audit.Record(r.Context(), audit.Event{
+		ActorType: method,
+		Role:      role,
+		Action:    audit.ActionAuthBan,
+		Outcome:   audit.OutcomeSuccess,
+		ClientIP:  ip,
+		RequestID: logging.RequestID(r.Context()),
+		Detail:    fmt.Sprintf("%d failures, banned until %s", ban.Failures, ban.Until.Format(time.RFC3339)),
+	})
+}
+
+// recordDenial counts a refused request and writes it to the audit log with
+// the reason. p is empty for requests refused before authentication.
+func (a Auth) recordDenial(r *http.Request, p Principal, action, typ, reason string) {
+	metrics.AuthDenied(action)
+	ip := clientip.String(r)
+	if !a.auditAllowed(ip) {
+		return
+	}
+	a.Audit.Record(r.Context(), audit.Event{
+		Actor:      p.Subject,
+		ActorType:  p.Method,
+		ActorEmail: p.Email,
+		Role:       p.Role,
+		Action:     audit.ActionAuthDenied,
+		Outcome:    audit.OutcomeFailure,
+		TargetType: typ,
+		ClientIP:   ip,
+		RequestID:  logging.RequestID(r.Context()),
+		Detail:     r.Method + " " + r.URL.Path + ": " + reason,
+	})
+}
+
+// auditAllowed reports whether another refused request from ip may be
+// written to the audit log. Refusals over the limit are still counted in
+// the metrics.
+func (a Auth) auditAllowed(ip string) bool {
+	if a.AuditLimiter == nil {
+		return true
+	}
+	ok, _ := a.AuditLimiter.Allow(ratelimit.ClassAudit, ip, time.Now())
+	return ok
+}
+
+// presentedCredential names the kind of credential a rejected request
+// carried, for the auth failure metric and audit log.
+func presentedCredential(r *http.Request, keyHeader string) string {
+	switch {
+	case r.Header.Get("Authorization") != "":
+		return "jwt"
+	case r.Header.Get(keyHeader) != "", r.Header.Get(APIKeyHeader) != "":
+		return "api_key"
+	default:
+		return "none"
+	}
+}
+
+// verifyBearer validates the bearer token as an OIDC JWT or, failing that,
+// through introspection, and derives its permissions: the admin role (or
+// every token, if none is configured) grants everything, the device role
+// grants read on all types, and role and group names matching RBAC roles add
+// their grants. A valid token that grants nothing is treated as
+// unauthenticated.
+func (a Auth) verifyBearer(r *http.Request, s Settings) (Principal, bool) {
+	jwt := a.OIDCEnabled && a.OIDCVerifier != nil
+	if !jwt && a.Introspector == nil {
+		return Principal{}, false
+	}
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return Principal{}, false
+	}
+
+	raw := ExtractBearerToken(authHeader)
+	if raw == "" {
+		zerolog.Ctx(r.Context()).Debug().Msg("No Bearer token found in Authorization header")
+		return Principal{}, false
+	}
+
+	var token Token
+	var method string
+	var err error
+	if jwt {
+		method = "jwt"
+		token, err = a.OIDCVerifier.VerifyToken(r.Context(), raw)
+	}
+	if (!jwt || err != nil) && a.Introspector != nil {
+		if err != nil {
+			zerolog.Ctx(r.Context()).Debug().Err(err).Msg("JWT verification failed, trying introspection")
+		}
+		method = "introspection"
+		token, err = a.Introspector.Introspect(r.Context(), raw)
+	}
 	if err != nil {
-		log.Error().
+		zerolog.Ctx(r.Context()).Warn().
 			Err(err).
-			Str("required_role", requiredRole).
-			Msg("Failed to check role in JWT")
-		return false
+			Str("auth_type", method).
+			Str("path", r.URL.Path).
+			Str("client_ip", clientip.String(r)).
+			Msg("Bearer token rejected")
+		return Principal{}, false
 	}
 
-	if !hasRole && requiredRole != "" {
-		log.Warn().
-			Str("required_role", requiredRole).
-			Str("role_type", roleType).
+	p := Principal{Method: method, Subject: token.Subject, Email: token.Email, Issuer: token.Issuer}
+	switch {
+	case token.Admin:
+		p.Role, p.Permissions = "admin", rbac.All
+	case token.Device:
+		p.Role = "device"
+		p.Permissions.Add(s.Roles, token.Names, devicePermissions.Grants...)
+	default:
+		p.Role = "scoped"
+		p.Permissions.Add(s.Roles, token.Names)
+	}
+	if p.Permissions.Empty() {
+		zerolog.Ctx(r.Context()).Warn().
+			Str("issuer", token.Issuer).
+			Str("subject", token.Subject).
 			Str("path", r.URL.Path).
-			Str("remote_addr", r.RemoteAddr).
-			Msg("User missing required role")
-		return false
+			Str("client_ip", clientip.String(r)).
+			Msg("Bearer token grants no roles or permissions")
+		return Principal{}, false
 	}
-
-	return true
+	return p, true
 }
 
 // ExtractBearerToken is a helper to extract Bearer token from Authorization header
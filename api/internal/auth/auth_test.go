@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"firmware-registry-api/internal/audit"
+	"firmware-registry-api/internal/clientip"
+	"firmware-registry-api/internal/ratelimit"
+	"firmware-registry-api/internal/rbac"
+)
+
+const (
+	testAdminKey  = "admin-key-0123456789"
+	testDeviceKey = "device-key-0123456789"
+)
+
+// testAuth returns Auth with the shared keys and s's network settings.
+func testAuth(t *testing.T, s Settings) Auth {
+	t.Helper()
+	s.AdminKey, s.DeviceKey = testAdminKey, testDeviceKey
+	return Auth{Settings: NewStore(s)}
+}
+
+func networks(t *testing.T, list string) clientip.Networks {
+	t.Helper()
+	n, invalid := clientip.ParseNetworks(list)
+	if len(invalid) > 0 {
+		t.Fatalf("invalid networks %v", invalid)
+	}
+	return n
+}
+
+// serve sends a request from peer through client IP resolution (trusting
+// the proxy 10.0.0.1), RestrictRoutes and guard.
+func serve(t *testing.T, a Auth, guard func(http.HandlerFunc) http.HandlerFunc, method, path, peer string, headers map[string]string) int {
+	t.Helper()
+	proxies, err := clientip.ParseSettings("10.0.0.1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := clientip.NewResolver(proxies).Middleware(a.RestrictRoutes(guard(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+	req := httptest.NewRequest(method, path, nil)
+	req.RemoteAddr = peer + ":5000"
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestDeviceAllowlist(t *testing.T) {
+	a := testAuth(t, Settings{
+		NoAuth:       networks(t, "192.0.2.1"),
+		DeviceNoAuth: networks(t, "198.51.100.0/24"),
+	})
+	upload := func(next http.HandlerFunc) http.HandlerFunc {
+		return a.RequirePermission(rbac.Upload, "esp32-main", next)
+	}
+	read := func(next http.HandlerFunc) http.HandlerFunc {
+		return a.RequirePermission(rbac.Read, "esp32-main", next)
+	}
+
+	tests := []struct {
+		name    string
+		guard   func(http.HandlerFunc) http.HandlerFunc
+		peer    string
+		headers map[string]string
+		want    int
+	}{
+		{"device allowlist reads", a.RequireDevice, "198.51.100.7", nil, http.StatusOK},
+		{"device allowlist reads a type", read, "198.51.100.7", nil, http.StatusOK},
+		{"device allowlist cannot reach admin routes", a.RequireAdmin, "198.51.100.7", nil, http.StatusForbidden},
+		{"device allowlist cannot upload", upload, "198.51.100.7", nil, http.StatusForbidden},
+		{"device allowlist with the device key is still not admin", a.RequireAdmin, "198.51.100.7",
+			map[string]string{"X-Device-Key": testDeviceKey}, http.StatusForbidden},
+		{"device allowlist can still use the admin key", a.RequireAdmin, "198.51.100.7",
+			map[string]string{"X-Admin-Key": testAdminKey}, http.StatusOK},
+		{"admin allowlist reaches admin routes", a.RequireAdmin, "192.0.2.1", nil, http.StatusOK},
+		{"other clients need credentials", a.RequireDevice, "203.0.113.9", nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(t, a, tt.guard, http.MethodGet, "/api/firmware/esp32-main", tt.peer, tt.headers); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForwardedForFromUntrustedPeer(t *testing.T) {
+	a := testAuth(t, Settings{
+		NoAuth:        networks(t, "192.0.2.1"),
+		DeviceNoAuth:  networks(t, "198.51.100.0/24"),
+		AdminNetworks: networks(t, "192.0.2.0/24"),
+	})
+	admin := map[string]string{"X-Admin-Key": testAdminKey}
+
+	tests := []struct {
+		name  string
+		guard func(http.HandlerFunc) http.HandlerFunc
+		peer  string
+		xff   string
+		key   map[string]string
+		want  int
+	}{
+		{"spoofed admin allowlist IP", a.RequireAdmin, "203.0.113.9", "192.0.2.1", nil, http.StatusUnauthorized},
+		{"spoofed device allowlist IP", a.RequireDevice, "203.0.113.9", "198.51.100.7", nil, http.StatusUnauthorized},
+		{"spoofed admin network", a.RequireAdmin, "203.0.113.9", "192.0.2.50", admin, http.StatusForbidden},
+		{"admin allowlist IP via the trusted proxy", a.RequireAdmin, "10.0.0.1", "192.0.2.1", nil, http.StatusOK},
+		{"device allowlist IP via the trusted proxy", a.RequireDevice, "10.0.0.1", "198.51.100.7", nil, http.StatusOK},
+		{"admin network via the trusted proxy", a.RequireAdmin, "10.0.0.1", "192.0.2.50", admin, http.StatusOK},
+		{"proxy itself is outside the admin networks", a.RequireAdmin, "10.0.0.1", "", admin, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{}
+			for k, v := range tt.key {
+				headers[k] = v
+			}
+			if tt.xff != "" {
+				headers["X-Forwarded-For"] = tt.xff
+			}
+			if got := serve(t, a, tt.guard, http.MethodGet, "/api/admin/config", tt.peer, headers); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestrictRoutes(t *testing.T) {
+	routes, err := clientip.ParseRouteRules(map[string]string{
+		"DELETE /api/firmware/*/*": "192.0.2.0/24",
+		"/api/admin/":              "192.0.2.1, 198.51.100.0/24",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := testAuth(t, Settings{Routes: routes})
+	admin := map[string]string{"X-Admin-Key": testAdminKey}
+	open := func(next http.HandlerFunc) http.HandlerFunc { return next }
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		peer   string
+		want   int
+	}{
+		{"delete from the allowed network", http.MethodDelete, "/api/firmware/esp32/1.0.0", "192.0.2.7", http.StatusOK},
+		{"delete from elsewhere", http.MethodDelete, "/api/firmware/esp32/1.0.0", "198.51.100.7", http.StatusForbidden},
+		{"other methods are not restricted", http.MethodGet, "/api/firmware/esp32/1.0.0", "198.51.100.7", http.StatusOK},
+		{"admin subtree from an allowed network", http.MethodGet, "/api/admin/bans", "198.51.100.7", http.StatusOK},
+		{"admin subtree from elsewhere", http.MethodGet, "/api/admin/bans", "192.0.2.7", http.StatusForbidden},
+		{"admin subtree from an unlisted client", http.MethodGet, "/api/admin/config", "203.0.113.9", http.StatusForbidden},
+		{"unmatched routes are open", http.MethodGet, "/api/health", "203.0.113.9", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(t, a, open, tt.method, tt.path, tt.peer, admin); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	// Route restrictions apply before authentication, so even valid
+	// credentials are refused
+	if got := serve(t, a, a.RequireAdmin, http.MethodGet, "/api/admin/config", "203.0.113.9", admin); got != http.StatusForbidden {
+		t.Errorf("admin key from a restricted network: status = %d, want 403", got)
+	}
+}
+
+// memAudit keeps recorded audit events in memory.
+type memAudit struct {
+	mu     sync.Mutex
+	events []audit.Event
+}
+
+func (m *memAudit) Insert(_ context.Context, e audit.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, e)
+	return nil
+}
+
+func (m *memAudit) Query(context.Context, audit.Filter) ([]audit.Event, int, error) {
+	return nil, 0, nil
+}
+
+func (m *memAudit) Each(context.Context, audit.Filter, func(audit.Event) error) error {
+	return nil
+}
+
+func (m *memAudit) Prune(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m *memAudit) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.events)
+}
+
+func TestFailureAudit(t *testing.T) {
+	repo := &memAudit{}
+	a := testAuth(t, Settings{})
+	a.Audit = &audit.Log{Repo: repo}
+	a.AuditLimiter = ratelimit.NewLimiter(map[ratelimit.Class]ratelimit.Limit{
+		ratelimit.ClassAudit: {PerMin: 1, Burst: 2},
+	})
+	wrongKey := map[string]string{"X-Admin-Key": "wrong-key"}
+
+	for i := 0; i < 5; i++ {
+		if got := serve(t, a, a.RequireAdmin, http.MethodGet, "/api/admin/config", "203.0.113.9", nil); got != http.StatusUnauthorized {
+			t.Fatalf("without credentials: status = %d, want 401", got)
+		}
+	}
+	if n := repo.count(); n != 0 {
+		t.Errorf("%d events for requests without credentials, want 0", n)
+	}
+
+	for i := 0; i < 5; i++ {
+		serve(t, a, a.RequireAdmin, http.MethodGet, "/api/admin/config", "203.0.113.9", wrongKey)
+	}
+	if n := repo.count(); n != 2 {
+		t.Errorf("%d events for 5 wrong keys from one client, want the burst of 2", n)
+	}
+
+	serve(t, a, a.RequireAdmin, http.MethodGet, "/api/admin/config", "198.51.100.7", wrongKey)
+	if n := repo.count(); n != 3 {
+		t.Errorf("%d events after a wrong key from another client, want 3", n)
+	}
+}
@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IntrospectionConfig configures RFC 7662 token introspection.
+type IntrospectionConfig struct {
+	URL          string
+	ClientID     string // sent with ClientSecret as HTTP basic auth, if set
+	ClientSecret string
+	Audiences    []string // the aud field must contain one; empty accepts any
+	RoleClaims   []string // response fields with role names, as for OIDC
+	AdminRole    string
+	DeviceRole   string
+	CacheTTL     time.Duration // 0 disables caching
+}
+
+// maxCachedTokens bounds the introspection cache; once full, new results
+// are not cached until entries expire.
+const maxCachedTokens = 10000
+
+// Introspector validates opaque bearer tokens at an introspection endpoint.
+// Results, including inactive tokens, are cached by token hash for CacheTTL
+// but never past the token's exp.
+type Introspector struct {
+	cfg    IntrospectionConfig
+	client *http.Client
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]introspected
+}
+
+type introspected struct {
+	token   Token
+	err     error
+	expires time.Time
+}
+
+var errTokenInactive = errors.New("token is not active")
+
+// NewIntrospector returns an Introspector for cfg.
+func NewIntrospector(cfg IntrospectionConfig) *Introspector {
+	return &Introspector{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+		cache:  make(map[[sha256.Size]byte]introspected),
+	}
+}
+
+// Introspect returns the token's identity, or an error if it is not active
+// or the endpoint cannot be reached.
+func (in *Introspector) Introspect(ctx context.Context, rawToken string) (Token, error) {
+	key := sha256.Sum256([]byte(rawToken))
+	now := time.Now()
+
+	in.mu.Lock()
+	c, ok := in.cache[key]
+	in.mu.Unlock()
+	if ok && now.Before(c.expires) {
+		return c.token, c.err
+	}
+
+	t, exp, err := in.introspect(ctx, rawToken)
+	if err != nil && !errors.Is(err, errTokenInactive) {
+		return Token{}, err // transport errors are not cached
+	}
+	expires := now.Add(in.cfg.CacheTTL)
+	if !exp.IsZero() && exp.Before(expires) {
+		expires = exp
+	}
+	in.store(key, introspected{token: t, err: err, expires: expires}, now)
+	return t, err
+}
+
+func (in *Introspector) store(key [sha256.Size]byte, c introspected, now time.Time) {
+	if in.cfg.CacheTTL <= 0 || !now.Before(c.expires) {
+		return
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if len(in.cache) >= maxCachedTokens {
+		for k, v := range in.cache {
+			if !now.Before(v.expires) {
+				delete(in.cache, k)
+			}
+		}
+		if len(in.cache) >= maxCachedTokens {
+			return
+		}
+	}
+	in.cache[key] = c
+}
+
+// introspect calls the endpoint. exp is the token's expiry, if reported.
+func (in *Introspector) introspect(ctx context.Context, rawToken string) (Token, time.Time, error) {
+	form := url.Values{"token": {rawToken}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, in.cfg.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Token{}, time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if in.cfg.ClientID != "" {
+		req.SetBasicAuth(url.QueryEscape(in.cfg.ClientID), url.QueryEscape(in.cfg.ClientSecret))
+	}
+
+	resp, err := in.client.Do(req)
+	if err != nil {
+		return Token{}, time.Time{}, fmt.Errorf("token introspection: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return Token{}, time.Time{}, fmt.Errorf("token introspection: %s", resp.Status)
+	}
+
+	var claims map[string]any
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&claims); err != nil {
+		return Token{}, time.Time{}, fmt.Errorf("token introspection: bad response: %w", err)
+	}
+	return in.parse(claims, time.Now())
+}
+
+// parse maps an introspection response onto a Token. Inactive, expired or
+// not yet valid tokens, and tokens for other audiences, are errTokenInactive.
+func (in *Introspector) parse(claims map[string]any, now time.Time) (Token, time.Time, error) {
+	if active, _ := claims["active"].(bool); !active {
+		return Token{}, time.Time{}, errTokenInactive
+	}
+	var exp time.Time
+	if v, ok := claims["exp"].(float64); ok {
+		exp = time.Unix(int64(v), 0)
+		if !now.Before(exp) {
+			return Token{}, exp, fmt.Errorf("%w: expired", errTokenInactive)
+		}
+	}
+	if v, ok := claims["nbf"].(float64); ok && now.Before(time.Unix(int64(v), 0)) {
+		return Token{}, exp, fmt.Errorf("%w: not valid yet", errTokenInactive)
+	}
+	if len(in.cfg.Audiences) > 0 {
+		aud := appendNames(nil, claims["aud"])
+		if !slices.ContainsFunc(aud, func(a string) bool { return slices.Contains(in.cfg.Audiences, a) }) {
+			return Token{}, exp, fmt.Errorf("%w: audience %v not accepted", errTokenInactive, aud)
+		}
+	}
+
+	t := Token{}
+	t.Issuer, _ = claims["iss"].(string)
+	t.Email, _ = claims["email"].(string)
+	// Client-credentials tokens have no user; name them after the client.
+	for _, field := range []string{"sub", "username", "client_id"} {
+		if s, _ := claims[field].(string); s != "" {
+			t.Subject = s
+			break
+		}
+	}
+	for _, path := range in.cfg.RoleClaims {
+		for _, v := range lookupClaim(claims, path) {
+			t.Names = appendNames(t.Names, v)
+		}
+	}
+	has := func(role string) bool {
+		return role == "" || slices.Contains(t.Names, role)
+	}
+	t.Admin = has(in.cfg.AdminRole)
+	t.Device = has(in.cfg.DeviceRole)
+	return t, exp, nil
+}
@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubIntrospection serves RFC 7662 responses from a map of token to
+// claims and counts the calls per token. Tokens not in the map get 500.
+type stubIntrospection struct {
+	mu     sync.Mutex
+	claims map[string]map[string]any
+	calls  map[string]int
+}
+
+func newStubIntrospection(t *testing.T, claims map[string]map[string]any) (*stubIntrospection, *httptest.Server) {
+	t.Helper()
+	s := &stubIntrospection{claims: claims, calls: map[string]int{}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		token := r.PostForm.Get("token")
+		s.mu.Lock()
+		s.calls[token]++
+		c, ok := s.claims[token]
+		s.mu.Unlock()
+		if !ok {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(c)
+	}))
+	t.Cleanup(srv.Close)
+	return s, srv
+}
+
+func (s *stubIntrospection) set(token string, claims map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.claims[token] = claims
+}
+
+func (s *stubIntrospection) count(token string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[token]
+}
+
+func TestIntrospect(t *testing.T) {
+	now := time.Now()
+	stub, srv := newStubIntrospection(t, map[string]map[string]any{
+		"admin": {
+			"active": true, "sub": "alice", "email": "alice@example.com",
+			"aud": []any{"registry"}, "roles": []any{"fw-admin"},
+			"exp": float64(now.Add(time.Hour).Unix()),
+		},
+		"device": {
+			"active": true, "client_id": "esp32-fleet", "aud": "registry",
+			"roles": "fw-device",
+		},
+		"inactive":    {"active": false},
+		"expired":     {"active": true, "sub": "bob", "aud": "registry", "exp": float64(now.Add(-time.Minute).Unix())},
+		"not-yet":     {"active": true, "sub": "bob", "aud": "registry", "nbf": float64(now.Add(time.Hour).Unix())},
+		"other-aud":   {"active": true, "sub": "bob", "aud": []any{"billing"}},
+		"no-aud":      {"active": true, "sub": "bob"},
+		"no-role":     {"active": true, "sub": "carol", "aud": "registry", "roles": []any{"viewer"}},
+		"nbf-elapsed": {"active": true, "sub": "dave", "aud": "registry", "nbf": float64(now.Add(-time.Minute).Unix())},
+	})
+	in := NewIntrospector(IntrospectionConfig{
+		URL:        srv.URL,
+		Audiences:  []string{"registry"},
+		RoleClaims: []string{"roles"},
+		AdminRole:  "fw-admin",
+		DeviceRole: "fw-device",
+	})
+
+	tests := []struct {
+		token         string
+		inactive      bool
+		subject       string
+		admin, device bool
+	}{
+		{token: "admin", subject: "alice", admin: true},
+		{token: "device", subject: "esp32-fleet", device: true},
+		{token: "no-role", subject: "carol"},
+		{token: "nbf-elapsed", subject: "dave"},
+		{token: "inactive", inactive: true},
+		{token: "expired", inactive: true},
+		{token: "not-yet", inactive: true},
+		{token: "other-aud", inactive: true},
+		{token: "no-aud", inactive: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			tok, err := in.Introspect(context.Background(), tt.token)
+			if tt.inactive {
+				if !errors.Is(err, errTokenInactive) {
+					t.Fatalf("err = %v, want errTokenInactive", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tok.Subject != tt.subject || tok.Admin != tt.admin || tok.Device != tt.device {
+				t.Errorf("got subject=%q admin=%v device=%v, want %q %v %v",
+					tok.Subject, tok.Admin, tok.Device, tt.subject, tt.admin, tt.device)
+			}
+		})
+	}
+
+	if got := stub.count("admin"); got != 1 {
+		t.Errorf("without CacheTTL the endpoint was called %d times, want 1 per lookup", got)
+	}
+}
+
+func TestIntrospectCache(t *testing.T) {
+	now := time.Now()
+	shortExp := now.Add(30 * time.Second).Truncate(time.Second)
+	stub, srv := newStubIntrospection(t, map[string]map[string]any{
+		"short":    {"active": true, "sub": "alice", "exp": float64(shortExp.Unix())},
+		"long":     {"active": true, "sub": "bob", "exp": float64(now.Add(24 * time.Hour).Unix())},
+		"no-exp":   {"active": true, "sub": "carol"},
+		"inactive": {"active": false},
+	})
+	in := NewIntrospector(IntrospectionConfig{URL: srv.URL, CacheTTL: time.Minute})
+
+	cachedUntil := func(token string) time.Time {
+		t.Helper()
+		in.mu.Lock()
+		defer in.mu.Unlock()
+		c, ok := in.cache[sha256.Sum256([]byte(token))]
+		if !ok {
+			t.Fatalf("%s: not cached", token)
+		}
+		return c.expires
+	}
+
+	for _, token := range []string{"short", "long", "no-exp", "inactive"} {
+		for i := 0; i < 3; i++ {
+			_, _ = in.Introspect(context.Background(), token)
+		}
+		if got := stub.count(token); got != 1 {
+			t.Errorf("%s: endpoint called %d times, want 1 (cached)", token, got)
+		}
+	}
+
+	if got := cachedUntil("short"); !got.Equal(shortExp) {
+		t.Errorf("short: cached until %v, want exp %v", got, shortExp)
+	}
+	for _, token := range []string{"long", "no-exp", "inactive"} {
+		if got := cachedUntil(token); got.Before(now.Add(time.Minute)) || got.After(time.Now().Add(time.Minute)) {
+			t.Errorf("%s: cached until %v, want now+CacheTTL", token, got)
+		}
+	}
+}
+
+func TestIntrospectTransportErrorsNotCached(t *testing.T) {
+	stub, srv := newStubIntrospection(t, map[string]map[string]any{})
+	in := NewIntrospector(IntrospectionConfig{URL: srv.URL, CacheTTL: time.Minute})
+
+	// The stub answers 500 until the token is known
+	if _, err := in.Introspect(context.Background(), "flaky"); err == nil || errors.Is(err, errTokenInactive) {
+		t.Fatalf("err = %v, want a transport error", err)
+	}
+	stub.set("flaky", map[string]any{"active": true, "sub": "alice"})
+	tok, err := in.Introspect(context.Background(), "flaky")
+	if err != nil || tok.Subject != "alice" {
+		t.Fatalf("after recovery got %+v, %v", tok, err)
+	}
+	if got := stub.count("flaky"); got != 2 {
+		t.Errorf("endpoint called %d times, want 2", got)
+	}
+
+	// Unreachable endpoint
+	srv.Close()
+	down := NewIntrospector(IntrospectionConfig{URL: srv.URL, CacheTTL: time.Minute})
+	if _, err := down.Introspect(context.Background(), "any"); err == nil {
+		t.Fatal("want an error from an unreachable endpoint")
+	}
+	if n := len(down.cache); n != 0 {
+		t.Errorf("%d entries cached after a transport error, want 0", n)
+	}
+}
@@ -0,0 +1,283 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	jwksFetchTimeout = 10 * time.Second
+	// minRefetch limits refetches triggered by tokens signed with an
+	// unknown key, which may also be forged.
+	minRefetch = 30 * time.Second
+	// Discovery and fetch failures are retried with exponential backoff
+	// between these bounds.
+	minRetry = 5 * time.Second
+	maxRetry = 5 * time.Minute
+)
+
+// keySet verifies token signatures with the keys of one issuer. The keys come
+// from a JWKS file or URL, configured or found through discovery, and are
+// refreshed every ttl. Fetched keys are cached on disk, so tokens keep being
+// verified while the IdP is unreachable, also across restarts; a failed
+// refresh keeps the previous keys.
+type keySet struct {
+	issuer    string
+	static    bool   // source was configured; no discovery
+	cacheFile string // empty: no disk cache (local files)
+	ttl       time.Duration
+	client    *http.Client
+
+	fetchMu sync.Mutex // serializes refreshes
+
+	mu        sync.Mutex
+	source    string // JWKS URL or file; empty until discovered
+	keys      *oidc.StaticKeySet
+	fetchedAt time.Time
+	lastTry   time.Time
+	failures  int
+}
+
+// jwksCache is the on-disk format of a fetched key set.
+type jwksCache struct {
+	Issuer    string          `json:"issuer"`
+	Source    string          `json:"source"`
+	FetchedAt time.Time       `json:"fetchedAt"`
+	JWKS      json.RawMessage `json:"jwks"`
+}
+
+func newKeySet(issuer, source, cacheDir string, ttl time.Duration) *keySet {
+	k := &keySet{
+		issuer: issuer,
+		static: source != "",
+		source: source,
+		ttl:    ttl,
+		client: &http.Client{Timeout: jwksFetchTimeout},
+	}
+	if cacheDir != "" && !isLocalFile(source) {
+		sum := sha256.Sum256([]byte(issuer))
+		k.cacheFile = filepath.Join(cacheDir, hex.EncodeToString(sum[:8])+".json")
+		k.loadCache()
+	}
+	return k
+}
+
+// VerifySignature implements oidc.KeySet. A token signed with a key not in
+// the set triggers one refetch, in case the IdP rotated its keys.
+func (k *keySet) VerifySignature(ctx context.Context, jwt string) ([]byte, error) {
+	payload, err := k.verify(ctx, jwt)
+	if err == nil {
+		return payload, nil
+	}
+	k.mu.Lock()
+	due := k.source != "" && time.Since(k.lastTry) >= minRefetch
+	k.mu.Unlock()
+	if !due || k.refresh(ctx) != nil {
+		return nil, err
+	}
+	return k.verify(ctx, jwt)
+}
+
+func (k *keySet) verify(ctx context.Context, jwt string) ([]byte, error) {
+	k.mu.Lock()
+	keys := k.keys
+	k.mu.Unlock()
+	if keys == nil {
+		return nil, fmt.Errorf("no signing keys for issuer %s yet", k.issuer)
+	}
+	return keys.VerifySignature(ctx, jwt)
+}
+
+// due reports whether the keys are missing or older than ttl and the retry
+// backoff has passed.
+func (k *keySet) due(now time.Time) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.keys != nil && now.Sub(k.fetchedAt) < k.ttl {
+		return false
+	}
+	if k.failures == 0 {
+		return true
+	}
+	backoff := min(minRetry<<min(k.failures-1, 16), maxRetry)
+	return now.Sub(k.lastTry) >= backoff
+}
+
+// refresh discovers the JWKS URL if needed and reloads the keys.
+func (k *keySet) refresh(ctx context.Context) error {
+	k.fetchMu.Lock()
+	defer k.fetchMu.Unlock()
+
+	k.mu.Lock()
+	source := k.source
+	k.lastTry = time.Now()
+	k.mu.Unlock()
+
+	err := func() error {
+		// Discovered sources are looked up again in case jwks_uri changed.
+		if !k.static {
+			discovered, err := k.discover(ctx)
+			if err != nil {
+				if source == "" {
+					return err
+				}
+				// Discovery is down but a cached JWKS URL is known.
+				log.Debug().Err(err).Str("issuer", k.issuer).Msg("OIDC discovery failed, using cached JWKS URL")
+			} else {
+				source = discovered
+			}
+		}
+		raw, err := k.fetch(ctx, source)
+		if err != nil {
+			return err
+		}
+		keys, err := parseJWKS(raw)
+		if err != nil {
+			return fmt.Errorf("JWKS from %s: %w", source, err)
+		}
+
+		now := time.Now().UTC()
+		k.mu.Lock()
+		k.source, k.keys, k.fetchedAt, k.failures = source, keys, now, 0
+		k.mu.Unlock()
+		k.saveCache(jwksCache{Issuer: k.issuer, Source: source, FetchedAt: now, JWKS: raw})
+
+		log.Info().
+			Str("issuer", k.issuer).
+			Str("jwks", source).
+			Int("keys", len(keys.PublicKeys)).
+			Msg("OIDC signing keys loaded")
+		return nil
+	}()
+	if err != nil {
+		k.mu.Lock()
+		k.failures++
+		k.mu.Unlock()
+	}
+	return err
+}
+
+// discover returns the issuer's jwks_uri.
+func (k *keySet) discover(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(oidc.ClientContext(ctx, k.client), jwksFetchTimeout)
+	defer cancel()
+	provider, err := oidc.NewProvider(ctx, k.issuer)
+	if err != nil {
+		return "", fmt.Errorf("OIDC discovery for %s: %w", k.issuer, err)
+	}
+	var claims struct {
+		JWKSURL string `json:"jwks_uri"`
+	}
+	if err := provider.Claims(&claims); err != nil || claims.JWKSURL == "" {
+		return "", fmt.Errorf("OIDC discovery for %s: no jwks_uri", k.issuer)
+	}
+	return claims.JWKSURL, nil
+}
+
+func (k *keySet) fetch(ctx context.Context, source string) ([]byte, error) {
+	if isLocalFile(source) {
+		return os.ReadFile(strings.TrimPrefix(source, "file://"))
+	}
+	ctx, cancel := context.WithTimeout(ctx, jwksFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch JWKS: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch JWKS from %s: %s", source, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+func (k *keySet) loadCache() {
+	b, err := os.ReadFile(k.cacheFile)
+	if err != nil {
+		return
+	}
+	var c jwksCache
+	if err := json.Unmarshal(b, &c); err != nil || c.Issuer != k.issuer {
+		return
+	}
+	keys, err := parseJWKS(c.JWKS)
+	if err != nil {
+		return
+	}
+	if !k.static {
+		k.source = c.Source
+	}
+	k.keys, k.fetchedAt = keys, c.FetchedAt
+	log.Info().
+		Str("issuer", k.issuer).
+		Time("fetched_at", c.FetchedAt).
+		Msg("OIDC signing keys loaded from cache")
+}
+
+func (k *keySet) saveCache(c jwksCache) {
+	if k.cacheFile == "" {
+		return
+	}
+	err := func() error {
+		if err := os.MkdirAll(filepath.Dir(k.cacheFile), 0o700); err != nil {
+			return err
+		}
+		b, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+		tmp := k.cacheFile + ".tmp"
+		if err := os.WriteFile(tmp, b, 0o600); err != nil {
+			return err
+		}
+		return os.Rename(tmp, k.cacheFile)
+	}()
+	if err != nil {
+		log.Warn().Err(err).Str("path", k.cacheFile).Msg("Failed to cache OIDC signing keys")
+	}
+}
+
+// parseJWKS returns the signing keys of a JWKS document.
+func parseJWKS(raw []byte) (*oidc.StaticKeySet, error) {
+	var set jose.JSONWebKeySet
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, err
+	}
+	keys := &oidc.StaticKeySet{}
+	for _, key := range set.Keys {
+		pub := key.Public() // invalid for symmetric keys
+		if key.Use == "enc" || !pub.Valid() {
+			continue
+		}
+		keys.PublicKeys = append(keys.PublicKeys, crypto.PublicKey(pub.Key))
+	}
+	if len(keys.PublicKeys) == 0 {
+		return nil, errors.New("no signing keys")
+	}
+	return keys, nil
+}
+
+// isLocalFile reports whether a JWKS source is a path rather than a URL.
+func isLocalFile(source string) bool {
+	return source != "" && !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://")
+}
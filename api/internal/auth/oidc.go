@@ -2,87 +2,233 @@ package auth
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/rs/zerolog"
 )
 
-// OIDCVerifier wraps the OIDC provider and verifier for JWT validation
+// OIDCProviderConfig describes one accepted token issuer.
+type OIDCProviderConfig struct {
+	IssuerURL string
+	// A token is accepted if its aud claim contains one of ClientIDs or
+	// Audiences, or its azp (authorized party) claim is one of ClientIDs.
+	ClientIDs []string
+	Audiences []string
+	// RoleClaims are dot-separated claim paths whose values are role or
+	// group names; "*" matches every key of an object.
+	RoleClaims []string
+	AdminRole  string
+	DeviceRole string
+	// JWKS is a file or URL with the signing keys. If empty they are found
+	// through OIDC discovery.
+	JWKS string
+}
+
+type oidcProvider struct {
+	OIDCProviderConfig
+	keys     *keySet
+	verifier *oidc.IDTokenVerifier
+}
+
+// signingAlgs are the accepted token signature algorithms.
+var signingAlgs = []string{
+	oidc.RS256, oidc.RS384, oidc.RS512,
+	oidc.ES256, oidc.ES384, oidc.ES512,
+	oidc.PS256, oidc.PS384, oidc.PS512,
+	oidc.EdDSA,
+}
+
+// OIDCVerifier validates bearer tokens from one or more issuers.
 type OIDCVerifier struct {
-	provider   *oidc.Provider
-	verifier   *oidc.IDTokenVerifier
-	adminRole  string
-	deviceRole string
+	providers map[string]*oidcProvider // by issuer URL
 }
 
-// NewOIDCVerifier creates a new OIDC verifier that connects to Keycloak
-func NewOIDCVerifier(ctx context.Context, issuerURL, clientID, audience, adminRole, deviceRole string) (*OIDCVerifier, error) {
-	provider, err := oidc.NewProvider(ctx, issuerURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create OIDC provider: %w", err)
-	}
+// Token is a verified bearer token.
+type Token struct {
+	Issuer  string
+	Subject string
+	Email   string
+	Names   []string // role and group names found in the role claims
+	Admin   bool     // has the issuer's admin role, or it has none configured
+	Device  bool     // has the issuer's device role, or it has none configured
+}
 
-	config := &oidc.Config{
-		ClientID: clientID,
+// NewOIDCVerifier sets up the providers without network access: keys come
+// from the disk cache in cacheDir (if any) until Discover or Run loads them.
+// ttl is how long fetched keys are used before they are refreshed.
+func NewOIDCVerifier(providers []OIDCProviderConfig, cacheDir string, ttl time.Duration) *OIDCVerifier {
+	o := &OIDCVerifier{providers: make(map[string]*oidcProvider, len(providers))}
+	for _, cfg := range providers {
+		keys := newKeySet(cfg.IssuerURL, cfg.JWKS, cacheDir, ttl)
+		// The audience is checked in VerifyToken against every accepted
+		// client ID and audience, not just one.
+		verifier := oidc.NewVerifier(cfg.IssuerURL, keys, &oidc.Config{
+			SkipClientIDCheck:    true,
+			SupportedSigningAlgs: signingAlgs,
+		})
+		o.providers[cfg.IssuerURL] = &oidcProvider{OIDCProviderConfig: cfg, keys: keys, verifier: verifier}
 	}
+	return o
+}
 
-	// If audience is specified, verify it
-	if audience != "" {
-		config.SupportedSigningAlgs = []string{oidc.RS256}
-		// Note: go-oidc doesn't have a direct audience field in Config
-		// Audience verification happens in VerifyToken
+// Discover loads the signing keys of every provider, running OIDC discovery
+// where no JWKS is configured. The error names every provider that failed;
+// their tokens are rejected, unless cached keys exist, until Run succeeds.
+func (o *OIDCVerifier) Discover(ctx context.Context) error {
+	var errs []error
+	for _, p := range o.providers {
+		if err := p.keys.refresh(ctx); err != nil {
+			errs = append(errs, err)
+		}
 	}
+	return errors.Join(errs...)
+}
 
-	verifier := provider.Verifier(config)
-
-	return &OIDCVerifier{
-		provider:   provider,
-		verifier:   verifier,
-		adminRole:  adminRole,
-		deviceRole: deviceRole,
-	}, nil
+// Run retries failed discovery with backoff and refreshes keys older than
+// their ttl until ctx is cancelled. Failed refreshes keep the previous keys.
+func (o *OIDCVerifier) Run(ctx context.Context) {
+	ticker := time.NewTicker(minRetry)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, p := range o.providers {
+				if !p.keys.due(now) {
+					continue
+				}
+				if err := p.keys.refresh(ctx); err != nil {
+					zerolog.Ctx(ctx).Warn().
+						Err(err).
+						Str("issuer", p.IssuerURL).
+						Msg("OIDC key refresh failed, retrying")
+				}
+			}
+		}
+	}
 }
 
-// VerifyToken validates a JWT token and returns the claims
-func (o *OIDCVerifier) VerifyToken(ctx context.Context, rawToken string) (*oidc.IDToken, error) {
-	idToken, err := o.verifier.Verify(ctx, rawToken)
+// VerifyToken validates a JWT against the provider of its issuer and
+// extracts its subject and role names.
+func (o *OIDCVerifier) VerifyToken(ctx context.Context, rawToken string) (Token, error) {
+	iss, err := unverifiedIssuer(rawToken)
 	if err != nil {
-		return nil, fmt.Errorf("token verification failed: %w", err)
+		return Token{}, fmt.Errorf("token verification failed: %w", err)
+	}
+	p, ok := o.providers[iss]
+	if !ok {
+		return Token{}, fmt.Errorf("token verification failed: untrusted issuer %q", iss)
 	}
 
-	return idToken, nil
-}
+	idToken, err := p.verifier.Verify(ctx, rawToken)
+	if err != nil {
+		return Token{}, fmt.Errorf("token verification failed: %w", err)
+	}
 
-// HasRole checks if the token contains the specified role
-func (o *OIDCVerifier) HasRole(token *oidc.IDToken, role string) (bool, error) {
-	var claims struct {
-		RealmAccess struct {
-			Roles []string `json:"roles"`
-		} `json:"realm_access"`
-		ResourceAccess map[string]struct {
-			Roles []string `json:"roles"`
-		} `json:"resource_access"`
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return Token{}, fmt.Errorf("failed to parse claims: %w", err)
+	}
+	azp, _ := claims["azp"].(string)
+	if !p.acceptsAudience(idToken.Audience, azp) {
+		return Token{}, fmt.Errorf("token verification failed: audience %v not accepted", idToken.Audience)
 	}
 
-	if err := token.Claims(&claims); err != nil {
-		return false, fmt.Errorf("failed to parse claims: %w", err)
+	t := Token{Issuer: iss, Subject: idToken.Subject}
+	t.Email, _ = claims["email"].(string)
+	for _, path := range p.RoleClaims {
+		for _, v := range lookupClaim(claims, path) {
+			t.Names = appendNames(t.Names, v)
+		}
+	}
+	has := func(role string) bool {
+		return role == "" || slices.Contains(t.Names, role)
 	}
+	t.Admin = has(p.AdminRole)
+	t.Device = has(p.DeviceRole)
+	return t, nil
+}
 
-	// Check realm roles
-	for _, r := range claims.RealmAccess.Roles {
-		if r == role {
-			return true, nil
+func (p *oidcProvider) acceptsAudience(aud []string, azp string) bool {
+	for _, a := range aud {
+		if slices.Contains(p.ClientIDs, a) || slices.Contains(p.Audiences, a) {
+			return true
 		}
 	}
+	return azp != "" && slices.Contains(p.ClientIDs, azp)
+}
 
-	// Check client roles
-	for _, client := range claims.ResourceAccess {
-		for _, r := range client.Roles {
-			if r == role {
-				return true, nil
+// unverifiedIssuer reads the iss claim to pick the provider; the signature
+// is checked afterwards by that provider's verifier.
+func unverifiedIssuer(rawToken string) (string, error) {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return "", errors.New("malformed JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("malformed JWT payload: %w", err)
+	}
+	var claims struct {
+		Issuer string `json:"iss"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", fmt.Errorf("malformed JWT payload: %w", err)
+	}
+	return claims.Issuer, nil
+}
+
+// lookupClaim returns the values at path. A key may itself contain dots
+// (e.g. Auth0's "https://example.com/roles"), so the longest literal key is
+// tried first and every split point after that.
+func lookupClaim(v any, path string) []any {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil
+	}
+	if x, ok := m[path]; ok {
+		return []any{x}
+	}
+	var out []any
+	for i := 0; i < len(path); i++ {
+		if path[i] != '.' {
+			continue
+		}
+		key, rest := path[:i], path[i+1:]
+		if key == "*" {
+			for _, x := range m {
+				out = append(out, lookupClaim(x, rest)...)
 			}
+		} else if x, ok := m[key]; ok {
+			out = append(out, lookupClaim(x, rest)...)
 		}
 	}
+	return out
+}
 
-	return false, nil
+// appendNames adds the names in a claim value: a list of strings, or a
+// space-separated string such as the scp claim. Keycloak group paths lose
+// their leading slash.
+func appendNames(names []string, v any) []string {
+	switch v := v.(type) {
+	case string:
+		for _, s := range strings.Fields(v) {
+			names = append(names, strings.TrimPrefix(s, "/"))
+		}
+	case []any:
+		for _, x := range v {
+			if s, ok := x.(string); ok && s != "" {
+				names = append(names, strings.TrimPrefix(s, "/"))
+			}
+		}
+	}
+	return names
 }
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"firmware-registry-api/internal/rbac"
+)
+
+// Principal identifies the caller of an authenticated request.
+type Principal struct {
+	Role        string // admin, device or scoped (grants from a managed key or RBAC roles)
+	Method      string // api_key, jwt, introspection or ip_allowlist
+	Subject     string // API key name, JWT subject or client IP
+	Email       string // JWT email claim, if present
+	Issuer      string // token issuer
+	Permissions rbac.Permissions
+}
+
+// String formats the principal for logs, e.g. "admin/jwt/alice".
+func (p Principal) String() string {
+	parts := []string{p.Role, p.Method}
+	if p.Subject != "" {
+		parts = append(parts, p.Subject)
+	}
+	return strings.Join(parts, "/")
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal stored by the auth middleware.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// Identity describes a principal and its effective permissions.
+type Identity struct {
+	Role        string   `json:"role" example:"scoped" doc:"admin, device or scoped"`
+	Method      string   `json:"method" example:"api_key" doc:"api_key, jwt, introspection or ip_allowlist"`
+	Subject     string   `json:"subject" example:"ci-esp32"`
+	Email       string   `json:"email,omitempty" example:"alice@example.com"`
+	Issuer      string   `json:"issuer,omitempty" example:"https://keycloak.example.com/realms/firmware" doc:"Token issuer, for bearer token callers"`
+	Admin       bool     `json:"admin" doc:"Full access, including key management and admin endpoints"`
+	Roles       []string `json:"roles" example:"esp32-team" doc:"RBAC roles contributing grants"`
+	Permissions []string `json:"permissions" example:"esp32-*:upload,read" doc:"Effective grants; * for admins"`
+}
+
+// Identity returns p for display.
+func (p Principal) Identity() Identity {
+	roles := p.Permissions.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	return Identity{
+		Role:        p.Role,
+		Method:      p.Method,
+		Subject:     p.Subject,
+		Email:       p.Email,
+		Issuer:      p.Issuer,
+		Admin:       p.Permissions.Admin,
+		Roles:       roles,
+		Permissions: p.Permissions.Strings(),
+	}
+}
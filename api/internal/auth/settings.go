@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"sync/atomic"
+
+	"firmware-registry-api/internal/clientip"
+	"firmware-registry-api/internal/rbac"
+)
+
+// Settings are the credentials and allowlist that can be replaced while the
+// server is running.
+type Settings struct {
+	AdminKey     string
+	DeviceKey    string
+	NoAuth       clientip.Networks // clients with full access without credentials
+	DeviceNoAuth clientip.Networks // clients with read access without credentials
+	// AdminNetworks, if not empty, are the only clients allowed admin
+	// operations (admin endpoints, uploads, deletes, webhook management),
+	// whatever their credentials.
+	AdminNetworks clientip.Networks
+	Routes        []clientip.RouteRule // per-route client restrictions
+	Roles         rbac.Roles           // RBAC roles for managed keys and OIDC tokens
+}
+
+// Store holds the current Settings. Copies of Auth share one Store, so an
+// update is seen by every handler at once.
+type Store struct {
+	p atomic.Pointer[Settings]
+}
+
+// NewStore returns a Store holding s.
+func NewStore(s Settings) *Store {
+	st := &Store{}
+	st.Set(s)
+	return st
+}
+
+// Load returns the current settings; a nil Store has none.
+func (st *Store) Load() Settings {
+	if st == nil {
+		return Settings{}
+	}
+	if s := st.p.Load(); s != nil {
+		return *s
+	}
+	return Settings{}
+}
+
+// Set atomically replaces the settings.
+func (st *Store) Set(s Settings) {
+	st.p.Store(&s)
+}
@@ -0,0 +1,195 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"firmware-registry-api/internal/firmware"
+	"firmware-registry-api/internal/webhook"
+
+	"github.com/rs/zerolog"
+)
+
+// Archiver exports and imports the whole registry (database and binaries)
+// as a single tar archive.
+type Archiver struct {
+	DB            *sql.DB
+	Storage       firmware.Storage
+	Firmware      firmware.Repository
+	Webhooks      webhook.Repository
+	MigrationsDir string
+}
+
+// Export writes a tar (optionally gzip-compressed) archive to w.
+//
+// The database is captured with VACUUM INTO, so the archive is consistent
+// even while uploads continue. Binaries are taken from the rows in that
+// snapshot rather than from a directory walk, which keeps orphaned and
+// temporary files out of the archive.
+func (a *Archiver) Export(ctx context.Context, w io.Writer, compress bool) error {
+	tmpDir, err := os.MkdirTemp("", "fwr-export-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+
+	snapshot := filepath.Join(tmpDir, databaseName)
+	if _, err := a.DB.ExecContext(ctx, `VACUUM INTO ?`, snapshot); err != nil {
+		return fmt.Errorf("database snapshot failed: %w", err)
+	}
+
+	rows, err := snapshotFirmware(ctx, snapshot)
+	if err != nil {
+		return err
+	}
+
+	manifest := Manifest{
+		FormatVersion: FormatVersion,
+		CreatedAt:     time.Now().UTC(),
+		Files:         make([]ManifestEntry, 0, len(rows)),
+	}
+	if manifest.Database, err = hashFile(snapshot, databaseName); err != nil {
+		return err
+	}
+
+	sources := make(map[string]string, len(rows))
+	for _, f := range rows {
+		src := a.Storage.FilePath(f.Type, f.Version)
+		name := filesPrefix + path.Join(f.Type, f.Version, "firmware.bin")
+		entry, err := hashFile(src, name)
+		if err != nil {
+			zerolog.Ctx(ctx).Warn().
+				Err(err).
+				Str("type", f.Type).
+				Str("version", f.Version).
+				Msg("Skipping firmware without readable binary in export")
+			continue
+		}
+		manifest.Files = append(manifest.Files, entry)
+		sources[name] = src
+	}
+
+	var out io.Writer = w
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(w)
+		out = gz
+	}
+	tw := tar.NewWriter(out)
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeBytes(tw, manifestName, manifestJSON, manifest.CreatedAt); err != nil {
+		return err
+	}
+	if err := writeFile(tw, snapshot, manifest.Database); err != nil {
+		return err
+	}
+	for _, entry := range manifest.Files {
+		if err := writeFile(tw, sources[entry.Path], entry); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return err
+		}
+	}
+
+	zerolog.Ctx(ctx).Info().
+		Int("firmware_count", len(manifest.Files)).
+		Bool("gzip", compress).
+		Msg("Registry exported")
+	return nil
+}
+
+// snapshotFirmware lists firmware rows from a database file.
+func snapshotFirmware(ctx context.Context, dbPath string) ([]firmware.Firmware, error) {
+	snap, err := sql.Open("sqlite3", dbPath+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = snap.Close()
+	}()
+	return (&firmware.SQLiteRepo{DB: snap}).ListAll(ctx)
+}
+
+func hashFile(src, name string) (ManifestEntry, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	return ManifestEntry{Path: name, SizeBytes: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+func writeBytes(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// writeFile copies src into the archive. The size recorded in the manifest
+// is used for the header so a file that changed since hashing fails loudly
+// instead of producing an archive that does not match its manifest.
+func writeFile(tw *tar.Writer, src string, entry ManifestEntry) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    entry.Path,
+		Mode:    0o644,
+		Size:    entry.SizeBytes,
+		ModTime: info.ModTime(),
+	}); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(tw, f, entry.SizeBytes); err != nil {
+		return fmt.Errorf("archiving %s: %w", entry.Path, err)
+	}
+	return nil
+}
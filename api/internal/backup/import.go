@@ -0,0 +1,310 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"firmware-registry-api/internal/db"
+	"firmware-registry-api/internal/firmware"
+	"firmware-registry-api/internal/webhook"
+
+	"github.com/rs/zerolog"
+)
+
+// Import restores an archive produced by Export. The archive may be plain
+// tar or gzip-compressed.
+//
+// Every entry is verified against the manifest before the registry is
+// touched. Releases that do not exist yet are added; releases that exist
+// with the same checksum are left alone; releases that exist with a
+// different checksum are handled according to policy. Webhooks are merged
+// by URL. Importing into an empty registry is the same operation with no
+// conflicts.
+func (a *Archiver) Import(ctx context.Context, r io.Reader, policy ConflictPolicy) (ImportReport, error) {
+	report := ImportReport{
+		Imported:    []string{},
+		Overwritten: []string{},
+		Skipped:     []string{},
+		Unchanged:   []string{},
+	}
+
+	staging, err := os.MkdirTemp("", "fwr-import-*")
+	if err != nil {
+		return report, err
+	}
+	defer func() {
+		_ = os.RemoveAll(staging)
+	}()
+
+	manifest, err := extract(r, staging)
+	if err != nil {
+		return report, fmt.Errorf("invalid archive: %w", err)
+	}
+	archived := make(map[string]ManifestEntry, len(manifest.Files))
+	for _, e := range manifest.Files {
+		archived[e.Path] = e
+	}
+
+	// Bring the snapshot up to the current schema so older archives import
+	// through the same repository code.
+	snapshot := filepath.Join(staging, databaseName)
+	if err := db.Migrate(snapshot, a.MigrationsDir); err != nil {
+		return report, fmt.Errorf("archived database: %w", err)
+	}
+	snap, err := sql.Open("sqlite3", snapshot)
+	if err != nil {
+		return report, err
+	}
+	defer func() {
+		_ = snap.Close()
+	}()
+
+	rows, err := (&firmware.SQLiteRepo{DB: snap}).ListAll(ctx)
+	if err != nil {
+		return report, fmt.Errorf("archived database: %w", err)
+	}
+	hooks, err := (&webhook.SQLiteRepo{DB: snap}).List(ctx)
+	if err != nil {
+		return report, fmt.Errorf("archived database: %w", err)
+	}
+
+	// Plan first so that ConflictFail aborts before anything is written.
+	var install []firmware.Firmware
+	var conflicts []string
+	for _, f := range rows {
+		key := f.Type + "/" + f.Version
+		entry, ok := archived[filesPrefix+path.Join(f.Type, f.Version, "firmware.bin")]
+		if !ok || entry.SHA256 != f.SHA256 {
+			zerolog.Ctx(ctx).Warn().
+				Str("type", f.Type).
+				Str("version", f.Version).
+				Msg("Archived firmware has no matching binary, skipping")
+			report.Skipped = append(report.Skipped, key)
+			continue
+		}
+
+		existing, err := a.Firmware.Get(ctx, f.Type, f.Version)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			install = append(install, f)
+			report.Imported = append(report.Imported, key)
+		case err != nil:
+			return report, err
+		case existing.SHA256 == f.SHA256:
+			report.Unchanged = append(report.Unchanged, key)
+		case policy == ConflictOverwrite:
+			install = append(install, f)
+			report.Overwritten = append(report.Overwritten, key)
+		case policy == ConflictFail:
+			conflicts = append(conflicts, key)
+		default:
+			report.Skipped = append(report.Skipped, key)
+		}
+	}
+	if len(conflicts) > 0 {
+		return report, fmt.Errorf("%d conflicting releases: %s", len(conflicts), strings.Join(conflicts, ", "))
+	}
+
+	for _, f := range install {
+		src := filepath.Join(staging, filepath.FromSlash(filesPrefix+path.Join(f.Type, f.Version, "firmware.bin")))
+		if err := a.install(ctx, src, f); err != nil {
+			return report, fmt.Errorf("installing %s/%s: %w", f.Type, f.Version, err)
+		}
+	}
+
+	current, err := a.Webhooks.List(ctx)
+	if err != nil {
+		return report, err
+	}
+	registered := make(map[string]bool, len(current))
+	for _, h := range current {
+		registered[h.URL] = true
+	}
+	for _, h := range hooks {
+		if registered[h.URL] {
+			report.WebhooksSkipped++
+			continue
+		}
+		if _, err := a.Webhooks.Create(ctx, h); err != nil {
+			return report, err
+		}
+		registered[h.URL] = true
+		report.WebhooksImported++
+	}
+
+	zerolog.Ctx(ctx).Info().
+		Int("imported", len(report.Imported)).
+		Int("overwritten", len(report.Overwritten)).
+		Int("skipped", len(report.Skipped)).
+		Int("unchanged", len(report.Unchanged)).
+		Int("webhooks_imported", report.WebhooksImported).
+		Str("conflict_policy", string(policy)).
+		Msg("Registry imported")
+	return report, nil
+}
+
+// install copies a verified binary into Storage (atomically, like uploads)
+// and upserts its metadata row.
+func (a *Archiver) install(ctx context.Context, src string, f firmware.Firmware) error {
+	if err := os.MkdirAll(a.Storage.Dir(f.Type, f.Version), 0o755); err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func(in *os.File) {
+		_ = in.Close()
+	}(in)
+
+	dest := a.Storage.FilePath(f.Type, f.Version)
+	tmp := dest + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		return err
+	}
+
+	return a.Firmware.Upsert(ctx, f)
+}
+
+// extract unpacks r into dir, verifying every entry against the manifest.
+func extract(r io.Reader, dir string) (Manifest, error) {
+	var manifest Manifest
+
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return manifest, err
+		}
+		defer func() {
+			_ = gz.Close()
+		}()
+		r = gz
+	} else {
+		r = br
+	}
+	tr := tar.NewReader(r)
+
+	hdr, err := tr.Next()
+	if err != nil {
+		return manifest, fmt.Errorf("reading manifest: %w", err)
+	}
+	if hdr.Name != manifestName {
+		return manifest, fmt.Errorf("first entry must be %s, got %q", manifestName, hdr.Name)
+	}
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return manifest, fmt.Errorf("decoding manifest: %w", err)
+	}
+	if manifest.FormatVersion != FormatVersion {
+		return manifest, fmt.Errorf("unsupported archive format version %d", manifest.FormatVersion)
+	}
+
+	expected := map[string]ManifestEntry{databaseName: manifest.Database}
+	for _, e := range manifest.Files {
+		if !validFilePath(e.Path) {
+			return manifest, fmt.Errorf("invalid path in manifest: %q", e.Path)
+		}
+		expected[e.Path] = e
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return manifest, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		entry, ok := expected[hdr.Name]
+		if !ok {
+			return manifest, fmt.Errorf("entry %q is not listed in the manifest", hdr.Name)
+		}
+		delete(expected, hdr.Name)
+
+		if err := extractEntry(tr, filepath.Join(dir, filepath.FromSlash(hdr.Name)), entry); err != nil {
+			return manifest, err
+		}
+	}
+
+	if len(expected) > 0 {
+		missing := make([]string, 0, len(expected))
+		for name := range expected {
+			missing = append(missing, name)
+		}
+		return manifest, fmt.Errorf("archive is missing %d entries listed in the manifest: %s", len(missing), strings.Join(missing, ", "))
+	}
+	return manifest, nil
+}
+
+func extractEntry(r io.Reader, dest string, entry ManifestEntry) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func(out *os.File) {
+		_ = out.Close()
+	}(out)
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(out, h), r)
+	if err != nil {
+		return err
+	}
+	if n != entry.SizeBytes {
+		return fmt.Errorf("%s: size %d does not match manifest (%d)", entry.Path, n, entry.SizeBytes)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != entry.SHA256 {
+		return fmt.Errorf("%s: sha256 %s does not match manifest (%s)", entry.Path, sum, entry.SHA256)
+	}
+	return nil
+}
+
+// validFilePath accepts exactly firmware/{type}/{version}/firmware.bin.
+func validFilePath(p string) bool {
+	if !strings.HasPrefix(p, filesPrefix) || path.Clean(p) != p {
+		return false
+	}
+	parts := strings.Split(strings.TrimPrefix(p, filesPrefix), "/")
+	if len(parts) != 3 || parts[2] != "firmware.bin" {
+		return false
+	}
+	for _, part := range parts {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
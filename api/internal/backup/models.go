@@ -0,0 +1,65 @@
+package backup
+
+import "time"
+
+// FormatVersion is bumped whenever the archive layout changes incompatibly.
+const FormatVersion = 1
+
+// Archive layout:
+//
+//	manifest.json
+//	registry.db
+//	firmware/{type}/{version}/firmware.bin
+const (
+	manifestName = "manifest.json"
+	databaseName = "registry.db"
+	filesPrefix  = "firmware/"
+)
+
+// Manifest lists every entry in an archive together with its checksum.
+// It is always the first entry so imports can validate while streaming.
+type Manifest struct {
+	FormatVersion int             `json:"formatVersion" example:"1"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	Database      ManifestEntry   `json:"database"`
+	Files         []ManifestEntry `json:"files"`
+}
+
+// ManifestEntry describes a single file inside the archive.
+type ManifestEntry struct {
+	Path      string `json:"path" example:"firmware/esp32-main/1.2.3/firmware.bin"`
+	SizeBytes int64  `json:"sizeBytes" example:"524288"`
+	SHA256    string `json:"sha256" example:"abc123..."`
+}
+
+// ConflictPolicy decides what happens when an imported type/version already
+// exists in the target registry with a different binary.
+type ConflictPolicy string
+
+const (
+	ConflictSkip      ConflictPolicy = "skip"      // keep the existing release
+	ConflictOverwrite ConflictPolicy = "overwrite" // replace it with the archived one
+	ConflictFail      ConflictPolicy = "fail"      // abort the import before writing anything
+)
+
+// ParseConflictPolicy validates a user supplied policy; empty means skip.
+func ParseConflictPolicy(s string) (ConflictPolicy, bool) {
+	switch ConflictPolicy(s) {
+	case "", ConflictSkip:
+		return ConflictSkip, true
+	case ConflictOverwrite, ConflictFail:
+		return ConflictPolicy(s), true
+	}
+	return "", false
+}
+
+// ImportReport summarizes what an import changed. Releases are listed as
+// "type/version".
+type ImportReport struct {
+	Imported         []string `json:"imported"`
+	Overwritten      []string `json:"overwritten"`
+	Skipped          []string `json:"skipped" doc:"Releases not imported: conflicts kept as they were or rows without an archived binary"`
+	Unchanged        []string `json:"unchanged" doc:"Releases already present with the same checksum"`
+	WebhooksImported int      `json:"webhooksImported"`
+	WebhooksSkipped  int      `json:"webhooksSkipped" doc:"Webhooks already registered with the same URL"`
+}
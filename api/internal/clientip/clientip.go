@@ -0,0 +1,187 @@
+// Package clientip resolves the address of the client behind trusted
+// reverse proxies. Forwarding headers are only honored when the request
+// comes from a trusted proxy, so clients cannot spoof their address (and,
+// with it, the no-auth allowlist) by sending the headers themselves.
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// Supported forwarding headers.
+const (
+	HeaderXForwardedFor = "X-Forwarded-For"
+	HeaderXRealIP       = "X-Real-IP"
+	HeaderForwarded     = "Forwarded" // RFC 7239
+)
+
+// Settings configure a Resolver.
+type Settings struct {
+	Trusted []*net.IPNet // proxies whose forwarding header is believed
+	Header  string       // the one header honored; see the Header constants
+}
+
+// ParseSettings parses a comma-separated list of proxy IPs and CIDR subnets
+// and the header name (case-insensitive; empty means X-Forwarded-For).
+func ParseSettings(trusted, header string) (Settings, error) {
+	s := Settings{Header: HeaderXForwardedFor}
+	switch strings.ToLower(strings.TrimSpace(header)) {
+	case "", "x-forwarded-for":
+	case "x-real-ip":
+		s.Header = HeaderXRealIP
+	case "forwarded":
+		s.Header = HeaderForwarded
+	default:
+		return Settings{}, fmt.Errorf("unsupported client IP header %q: want X-Forwarded-For, X-Real-IP or Forwarded", header)
+	}
+
+	var invalid []string
+	for _, entry := range strings.Split(trusted, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil {
+				bits := 8 * net.IPv6len
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 8*net.IPv4len
+				}
+				s.Trusted = append(s.Trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		} else if _, subnet, err := net.ParseCIDR(entry); err == nil {
+			s.Trusted = append(s.Trusted, subnet)
+			continue
+		}
+		invalid = append(invalid, entry)
+	}
+	if len(invalid) > 0 {
+		return Settings{}, fmt.Errorf("invalid trusted proxies: %s", strings.Join(invalid, ", "))
+	}
+	return s, nil
+}
+
+// Resolver determines client addresses. Its settings can be replaced while
+// requests are served.
+type Resolver struct {
+	settings atomic.Pointer[Settings]
+}
+
+// NewResolver returns a Resolver using s.
+func NewResolver(s Settings) *Resolver {
+	r := &Resolver{}
+	r.Set(s)
+	return r
+}
+
+// Set atomically replaces the settings.
+func (r *Resolver) Set(s Settings) {
+	r.settings.Store(&s)
+}
+
+// Resolve returns the client address of req. Without a trusted peer it is
+// the peer itself. Otherwise the configured header is walked from the right,
+// skipping trusted proxies, and the first other address is the client; an
+// entry that cannot be parsed stops the walk at the last good hop.
+func (r *Resolver) Resolve(req *http.Request) net.IP {
+	peer := hostIP(req.RemoteAddr)
+	s := r.settings.Load()
+	if peer == nil || !trusted(s.Trusted, peer) {
+		return peer
+	}
+
+	var hops []string
+	switch s.Header {
+	case HeaderXRealIP:
+		hops = []string{req.Header.Get(HeaderXRealIP)}
+	case HeaderForwarded:
+		hops = forwardedFor(req.Header.Values(HeaderForwarded))
+	default:
+		for _, v := range req.Header.Values(HeaderXForwardedFor) {
+			hops = append(hops, strings.Split(v, ",")...)
+		}
+	}
+
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := hostIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			break
+		}
+		client = ip
+		if !trusted(s.Trusted, ip) {
+			break
+		}
+	}
+	return client
+}
+
+type ctxKey struct{}
+
+// Middleware resolves the client address once and stores it in the request
+// context for IP and String.
+func (r *Resolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ip := r.Resolve(req)
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), ctxKey{}, ip)))
+	})
+}
+
+// IP returns the client address stored by Middleware, or the peer address
+// if the request did not pass through it. It is nil if neither parses.
+func IP(req *http.Request) net.IP {
+	if ip, ok := req.Context().Value(ctxKey{}).(net.IP); ok {
+		return ip
+	}
+	return hostIP(req.RemoteAddr)
+}
+
+// String is IP for logs and records; it falls back to RemoteAddr.
+func String(req *http.Request) string {
+	if ip := IP(req); ip != nil {
+		return ip.String()
+	}
+	return req.RemoteAddr
+}
+
+func trusted(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostIP parses "ip", "ip:port", "[v6]" or "[v6]:port".
+func hostIP(addr string) net.IP {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return net.ParseIP(strings.Trim(addr, "[]"))
+}
+
+// forwardedFor returns the for= parameters of RFC 7239 Forwarded headers in
+// order. Elements without one yield "" so that the walk stops there.
+func forwardedFor(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, elem := range strings.Split(v, ",") {
+			var hop string
+			for _, pair := range strings.Split(elem, ";") {
+				k, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(k, "for") {
+					hop = strings.Trim(val, `"`)
+				}
+			}
+			out = append(out, hop)
+		}
+	}
+	return out
+}
@@ -0,0 +1,149 @@
+package clientip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string // client_ip_header setting
+		peer    string
+		headers map[string][]string
+		want    string
+	}{
+		{
+			name:    "untrusted peer: X-Forwarded-For ignored",
+			peer:    "203.0.113.9:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"10.1.2.3"}},
+			want:    "203.0.113.9",
+		},
+		{
+			name:    "untrusted peer: X-Real-IP ignored",
+			header:  "X-Real-IP",
+			peer:    "203.0.113.9:5000",
+			headers: map[string][]string{"X-Real-Ip": {"10.1.2.3"}},
+			want:    "203.0.113.9",
+		},
+		{
+			name: "trusted peer without header",
+			peer: "10.0.0.1:5000",
+			want: "10.0.0.1",
+		},
+		{
+			name:    "trusted peer",
+			peer:    "10.0.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.7"}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "trusted hops are skipped, spoofed entries on the left are not reached",
+			peer:    "10.0.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"192.0.2.1, 203.0.113.7, 172.16.0.5"}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "several header lines",
+			peer:    "10.0.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"192.0.2.1", "203.0.113.7"}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "only trusted hops",
+			peer:    "10.0.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"172.16.0.5"}},
+			want:    "172.16.0.5",
+		},
+		{
+			name:    "unparsable hop stops the walk",
+			peer:    "10.0.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.7, unknown"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "only the configured header is honored",
+			peer:    "10.0.0.1:5000",
+			headers: map[string][]string{"X-Real-Ip": {"203.0.113.7"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "X-Real-IP",
+			header:  "x-real-ip",
+			peer:    "10.0.0.1:5000",
+			headers: map[string][]string{"X-Real-Ip": {"203.0.113.7"}, "X-Forwarded-For": {"192.0.2.1"}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "Forwarded",
+			header:  "Forwarded",
+			peer:    "10.0.0.1:5000",
+			headers: map[string][]string{"Forwarded": {`for=192.0.2.1, for="[2001:db8::1]:4711";proto=https`}},
+			want:    "2001:db8::1",
+		},
+		{
+			name:    "IPv6 peer",
+			peer:    "[2001:db8::2]:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.7"}},
+			want:    "2001:db8::2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseSettings("10.0.0.1, 172.16.0.0/12", tt.header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/api/health", nil)
+			req.RemoteAddr = tt.peer
+			for k, values := range tt.headers {
+				for _, v := range values {
+					req.Header.Add(k, v)
+				}
+			}
+			if got := NewResolver(s).Resolve(req); got.String() != tt.want {
+				t.Errorf("Resolve = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	s, err := ParseSettings("10.0.0.1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewResolver(s)
+
+	var got string
+	h := r.Middleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got = String(req)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:5000"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if got != "203.0.113.7" {
+		t.Errorf("String = %q, want the forwarded client", got)
+	}
+
+	// Settings replaced at runtime apply to the next request
+	r.Set(Settings{Header: HeaderXForwardedFor})
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if got != "10.0.0.1" {
+		t.Errorf("after untrusting the proxy String = %q, want the peer", got)
+	}
+}
+
+func TestParseSettings(t *testing.T) {
+	for _, tt := range []struct{ trusted, header string }{
+		{"10.0.0.1, proxy.local", ""},
+		{"10.0.0.0/33", ""},
+		{"10.0.0.1", "X-Client-IP"},
+	} {
+		if _, err := ParseSettings(tt.trusted, tt.header); err == nil {
+			t.Errorf("ParseSettings(%q, %q) succeeded, want an error", tt.trusted, tt.header)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Networks is a set of IP addresses and CIDR subnets.
+type Networks struct {
+	IPs     []net.IP
+	Subnets []*net.IPNet
+}
+
+// ParseNetworks parses a comma-separated list of IP addresses and CIDR
+// subnets. Entries that are neither are returned in invalid.
+func ParseNetworks(list string) (n Networks, invalid []string) {
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			_, subnet, err := net.ParseCIDR(entry)
+			if err != nil {
+				invalid = append(invalid, entry)
+				continue
+			}
+			n.Subnets = append(n.Subnets, subnet)
+		} else if ip := net.ParseIP(entry); ip != nil {
+			n.IPs = append(n.IPs, ip)
+		} else {
+			invalid = append(invalid, entry)
+		}
+	}
+	return n, invalid
+}
+
+// Empty reports whether n contains no addresses.
+func (n Networks) Empty() bool {
+	return len(n.IPs) == 0 && len(n.Subnets) == 0
+}
+
+// Match returns the entry containing ip, if any. IPv4 addresses match their
+// IPv4-mapped IPv6 form and vice versa.
+func (n Networks) Match(ip net.IP) (string, bool) {
+	if ip == nil {
+		return "", false
+	}
+	for _, allowed := range n.IPs {
+		if ip.Equal(allowed) {
+			return allowed.String(), true
+		}
+	}
+	for _, subnet := range n.Subnets {
+		if subnet.Contains(ip) {
+			return subnet.String(), true
+		}
+	}
+	return "", false
+}
+
+// Strings returns the entries in n.
+func (n Networks) Strings() []string {
+	out := make([]string, 0, len(n.IPs)+len(n.Subnets))
+	for _, ip := range n.IPs {
+		out = append(out, ip.String())
+	}
+	for _, subnet := range n.Subnets {
+		out = append(out, subnet.String())
+	}
+	return out
+}
+
+// RouteRule restricts requests matching Method and Pattern to clients in
+// Networks.
+type RouteRule struct {
+	Route    string // the rule as configured, e.g. "DELETE /api/firmware/*"
+	Method   string // empty matches every method
+	Pattern  string // path.Match pattern; with a trailing "/" it matches the subtrees
+	Networks Networks
+}
+
+// ParseRouteRules parses route restrictions: keys are a path pattern,
+// optionally preceded by a method ("DELETE /api/firmware/*"), values the
+// networks allowed to use it. Rules are returned sorted by route.
+func ParseRouteRules(routes map[string]string) ([]RouteRule, error) {
+	rules := make([]RouteRule, 0, len(routes))
+	for route, list := range routes {
+		rule := RouteRule{Route: route, Pattern: strings.TrimSpace(route)}
+		if method, pattern, ok := strings.Cut(rule.Pattern, " "); ok {
+			rule.Method, rule.Pattern = strings.ToUpper(method), strings.TrimSpace(pattern)
+		}
+		if !strings.HasPrefix(rule.Pattern, "/") {
+			return nil, fmt.Errorf("route %q: path must start with /", route)
+		}
+		if _, err := path.Match(rule.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("route %q: %w", route, err)
+		}
+		var invalid []string
+		rule.Networks, invalid = ParseNetworks(list)
+		if len(invalid) > 0 {
+			return nil, fmt.Errorf("route %q: not an IP address or CIDR subnet: %s", route, strings.Join(invalid, ", "))
+		}
+		if rule.Networks.Empty() {
+			return nil, fmt.Errorf("route %q: no networks", route)
+		}
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Route < rules[j].Route })
+	return rules, nil
+}
+
+// Matches reports whether the rule applies to r. GET rules also cover HEAD.
+func (rule RouteRule) Matches(r *http.Request) bool {
+	if rule.Method != "" && rule.Method != r.Method && !(rule.Method == http.MethodGet && r.Method == http.MethodHead) {
+		return false
+	}
+	p := r.URL.Path
+	if strings.HasSuffix(rule.Pattern, "/") {
+		// Compare the path's leading segments with the pattern
+		n := strings.Count(rule.Pattern, "/")
+		i := 0
+		for ; i < len(p) && n > 0; i++ {
+			if p[i] == '/' {
+				n--
+			}
+		}
+		if n > 0 {
+			return false
+		}
+		p = p[:i]
+	}
+	ok, _ := path.Match(rule.Pattern, p)
+	return ok
+}
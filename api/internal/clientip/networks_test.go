@@ -0,0 +1,93 @@
+package clientip
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNetworksMatch(t *testing.T) {
+	n, invalid := ParseNetworks("192.0.2.1, 10.0.0.0/8, 2001:db8::/32, nonsense")
+	if len(invalid) != 1 || invalid[0] != "nonsense" {
+		t.Fatalf("invalid = %v, want [nonsense]", invalid)
+	}
+	tests := []struct {
+		ip    string
+		match string
+	}{
+		{"192.0.2.1", "192.0.2.1"},
+		{"::ffff:192.0.2.1", "192.0.2.1"},
+		{"10.200.1.1", "10.0.0.0/8"},
+		{"2001:db8::7", "2001:db8::/32"},
+		{"192.0.2.2", ""},
+		{"11.0.0.1", ""},
+	}
+	for _, tt := range tests {
+		got, ok := n.Match(net.ParseIP(tt.ip))
+		if got != tt.match || ok != (tt.match != "") {
+			t.Errorf("Match(%s) = %q, %v, want %q", tt.ip, got, ok, tt.match)
+		}
+	}
+	if _, ok := n.Match(nil); ok {
+		t.Error("nil IP matched")
+	}
+}
+
+func TestRouteRuleMatches(t *testing.T) {
+	rules, err := ParseRouteRules(map[string]string{
+		"DELETE /api/firmware/*/*": "10.0.0.0/8",
+		"/api/admin/":              "10.0.0.0/8",
+		"get /api/firmware/*":      "10.0.0.0/8",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byRoute := map[string]RouteRule{}
+	for _, r := range rules {
+		byRoute[r.Route] = r
+	}
+
+	tests := []struct {
+		route  string
+		method string
+		path   string
+		want   bool
+	}{
+		{"DELETE /api/firmware/*/*", http.MethodDelete, "/api/firmware/esp32/1.0.0", true},
+		{"DELETE /api/firmware/*/*", http.MethodGet, "/api/firmware/esp32/1.0.0", false},
+		{"DELETE /api/firmware/*/*", http.MethodDelete, "/api/firmware/esp32", false},
+		{"DELETE /api/firmware/*/*", http.MethodDelete, "/api/firmware/esp32/1.0.0/presign", false},
+		{"/api/admin/", http.MethodGet, "/api/admin/config", true},
+		{"/api/admin/", http.MethodDelete, "/api/admin/bans/192.0.2.1", true},
+		{"/api/admin/", http.MethodGet, "/api/admin", false},
+		{"/api/admin/", http.MethodGet, "/api/administrators/x", false},
+		{"get /api/firmware/*", http.MethodGet, "/api/firmware/esp32", true},
+		{"get /api/firmware/*", http.MethodHead, "/api/firmware/esp32", true},
+		{"get /api/firmware/*", http.MethodPost, "/api/firmware/esp32", false},
+		{"get /api/firmware/*", http.MethodGet, "/api/firmware/esp32/1.0.0", false},
+	}
+	for _, tt := range tests {
+		rule, ok := byRoute[tt.route]
+		if !ok {
+			t.Fatalf("no rule %q in %v", tt.route, rules)
+		}
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if got := rule.Matches(req); got != tt.want {
+			t.Errorf("%q matches %s %s = %v, want %v", tt.route, tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParseRouteRulesErrors(t *testing.T) {
+	for _, routes := range []map[string]string{
+		{"api/admin/": "10.0.0.0/8"},
+		{"/api/[": "10.0.0.0/8"},
+		{"/api/admin/": "10.0.0.0/33"},
+		{"/api/admin/": " , "},
+	} {
+		if _, err := ParseRouteRules(routes); err == nil {
+			t.Errorf("ParseRouteRules(%v) succeeded, want an error", routes)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -19,12 +21,64 @@ type Config struct {
 
 	AdminKey  string `yaml:"admin_key"`
 	DeviceKey string `yaml:"device_key"`
+	// Files holding the keys (e.g. /run/secrets/...), read on every load.
+	AdminKeyFile  string `yaml:"admin_key_file"`
+	DeviceKeyFile string `yaml:"device_key_file"`
 
-	// NoAuthIPs contains comma-separated IP addresses that bypass authentication
+	// NoAuthIPs contains comma-separated IP addresses and subnets that bypass
+	// authentication with full (admin) access
 	NoAuthIPs string `yaml:"noauth_ips"`
+	// DeviceNoAuthIPs bypass authentication with read-only (device) access,
+	// e.g. test jigs on a factory LAN
+	DeviceNoAuthIPs string `yaml:"device_noauth_ips"`
+	// AdminNetworks, if set, restricts admin operations (admin endpoints,
+	// uploads, deletes, webhook management) to these IPs and subnets, even
+	// with a valid admin credential
+	AdminNetworks string `yaml:"admin_networks"`
+	// RouteNetworks restricts routes to clients in networks. Keys are a path
+	// pattern, optionally preceded by a method ("DELETE /api/firmware/*");
+	// values are comma-separated IPs and subnets.
+	RouteNetworks map[string]string `yaml:"route_networks"`
+
+	// TrustedProxies lists the reverse proxies (comma-separated IPs and CIDR
+	// subnets) whose ClientIPHeader is believed. Requests from anywhere else
+	// are attributed to their peer address.
+	TrustedProxies string `yaml:"trusted_proxies"`
+	// ClientIPHeader is X-Forwarded-For (default), X-Real-IP or Forwarded.
+	ClientIPHeader string `yaml:"client_ip_header"`
 
 	MaxUploadMB int64 `yaml:"max_upload_mb"`
 
+	// HTTP server limits. Timeouts of 0 disable the limit.
+	Server struct {
+		ReadHeaderTimeoutSec int `yaml:"read_header_timeout_sec"`
+		ReadTimeoutSec       int `yaml:"read_timeout_sec"`  // whole request, including upload bodies
+		WriteTimeoutSec      int `yaml:"write_timeout_sec"` // whole response, including downloads
+		IdleTimeoutSec       int `yaml:"idle_timeout_sec"`
+		MaxHeaderBytes       int `yaml:"max_header_bytes"`
+		// ShutdownGraceSec bounds draining requests and sending due webhook
+		// deliveries after SIGTERM/SIGINT.
+		ShutdownGraceSec int `yaml:"shutdown_grace_sec"`
+	} `yaml:"server"`
+
+	// Native HTTPS. Enabled when both CertFile and KeyFile are set; the pair
+	// is reloaded when either file changes.
+	TLS struct {
+		CertFile          string `yaml:"cert_file"`
+		KeyFile           string `yaml:"key_file"`
+		MinVersion        string `yaml:"min_version"`   // 1.0, 1.1, 1.2 or 1.3
+		CipherSuites      string `yaml:"cipher_suites"` // comma-separated IANA names, TLS <= 1.2 only; empty = Go defaults
+		ReloadIntervalSec int    `yaml:"reload_interval_sec"`
+		// RedirectAddr, if set, serves plain HTTP redirects to HTTPS, e.g. ":80".
+		RedirectAddr string `yaml:"redirect_addr"`
+	} `yaml:"tls"`
+
+	// Reload polls FW_CONFIG_FILE for changes every WatchSec seconds (0 = only
+	// on SIGHUP).
+	Reload struct {
+		WatchSec int `yaml:"watch_sec"`
+	} `yaml:"reload"`
+
 	// Logging configuration
 	Logging struct {
 		Level      string `yaml:"level"`       // trace, debug, info, warn, error, fatal, panic
@@ -39,22 +93,127 @@ type Config struct {
 		SyslogNet  string `yaml:"syslog_net"`  // tcp, udp, or empty for local
 	} `yaml:"logging"`
 
-	// OIDC/Keycloak extension point. Off by default.
+	// OIDC/JWT bearer tokens. Off by default. The top-level provider fields
+	// configure the first issuer; Providers adds more (YAML only).
 	OIDC struct {
-		Enabled      bool   `yaml:"enabled"`
-		IssuerURL    string `yaml:"issuer_url"`
-		ClientID     string `yaml:"client_id"`
-		Audience     string `yaml:"audience"`
-		AdminRole    string `yaml:"admin_role"`
-		DeviceRole   string `yaml:"device_role"`
+		Enabled      bool `yaml:"enabled"`
+		OIDCProvider `yaml:",inline"`
+		Providers    []OIDCProvider `yaml:"providers"`
+		// Fetched signing keys are refreshed every JWKSCacheSec and cached in
+		// JWKSCacheDir (default: jwks-cache next to db_path), so tokens are
+		// still verified while an IdP is down.
 		JWKSCacheSec int    `yaml:"jwks_cache_sec"`
+		JWKSCacheDir string `yaml:"jwks_cache_dir"`
 	} `yaml:"oidc"`
 
+	// OAuth2 token introspection (RFC 7662) for opaque bearer tokens.
+	// Enabled when URL is set; tried after OIDC.
+	Introspection struct {
+		URL              string `yaml:"url"`
+		ClientID         string `yaml:"client_id"`
+		ClientSecret     string `yaml:"client_secret"`
+		ClientSecretFile string `yaml:"client_secret_file"`
+		Audience         string `yaml:"audience"`    // comma-separated; empty accepts any
+		RoleClaims       string `yaml:"role_claims"` // comma-separated response fields, as for OIDC
+		AdminRole        string `yaml:"admin_role"`
+		DeviceRole       string `yaml:"device_role"`
+		// Results are cached for CacheSec, but never past the token's exp.
+		CacheSec int `yaml:"cache_sec"`
+	} `yaml:"introspection"`
+
+	// RBAC roles, each a list of grants such as "esp32-*:upload" or a bare
+	// action for all types. Roles are assigned to managed API keys and
+	// matched against OIDC role and group names.
+	RBAC struct {
+		Roles map[string][]string `yaml:"roles"`
+	} `yaml:"rbac"`
+
 	Webhooks struct {
 		Secret     string `yaml:"secret"`
+		SecretFile string `yaml:"secret_file"`
 		TimeoutSec int    `yaml:"timeout_sec"`
 		Retries    int    `yaml:"retries"`
+		// Workers caps concurrent deliveries; queued events wait for a
+		// free worker.
+		Workers int `yaml:"workers"`
+		// RetentionDays keeps delivered and failed deliveries this long;
+		// 0 keeps them forever.
+		RetentionDays int `yaml:"retention_days"`
 	} `yaml:"webhooks"`
+
+	// Prometheus endpoint at /metrics, off unless enabled. Token, if set, is
+	// required as a bearer token.
+	Metrics struct {
+		Enabled   bool   `yaml:"enabled"`
+		Token     string `yaml:"token"`
+		TokenFile string `yaml:"token_file"`
+	} `yaml:"metrics"`
+
+	// OpenTelemetry trace export over OTLP/HTTP. Off by default.
+	Tracing struct {
+		Enabled     bool    `yaml:"enabled"`
+		Endpoint    string  `yaml:"endpoint"` // collector URL, e.g. http://localhost:4318
+		ServiceName string  `yaml:"service_name"`
+		SampleRatio float64 `yaml:"sample_ratio"` // fraction of new traces recorded, 0..1
+	} `yaml:"tracing"`
+
+	// Pre-signed download URLs, for clients that cannot send credentials in
+	// headers. Disabled while Secret is empty.
+	Presign struct {
+		Secret        string `yaml:"secret"` // HMAC key; changing it invalidates issued URLs
+		SecretFile    string `yaml:"secret_file"`
+		DefaultTTLSec int    `yaml:"default_ttl_sec"`
+		MaxTTLSec     int    `yaml:"max_ttl_sec"`
+		// DownloadURLs makes firmware listings (list, latest, search,
+		// by-sha256) return pre-signed downloadUrl values.
+		DownloadURLs bool `yaml:"download_urls"`
+	} `yaml:"presign"`
+
+	// Token-bucket rate limits per route class, applied per client IP and
+	// per authenticated identity. A class with PerMin 0 is not limited.
+	RateLimit struct {
+		Enabled  bool           `yaml:"enabled"`
+		Read     RateLimitClass `yaml:"read"`     // GET requests other than downloads
+		Download RateLimitClass `yaml:"download"` // GET /api/firmware/{type}/{version}
+		Write    RateLimitClass `yaml:"write"`    // uploads, deletes and other changes
+	} `yaml:"rate_limit"`
+
+	// Temporary bans of client IPs after Failures failed authentications
+	// within WindowSec. Disabled when Failures is 0.
+	Ban struct {
+		Failures    int `yaml:"failures"`
+		WindowSec   int `yaml:"window_sec"`
+		DurationSec int `yaml:"duration_sec"`
+	} `yaml:"ban"`
+
+	// Audit log housekeeping. Events older than RetentionDays are deleted
+	// (0 keeps them forever); at most FailuresPerMin authentication failures
+	// and denials are audited per client IP and minute (0 audits all).
+	Audit struct {
+		RetentionDays  int `yaml:"retention_days"`
+		FailuresPerMin int `yaml:"failures_per_min"`
+	} `yaml:"audit"`
+
+	// Download statistics, written in batches.
+	Stats struct {
+		Enabled       bool `yaml:"enabled"`
+		BatchSize     int  `yaml:"batch_size"`
+		FlushInterval int  `yaml:"flush_interval_sec"`
+	} `yaml:"stats"`
+
+	// Background storage scrub. Disabled when IntervalMin is 0.
+	Scrub struct {
+		IntervalMin int  `yaml:"interval_min"`
+		Repair      bool `yaml:"repair"` // delete dangling rows, orphaned and temp files
+	} `yaml:"scrub"`
+
+	// Strict makes configuration errors (see Validate) fatal at startup
+	// instead of logged, and OIDC initialization failures fatal instead of
+	// falling back to API keys.
+	Strict bool `yaml:"strict"`
+
+	// loadProblems collects settings that could not be applied while loading.
+	loadProblems []Problem
 }
 
 // Load reads YAML if path is non-empty, then applies env overrides.
@@ -69,9 +228,13 @@ func Load(path string) (Config, error) {
 		if err := yaml.Unmarshal(b, &cfg); err != nil {
 			return cfg, err
 		}
+		cfg.checkSecretConflicts()
 	}
 
 	applyEnv(&cfg)
+	if err := cfg.readSecretFiles(); err != nil {
+		return cfg, err
+	}
 	return cfg, nil
 }
 
@@ -83,6 +246,18 @@ func defaults() Config {
 	c.DBPath = "/data/db/firmware-registry.db"
 	c.MaxUploadMB = 50
 
+	c.Server.ReadHeaderTimeoutSec = 10
+	c.Server.ReadTimeoutSec = 300
+	c.Server.WriteTimeoutSec = 300
+	c.Server.IdleTimeoutSec = 120
+	c.Server.MaxHeaderBytes = 1 << 20
+	c.Server.ShutdownGraceSec = 30
+
+	c.Reload.WatchSec = 10
+
+	c.TLS.MinVersion = "1.2"
+	c.TLS.ReloadIntervalSec = 30
+
 	// Logging defaults
 	c.Logging.Level = "info"
 	c.Logging.Format = "json"
@@ -97,9 +272,39 @@ func defaults() Config {
 
 	c.Webhooks.TimeoutSec = 5
 	c.Webhooks.Retries = 3
+	c.Webhooks.Workers = 4
+	c.Webhooks.RetentionDays = 7
+
+	c.Tracing.Enabled = false
+	c.Tracing.Endpoint = "http://localhost:4318"
+	c.Tracing.ServiceName = "firmware-registry"
+	c.Tracing.SampleRatio = 1
+
+	c.Presign.DefaultTTLSec = 900
+	c.Presign.MaxTTLSec = 7 * 24 * 3600
+
+	c.RateLimit.Read = RateLimitClass{PerMin: 600, Burst: 60}
+	c.RateLimit.Download = RateLimitClass{PerMin: 60, Burst: 10}
+	c.RateLimit.Write = RateLimitClass{PerMin: 60, Burst: 20}
+
+	c.Ban.WindowSec = 60
+	c.Ban.DurationSec = 900
+
+	c.Audit.RetentionDays = 365
+	c.Audit.FailuresPerMin = 10
+
+	c.Stats.Enabled = true
+	c.Stats.BatchSize = 100
+	c.Stats.FlushInterval = 5
+
+	c.Scrub.IntervalMin = 0
+	c.Scrub.Repair = false
 
 	c.OIDC.Enabled = false
 	c.OIDC.JWKSCacheSec = 300
+
+	c.Introspection.RoleClaims = "scope,roles,groups"
+	c.Introspection.CacheSec = 60
 	return c
 }
 
@@ -108,36 +313,105 @@ func applyEnv(cfg *Config) {
 	setStr(&cfg.PublicBaseURL, "FW_PUBLIC_BASE_URL")
 	setStr(&cfg.StorageDir, "FW_STORAGE_DIR")
 	setStr(&cfg.DBPath, "FW_DB_PATH")
-	setStr(&cfg.AdminKey, "FW_ADMIN_KEY")
-	setStr(&cfg.DeviceKey, "FW_DEVICE_KEY")
+	cfg.applySecretEnv()
 	setStr(&cfg.NoAuthIPs, "FW_NOAUTH_IPS")
+	setStr(&cfg.DeviceNoAuthIPs, "FW_DEVICE_NOAUTH_IPS")
+	setStr(&cfg.AdminNetworks, "FW_ADMIN_NETWORKS")
+	cfg.setRouteNetworks("FW_ROUTE_NETWORKS")
+	setStr(&cfg.TrustedProxies, "FW_TRUSTED_PROXIES")
+	setStr(&cfg.ClientIPHeader, "FW_CLIENT_IP_HEADER")
+	cfg.setBool(&cfg.Strict, "FW_CONFIG_STRICT")
 
 	if v := os.Getenv("FW_MAX_UPLOAD_MB"); v != "" {
 		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
 			cfg.MaxUploadMB = n
+		} else {
+			cfg.envProblem("FW_MAX_UPLOAD_MB", v, "a positive integer")
 		}
 	}
 
-	setStr(&cfg.Webhooks.Secret, "FW_WEBHOOK_SECRET")
-	if v := os.Getenv("FW_WEBHOOK_TIMEOUT_SEC"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil && n > 0 {
-			cfg.Webhooks.TimeoutSec = n
-		}
-	}
-	if v := os.Getenv("FW_WEBHOOK_RETRIES"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
-			cfg.Webhooks.Retries = n
+	cfg.setInt(&cfg.Webhooks.TimeoutSec, "FW_WEBHOOK_TIMEOUT_SEC", 1)
+	cfg.setInt(&cfg.Webhooks.Retries, "FW_WEBHOOK_RETRIES", 0)
+	cfg.setInt(&cfg.Webhooks.Workers, "FW_WEBHOOK_WORKERS", 1)
+	cfg.setInt(&cfg.Webhooks.RetentionDays, "FW_WEBHOOK_RETENTION_DAYS", 0)
+
+	cfg.setInt(&cfg.Server.ReadHeaderTimeoutSec, "FW_HTTP_READ_HEADER_TIMEOUT_SEC", 0)
+	cfg.setInt(&cfg.Server.ReadTimeoutSec, "FW_HTTP_READ_TIMEOUT_SEC", 0)
+	cfg.setInt(&cfg.Server.WriteTimeoutSec, "FW_HTTP_WRITE_TIMEOUT_SEC", 0)
+	cfg.setInt(&cfg.Server.IdleTimeoutSec, "FW_HTTP_IDLE_TIMEOUT_SEC", 0)
+	cfg.setInt(&cfg.Server.ShutdownGraceSec, "FW_SHUTDOWN_GRACE_SEC", 0)
+	cfg.setInt(&cfg.Server.MaxHeaderBytes, "FW_HTTP_MAX_HEADER_BYTES", 1)
+
+	cfg.setInt(&cfg.Reload.WatchSec, "FW_CONFIG_WATCH_SEC", 0)
+
+	setStr(&cfg.TLS.CertFile, "FW_TLS_CERT_FILE")
+	setStr(&cfg.TLS.KeyFile, "FW_TLS_KEY_FILE")
+	setStr(&cfg.TLS.MinVersion, "FW_TLS_MIN_VERSION")
+	setStr(&cfg.TLS.CipherSuites, "FW_TLS_CIPHER_SUITES")
+	setStr(&cfg.TLS.RedirectAddr, "FW_TLS_REDIRECT_ADDR")
+	cfg.setInt(&cfg.TLS.ReloadIntervalSec, "FW_TLS_RELOAD_INTERVAL_SEC", 1)
+
+	cfg.setBool(&cfg.Metrics.Enabled, "FW_METRICS_ENABLED")
+
+	cfg.setBool(&cfg.Tracing.Enabled, "FW_TRACING_ENABLED")
+	setStr(&cfg.Tracing.Endpoint, "FW_TRACING_ENDPOINT")
+	setStr(&cfg.Tracing.ServiceName, "FW_TRACING_SERVICE_NAME")
+	if v := os.Getenv("FW_TRACING_SAMPLE_RATIO"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 && f <= 1 {
+			cfg.Tracing.SampleRatio = f
+		} else {
+			cfg.envProblem("FW_TRACING_SAMPLE_RATIO", v, "a number between 0 and 1")
 		}
 	}
 
-	if v := os.Getenv("FW_OIDC_ENABLED"); v != "" {
-		cfg.OIDC.Enabled = v == "1" || strings.ToLower(v) == "true"
+	cfg.setInt(&cfg.Presign.DefaultTTLSec, "FW_PRESIGN_DEFAULT_TTL_SEC", 1)
+	cfg.setInt(&cfg.Presign.MaxTTLSec, "FW_PRESIGN_MAX_TTL_SEC", 1)
+	cfg.setBool(&cfg.Presign.DownloadURLs, "FW_PRESIGN_DOWNLOAD_URLS")
+
+	cfg.setBool(&cfg.RateLimit.Enabled, "FW_RATE_LIMIT_ENABLED")
+	for env, class := range map[string]*RateLimitClass{
+		"READ":     &cfg.RateLimit.Read,
+		"DOWNLOAD": &cfg.RateLimit.Download,
+		"WRITE":    &cfg.RateLimit.Write,
+	} {
+		cfg.setInt(&class.PerMin, "FW_RATE_LIMIT_"+env+"_PER_MIN", 0)
+		cfg.setInt(&class.Burst, "FW_RATE_LIMIT_"+env+"_BURST", 1)
 	}
+
+	cfg.setInt(&cfg.Ban.Failures, "FW_BAN_FAILURES", 0)
+	cfg.setInt(&cfg.Ban.WindowSec, "FW_BAN_WINDOW_SEC", 1)
+	cfg.setInt(&cfg.Ban.DurationSec, "FW_BAN_DURATION_SEC", 1)
+
+	cfg.setInt(&cfg.Audit.RetentionDays, "FW_AUDIT_RETENTION_DAYS", 0)
+	cfg.setInt(&cfg.Audit.FailuresPerMin, "FW_AUDIT_FAILURES_PER_MIN", 0)
+
+	cfg.setBool(&cfg.Stats.Enabled, "FW_STATS_ENABLED")
+	cfg.setInt(&cfg.Stats.BatchSize, "FW_STATS_BATCH_SIZE", 1)
+	cfg.setInt(&cfg.Stats.FlushInterval, "FW_STATS_FLUSH_INTERVAL_SEC", 1)
+
+	cfg.setInt(&cfg.Scrub.IntervalMin, "FW_SCRUB_INTERVAL_MIN", 0)
+	cfg.setBool(&cfg.Scrub.Repair, "FW_SCRUB_REPAIR")
+
+	cfg.setBool(&cfg.OIDC.Enabled, "FW_OIDC_ENABLED")
 	setStr(&cfg.OIDC.IssuerURL, "FW_OIDC_ISSUER_URL")
 	setStr(&cfg.OIDC.ClientID, "FW_OIDC_CLIENT_ID")
 	setStr(&cfg.OIDC.Audience, "FW_OIDC_AUDIENCE")
 	setStr(&cfg.OIDC.AdminRole, "FW_OIDC_ADMIN_ROLE")
 	setStr(&cfg.OIDC.DeviceRole, "FW_OIDC_DEVICE_ROLE")
+	setStr(&cfg.OIDC.RoleClaims, "FW_OIDC_ROLE_CLAIMS")
+	setStr(&cfg.OIDC.JWKS, "FW_OIDC_JWKS")
+	setStr(&cfg.OIDC.JWKSCacheDir, "FW_OIDC_JWKS_CACHE_DIR")
+	cfg.setInt(&cfg.OIDC.JWKSCacheSec, "FW_OIDC_JWKS_CACHE_SEC", 1)
+
+	setStr(&cfg.Introspection.URL, "FW_INTROSPECTION_URL")
+	setStr(&cfg.Introspection.ClientID, "FW_INTROSPECTION_CLIENT_ID")
+	setStr(&cfg.Introspection.Audience, "FW_INTROSPECTION_AUDIENCE")
+	setStr(&cfg.Introspection.RoleClaims, "FW_INTROSPECTION_ROLE_CLAIMS")
+	setStr(&cfg.Introspection.AdminRole, "FW_INTROSPECTION_ADMIN_ROLE")
+	setStr(&cfg.Introspection.DeviceRole, "FW_INTROSPECTION_DEVICE_ROLE")
+	cfg.setInt(&cfg.Introspection.CacheSec, "FW_INTROSPECTION_CACHE_SEC", 0)
+
+	cfg.setRoles("FW_RBAC_ROLES")
 
 	// Logging configuration
 	setStr(&cfg.Logging.Level, "FW_LOG_LEVEL")
@@ -147,24 +421,163 @@ func applyEnv(cfg *Config) {
 	setStr(&cfg.Logging.SyslogAddr, "FW_LOG_SYSLOG_ADDR")
 	setStr(&cfg.Logging.SyslogNet, "FW_LOG_SYSLOG_NET")
 
-	if v := os.Getenv("FW_LOG_MAX_SIZE_MB"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil && n > 0 {
-			cfg.Logging.MaxSizeMB = n
+	cfg.setInt(&cfg.Logging.MaxSizeMB, "FW_LOG_MAX_SIZE_MB", 1)
+	cfg.setInt(&cfg.Logging.MaxBackups, "FW_LOG_MAX_BACKUPS", 0)
+	cfg.setInt(&cfg.Logging.MaxAgeDays, "FW_LOG_MAX_AGE_DAYS", 0)
+	cfg.setBool(&cfg.Logging.Compress, "FW_LOG_COMPRESS")
+}
+
+// RateLimitClass is a token bucket: PerMin requests per minute on average,
+// up to Burst at once.
+type RateLimitClass struct {
+	PerMin int `yaml:"per_min"`
+	Burst  int `yaml:"burst"`
+}
+
+// OIDCProvider is one accepted token issuer.
+type OIDCProvider struct {
+	IssuerURL string `yaml:"issuer_url"`
+	// ClientID and Audience are comma-separated. A token is accepted if its
+	// aud claim contains any of them, or its azp claim is one of the client
+	// IDs.
+	ClientID string `yaml:"client_id"`
+	Audience string `yaml:"audience"`
+	// RoleClaims lists comma-separated claim paths holding role and group
+	// names, e.g. "groups", "roles", "scp" or "resource_access.*.roles".
+	RoleClaims string `yaml:"role_claims"`
+	AdminRole  string `yaml:"admin_role"`
+	DeviceRole string `yaml:"device_role"`
+	// JWKS is a file or http(s) URL with the signing keys; it replaces
+	// discovery, e.g. in air-gapped setups.
+	JWKS string `yaml:"jwks"`
+}
+
+// BearerAuth reports whether bearer tokens are accepted (OIDC or
+// introspection).
+func (c Config) BearerAuth() bool {
+	return c.OIDC.Enabled || c.Introspection.URL != ""
+}
+
+// JWKSCacheDirOrDefault returns where fetched OIDC signing keys are cached.
+func (c Config) JWKSCacheDirOrDefault() string {
+	if c.OIDC.JWKSCacheDir != "" {
+		return c.OIDC.JWKSCacheDir
+	}
+	return filepath.Join(filepath.Dir(c.DBPath), "jwks-cache")
+}
+
+// DefaultRoleClaims covers Keycloak realm roles, client roles and groups.
+const DefaultRoleClaims = "realm_access.roles,resource_access.*.roles,groups"
+
+// OIDCProviders returns every configured issuer, the top-level one first.
+// Providers without role_claims use DefaultRoleClaims.
+func (c Config) OIDCProviders() []OIDCProvider {
+	var out []OIDCProvider
+	if c.OIDC.IssuerURL != "" || c.OIDC.ClientID != "" || c.OIDC.Audience != "" || c.OIDC.JWKS != "" {
+		out = append(out, c.OIDC.OIDCProvider)
+	}
+	out = append(out, c.OIDC.Providers...)
+	for i := range out {
+		if strings.TrimSpace(out[i].RoleClaims) == "" {
+			out[i].RoleClaims = DefaultRoleClaims
 		}
 	}
-	if v := os.Getenv("FW_LOG_MAX_BACKUPS"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
-			cfg.Logging.MaxBackups = n
+	return out
+}
+
+// setRoles replaces the RBAC roles with "name=grant,grant;name2=grant".
+func (c *Config) setRoles(key string) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	roles := make(map[string][]string)
+	for _, def := range strings.Split(v, ";") {
+		if strings.TrimSpace(def) == "" {
+			continue
+		}
+		name, grants, ok := strings.Cut(def, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			c.envProblem(key, v, "name=grant,grant;name2=grant")
+			return
+		}
+		var list []string
+		for _, g := range strings.Split(grants, ",") {
+			if g = strings.TrimSpace(g); g != "" {
+				list = append(list, g)
+			}
 		}
+		roles[name] = list
+	}
+	c.RBAC.Roles = roles
+}
+
+// setRouteNetworks replaces the route restrictions with
+// "route=net,net;route2=net", e.g. "DELETE /api/firmware/*=10.0.0.0/8".
+func (c *Config) setRouteNetworks(key string) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
 	}
-	if v := os.Getenv("FW_LOG_MAX_AGE_DAYS"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
-			cfg.Logging.MaxAgeDays = n
+	routes := make(map[string]string)
+	for _, def := range strings.Split(v, ";") {
+		if strings.TrimSpace(def) == "" {
+			continue
+		}
+		route, networks, ok := strings.Cut(def, "=")
+		route = strings.TrimSpace(route)
+		if !ok || route == "" {
+			c.envProblem(key, v, "route=net,net;route2=net")
+			return
 		}
+		routes[route] = networks
+	}
+	c.RouteNetworks = routes
+}
+
+// setInt applies an integer env override of at least min. Anything else
+// leaves dst unchanged and is reported by Validate.
+func (c *Config) setInt(dst *int, key string, min int) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
 	}
-	if v := os.Getenv("FW_LOG_COMPRESS"); v != "" {
-		cfg.Logging.Compress = v == "1" || strings.ToLower(v) == "true"
+	if n, err := strconv.Atoi(v); err == nil && n >= min {
+		*dst = n
+		return
 	}
+	want := "a non-negative integer"
+	if min > 0 {
+		want = "a positive integer"
+	}
+	c.envProblem(key, v, want)
+}
+
+// setBool applies a boolean env override (1/0, true/false, t/f).
+func (c *Config) setBool(dst *bool, key string) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	if b, err := strconv.ParseBool(v); err == nil {
+		*dst = b
+		return
+	}
+	c.envProblem(key, v, "true or false")
+}
+
+func (c *Config) envProblem(key, value, want string) {
+	c.loadProblems = append(c.loadProblems, Problem{
+		Severity: SeverityError,
+		Field:    key,
+		Message:  fmt.Sprintf("%q is not %s, ignored", value, want),
+	})
+}
+
+// TLSEnabled reports whether the server should listen on HTTPS.
+func (c Config) TLSEnabled() bool {
+	return c.TLS.CertFile != "" && c.TLS.KeyFile != ""
 }
 
 func setStr(dst *string, key string) {
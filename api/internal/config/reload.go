@@ -0,0 +1,158 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Reloader re-runs Load on SIGHUP and, if Interval is set, whenever the
+// config file changes. The new config is handed to Apply, which validates it
+// and swaps it into the running components; if Apply (or Load) fails the
+// previous config stays in effect. Validation errors always reject a reload,
+// whether or not strict mode is on.
+//
+// Only the admin and device keys, the no-auth and device allowlists, admin
+// and route networks, trusted proxies and the client IP header, RBAC roles,
+// webhook delivery settings and the log level are applied at runtime. Other
+// changes are reported and take effect after a restart.
+type Reloader struct {
+	Path     string // FW_CONFIG_FILE; may be empty (env only)
+	Interval time.Duration
+	Apply    func(Config) error
+
+	mu      sync.Mutex
+	startup Config
+	status  ReloadStatus
+	stamp   string
+}
+
+// ReloadStatus describes the config currently in effect.
+type ReloadStatus struct {
+	Source        string     `json:"source" example:"/etc/firmware-registry/config.yaml" doc:"Config file, or env when only environment variables are used"`
+	LoadedAt      time.Time  `json:"loadedAt" doc:"When the config in effect was loaded (startup or last successful reload)"`
+	Reloads       int        `json:"reloads" example:"2" doc:"Successful reloads since startup"`
+	LastAttemptAt *time.Time `json:"lastAttemptAt,omitempty"`
+	LastError     string     `json:"lastError,omitempty" doc:"Why the last attempt was rejected; empty if it succeeded"`
+	// RestartRequired is set when the config in effect differs from the
+	// startup config in settings that are only applied at startup.
+	RestartRequired bool `json:"restartRequired"`
+}
+
+// NewReloader starts tracking cfg, the config loaded at startup.
+func NewReloader(path string, interval time.Duration, cfg Config, apply func(Config) error) *Reloader {
+	source := path
+	if source == "" {
+		source = "env"
+	}
+	r := &Reloader{Path: path, Interval: interval, Apply: apply, startup: cfg}
+	r.status = ReloadStatus{Source: source, LoadedAt: time.Now().UTC()}
+	r.stamp = r.fileStamp()
+	return r
+}
+
+// Status returns a snapshot of the reload state.
+func (r *Reloader) Status() ReloadStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// Run handles SIGHUP and file changes until ctx is cancelled.
+func (r *Reloader) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if r.Path != "" && r.Interval > 0 {
+		ticker := time.NewTicker(r.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			log.Info().Msg("SIGHUP received, reloading config")
+			_ = r.Reload()
+		case <-tick:
+			if stamp := r.fileStamp(); stamp != r.stamp {
+				log.Info().Str("path", r.Path).Msg("Config file changed, reloading config")
+				_ = r.Reload()
+			}
+		}
+	}
+}
+
+// Reload loads the config again and applies it.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC()
+	r.status.LastAttemptAt = &now
+	r.stamp = r.fileStamp()
+
+	cfg, err := Load(r.Path)
+	if err == nil {
+		err = cfg.Validate().Errors().Err()
+	}
+	if err == nil {
+		err = r.Apply(cfg)
+	}
+	if err != nil {
+		r.status.LastError = err.Error()
+		log.Error().Err(err).Str("source", r.status.Source).Msg("Config reload rejected, keeping previous config")
+		return err
+	}
+
+	restart := !reflect.DeepEqual(cfg.static(), r.startup.static())
+	r.status.LoadedAt = now
+	r.status.Reloads++
+	r.status.LastError = ""
+	r.status.RestartRequired = restart
+
+	event := log.Info().Str("source", r.status.Source).Int("reloads", r.status.Reloads)
+	if restart {
+		event = event.Bool("restart_required", true)
+	}
+	event.Msg("Config reloaded")
+	return nil
+}
+
+// static returns c without the settings that Reloader applies at runtime.
+func (c Config) static() Config {
+	c.AdminKey, c.AdminKeyFile = "", ""
+	c.DeviceKey, c.DeviceKeyFile = "", ""
+	c.NoAuthIPs, c.DeviceNoAuthIPs, c.AdminNetworks = "", "", ""
+	c.RouteNetworks = nil
+	c.TrustedProxies, c.ClientIPHeader = "", ""
+	c.RBAC.Roles = nil
+	c.Webhooks.Secret, c.Webhooks.SecretFile = "", ""
+	c.Webhooks.TimeoutSec = 0
+	c.Webhooks.Retries = 0
+	c.Logging.Level = ""
+	c.loadProblems = nil
+	return c
+}
+
+func (r *Reloader) fileStamp() string {
+	if r.Path == "" {
+		return ""
+	}
+	fi, err := os.Stat(r.Path)
+	if err != nil {
+		return "missing"
+	}
+	return fi.ModTime().String() + "/" + strconv.FormatInt(fi.Size(), 10)
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// secret pairs a secret-bearing setting with its file variant, so secrets
+// can come from Docker/Kubernetes secret mounts instead of the environment.
+type secret struct {
+	field string // YAML key of the value; the file variant is field+"_file"
+	env   string // env var of the value; the file variant is env+"_FILE"
+	value *string
+	file  *string
+}
+
+func (c *Config) secrets() []secret {
+	return []secret{
+		{"admin_key", "FW_ADMIN_KEY", &c.AdminKey, &c.AdminKeyFile},
+		{"device_key", "FW_DEVICE_KEY", &c.DeviceKey, &c.DeviceKeyFile},
+		{"webhooks.secret", "FW_WEBHOOK_SECRET", &c.Webhooks.Secret, &c.Webhooks.SecretFile},
+		{"metrics.token", "FW_METRICS_TOKEN", &c.Metrics.Token, &c.Metrics.TokenFile},
+		{"introspection.client_secret", "FW_INTROSPECTION_CLIENT_SECRET", &c.Introspection.ClientSecret, &c.Introspection.ClientSecretFile},
+		{"presign.secret", "FW_PRESIGN_SECRET", &c.Presign.Secret, &c.Presign.SecretFile},
+	}
+}
+
+// checkSecretConflicts reports secrets given both inline and as a file in
+// the YAML file; the file wins.
+func (c *Config) checkSecretConflicts() {
+	for _, s := range c.secrets() {
+		if *s.value != "" && *s.file != "" {
+			c.loadProblems = append(c.loadProblems, Problem{
+				Severity: SeverityWarning,
+				Field:    s.field,
+				Message:  "both " + s.field + " and " + s.field + "_file are set, using the file",
+			})
+		}
+	}
+}
+
+// applySecretEnv applies FW_X and FW_X_FILE. Either overrides both YAML
+// forms; if both are set the file wins.
+func (c *Config) applySecretEnv() {
+	for _, s := range c.secrets() {
+		value := strings.TrimSpace(os.Getenv(s.env))
+		file := strings.TrimSpace(os.Getenv(s.env + "_FILE"))
+		switch {
+		case file != "":
+			*s.file = file
+			if value != "" {
+				c.loadProblems = append(c.loadProblems, Problem{
+					Severity: SeverityWarning,
+					Field:    s.env,
+					Message:  "both " + s.env + " and " + s.env + "_FILE are set, using the file",
+				})
+			}
+		case value != "":
+			*s.value = value
+			*s.file = ""
+		}
+	}
+}
+
+// readSecretFiles replaces every secret that has a file with the file's
+// content, minus trailing newlines. It runs on every Load, so a reload picks
+// up rotated secrets.
+func (c *Config) readSecretFiles() error {
+	for _, s := range c.secrets() {
+		if *s.file == "" {
+			continue
+		}
+		b, err := os.ReadFile(*s.file)
+		if err != nil {
+			return fmt.Errorf("reading %s_file: %w", s.field, err)
+		}
+		*s.value = strings.TrimRight(string(b), "\r\n")
+	}
+	return nil
+}
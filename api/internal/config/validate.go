@@ -0,0 +1,363 @@
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"firmware-registry-api/internal/clientip"
+	"firmware-registry-api/internal/rbac"
+)
+
+// Severity of a configuration problem.
+type Severity string
+
+const (
+	// SeverityError means a setting is unusable: it was ignored, or the
+	// feature it controls cannot work. Fatal at startup in strict mode.
+	SeverityError Severity = "error"
+	// SeverityWarning means the config works but is probably not intended.
+	SeverityWarning Severity = "warning"
+)
+
+// Problem is one finding of Validate. Field is the YAML key, or the env var
+// for overrides that could not be parsed.
+type Problem struct {
+	Severity Severity
+	Field    string
+	Message  string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s: %s", p.Severity, p.Field, p.Message)
+}
+
+// Problems is the result of Validate.
+type Problems []Problem
+
+// Errors returns only the problems of SeverityError.
+func (ps Problems) Errors() Problems {
+	var out Problems
+	for _, p := range ps {
+		if p.Severity == SeverityError {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Err joins all problems into one error, or returns nil if there are none.
+func (ps Problems) Err() error {
+	errs := make([]error, len(ps))
+	for i, p := range ps {
+		errs[i] = errors.New(p.String())
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks the whole config and returns every problem found, errors
+// and warnings alike, in a stable order.
+func (c Config) Validate() Problems {
+	ps := append(Problems{}, c.loadProblems...)
+	add := func(sev Severity, field, format string, args ...any) {
+		ps = append(ps, Problem{Severity: sev, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if _, _, err := net.SplitHostPort(c.ListenAddr); err != nil {
+		add(SeverityError, "listen_addr", "%q is not host:port (e.g. :8080)", c.ListenAddr)
+	}
+	if c.PublicBaseURL != "" {
+		if u, err := url.Parse(c.PublicBaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add(SeverityError, "public_base_url", "%q is not an absolute http(s) URL", c.PublicBaseURL)
+		}
+	}
+	if c.StorageDir == "" {
+		add(SeverityError, "storage_dir", "must not be empty")
+	}
+	if c.DBPath == "" {
+		add(SeverityError, "db_path", "must not be empty")
+	}
+	if c.MaxUploadMB <= 0 {
+		add(SeverityError, "max_upload_mb", "must be positive, got %d", c.MaxUploadMB)
+	}
+
+	// Credentials
+	for field, list := range map[string]string{
+		"noauth_ips":        c.NoAuthIPs,
+		"device_noauth_ips": c.DeviceNoAuthIPs,
+		"admin_networks":    c.AdminNetworks,
+	} {
+		if _, invalid := clientip.ParseNetworks(list); len(invalid) > 0 {
+			add(SeverityError, field, "not an IP address or CIDR subnet: %s", strings.Join(invalid, ", "))
+		}
+	}
+	if _, err := clientip.ParseRouteRules(c.RouteNetworks); err != nil {
+		add(SeverityError, "route_networks", "%v", err)
+	}
+	if _, err := clientip.ParseSettings(c.TrustedProxies, ""); err != nil {
+		add(SeverityError, "trusted_proxies", "%v", err)
+	}
+	if _, err := clientip.ParseSettings("", c.ClientIPHeader); err != nil {
+		add(SeverityError, "client_ip_header", "%v", err)
+	}
+	if c.AdminKey == "" && !c.BearerAuth() && strings.TrimSpace(c.NoAuthIPs) == "" {
+		add(SeverityError, "admin_key", "empty and OIDC and introspection disabled: nobody can upload or use admin endpoints")
+	}
+	if c.DeviceKey == "" && !c.BearerAuth() && strings.TrimSpace(c.NoAuthIPs+c.DeviceNoAuthIPs) == "" {
+		add(SeverityWarning, "device_key", "empty and OIDC and introspection disabled: devices cannot download firmware")
+	}
+	if c.AdminKey != "" && c.AdminKey == c.DeviceKey {
+		add(SeverityWarning, "device_key", "same as admin_key: every device has admin access")
+	}
+	for field, key := range map[string]string{"admin_key": c.AdminKey, "device_key": c.DeviceKey} {
+		if key != "" && len(key) < 16 {
+			add(SeverityWarning, field, "shorter than 16 characters")
+		}
+	}
+
+	if c.OIDC.Enabled {
+		providers := c.OIDCProviders()
+		if len(providers) == 0 {
+			add(SeverityError, "oidc.issuer_url", "required when OIDC is enabled")
+		}
+		if c.OIDC.JWKSCacheSec <= 0 {
+			add(SeverityError, "oidc.jwks_cache_sec", "must be positive, got %d", c.OIDC.JWKSCacheSec)
+		}
+		// The top-level provider, if configured, comes first.
+		top := len(providers) - len(c.OIDC.Providers)
+		issuers := make(map[string]bool)
+		for i, p := range providers {
+			field := "oidc."
+			if i >= top {
+				field = fmt.Sprintf("oidc.providers[%d].", i-top)
+			}
+			if p.IssuerURL == "" {
+				add(SeverityError, field+"issuer_url", "required when OIDC is enabled")
+			} else if issuers[p.IssuerURL] {
+				add(SeverityError, field+"issuer_url", "%q is configured more than once", p.IssuerURL)
+			}
+			issuers[p.IssuerURL] = true
+			if strings.TrimSpace(p.ClientID+p.Audience) == "" {
+				add(SeverityError, field+"client_id", "client_id or audience is required: tokens for any client would be accepted")
+			}
+			if p.JWKS != "" && !strings.HasPrefix(p.JWKS, "http://") && !strings.HasPrefix(p.JWKS, "https://") {
+				if _, err := os.Stat(strings.TrimPrefix(p.JWKS, "file://")); err != nil {
+					add(SeverityError, field+"jwks", "%v", err)
+				}
+			}
+			if p.AdminRole == "" {
+				add(SeverityWarning, field+"admin_role", "empty: every valid token is granted admin access")
+			}
+			if p.DeviceRole == "" {
+				add(SeverityWarning, field+"device_role", "empty: every valid token is granted device access")
+			}
+		}
+	}
+
+	if in := c.Introspection; in.URL != "" {
+		if u, err := url.Parse(in.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add(SeverityError, "introspection.url", "%q is not an absolute http(s) URL", in.URL)
+		} else if u.Scheme == "http" {
+			add(SeverityWarning, "introspection.url", "plain http: tokens and client credentials are sent unencrypted")
+		}
+		if in.ClientID == "" {
+			add(SeverityWarning, "introspection.client_id", "empty: requests to the introspection endpoint are not authenticated")
+		}
+		if in.CacheSec < 0 {
+			add(SeverityError, "introspection.cache_sec", "must not be negative, got %d", in.CacheSec)
+		}
+		if in.AdminRole == "" {
+			add(SeverityWarning, "introspection.admin_role", "empty: every active token is granted admin access")
+		}
+		if in.DeviceRole == "" {
+			add(SeverityWarning, "introspection.device_role", "empty: every active token is granted device access")
+		}
+	}
+
+	if _, err := rbac.ParseRoles(c.RBAC.Roles); err != nil {
+		add(SeverityError, "rbac.roles", "%v", err)
+	}
+
+	// HTTP server and TLS
+	for field, n := range map[string]int{
+		"server.read_header_timeout_sec": c.Server.ReadHeaderTimeoutSec,
+		"server.read_timeout_sec":        c.Server.ReadTimeoutSec,
+		"server.write_timeout_sec":       c.Server.WriteTimeoutSec,
+		"server.idle_timeout_sec":        c.Server.IdleTimeoutSec,
+		"server.shutdown_grace_sec":      c.Server.ShutdownGraceSec,
+		"reload.watch_sec":               c.Reload.WatchSec,
+		"scrub.interval_min":             c.Scrub.IntervalMin,
+		"audit.retention_days":           c.Audit.RetentionDays,
+		"audit.failures_per_min":         c.Audit.FailuresPerMin,
+	} {
+		if n < 0 {
+			add(SeverityError, field, "must not be negative, got %d", n)
+		}
+	}
+	if c.Server.MaxHeaderBytes <= 0 {
+		add(SeverityError, "server.max_header_bytes", "must be positive, got %d", c.Server.MaxHeaderBytes)
+	}
+	if c.Server.ReadHeaderTimeoutSec == 0 && c.Server.ReadTimeoutSec == 0 {
+		add(SeverityWarning, "server.read_header_timeout_sec", "no read timeouts: slow clients can hold connections open indefinitely")
+	}
+
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		add(SeverityError, "tls", "cert_file and key_file must be set together; serving plain HTTP")
+	}
+	if c.TLSEnabled() {
+		for field, name := range map[string]string{"tls.cert_file": c.TLS.CertFile, "tls.key_file": c.TLS.KeyFile} {
+			if _, err := os.Stat(name); err != nil {
+				add(SeverityError, field, "%v", err)
+			}
+		}
+		switch strings.TrimPrefix(c.TLS.MinVersion, "TLS") {
+		case "1.0", "1.1":
+			add(SeverityWarning, "tls.min_version", "TLS %s is deprecated", c.TLS.MinVersion)
+		case "1.2", "1.3":
+		default:
+			add(SeverityError, "tls.min_version", "%q is not 1.0, 1.1, 1.2 or 1.3", c.TLS.MinVersion)
+		}
+		if unknown := unknownCipherSuites(c.TLS.CipherSuites); len(unknown) > 0 {
+			add(SeverityError, "tls.cipher_suites", "unknown or insecure: %s", strings.Join(unknown, ", "))
+		}
+		if c.TLS.ReloadIntervalSec <= 0 {
+			add(SeverityError, "tls.reload_interval_sec", "must be positive, got %d", c.TLS.ReloadIntervalSec)
+		}
+	} else if c.TLS.RedirectAddr != "" {
+		add(SeverityWarning, "tls.redirect_addr", "ignored without cert_file and key_file")
+	}
+
+	// Logging
+	switch strings.ToLower(c.Logging.Level) {
+	case "trace", "debug", "info", "warn", "warning", "error", "fatal", "panic", "disabled":
+	default:
+		add(SeverityError, "logging.level", "%q is not one of trace, debug, info, warn, error, fatal, panic, disabled", c.Logging.Level)
+	}
+	switch strings.ToLower(c.Logging.Format) {
+	case "json", "console":
+	default:
+		add(SeverityWarning, "logging.format", "%q is not json or console, using json", c.Logging.Format)
+	}
+	switch strings.ToLower(c.Logging.Output) {
+	case "stdout", "syslog":
+	case "file", "multi":
+		if c.Logging.FilePath == "" {
+			add(SeverityError, "logging.file_path", "required for output %q", c.Logging.Output)
+		}
+	default:
+		add(SeverityError, "logging.output", "%q is not stdout, file, syslog or multi", c.Logging.Output)
+	}
+
+	// Webhooks, stats, tracing
+	if c.Webhooks.TimeoutSec <= 0 {
+		add(SeverityError, "webhooks.timeout_sec", "must be positive, got %d", c.Webhooks.TimeoutSec)
+	}
+	if c.Webhooks.Retries < 0 {
+		add(SeverityError, "webhooks.retries", "must not be negative, got %d", c.Webhooks.Retries)
+	}
+	if c.Webhooks.Workers <= 0 {
+		add(SeverityError, "webhooks.workers", "must be positive, got %d", c.Webhooks.Workers)
+	}
+	if c.Webhooks.RetentionDays < 0 {
+		add(SeverityError, "webhooks.retention_days", "must not be negative, got %d", c.Webhooks.RetentionDays)
+	}
+	if c.Webhooks.Secret == "" {
+		add(SeverityWarning, "webhooks.secret", "empty: webhook payloads are not signed")
+	}
+	if c.Metrics.Enabled && c.Metrics.Token == "" {
+		add(SeverityWarning, "metrics.token", "empty: /metrics is served without authentication")
+	}
+	if c.Stats.Enabled && (c.Stats.BatchSize <= 0 || c.Stats.FlushInterval <= 0) {
+		add(SeverityError, "stats", "batch_size and flush_interval_sec must be positive")
+	}
+	if c.Tracing.Enabled {
+		if u, err := url.Parse(c.Tracing.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			add(SeverityError, "tracing.endpoint", "%q is not an absolute URL", c.Tracing.Endpoint)
+		}
+	}
+	if c.Presign.Secret != "" {
+		if len(c.Presign.Secret) < 32 {
+			add(SeverityWarning, "presign.secret", "shorter than 32 characters")
+		}
+		if c.Presign.DefaultTTLSec <= 0 || c.Presign.DefaultTTLSec > c.Presign.MaxTTLSec {
+			add(SeverityError, "presign.default_ttl_sec", "must be between 1 and max_ttl_sec (%d), got %d", c.Presign.MaxTTLSec, c.Presign.DefaultTTLSec)
+		}
+		if c.Presign.DownloadURLs && c.PublicBaseURL == "" {
+			add(SeverityWarning, "presign.download_urls", "public_base_url is empty: listings carry no download URLs to sign")
+		}
+	} else if c.Presign.DownloadURLs {
+		add(SeverityWarning, "presign.download_urls", "presign.secret is empty: pre-signed URLs are disabled")
+	}
+	if c.RateLimit.Enabled {
+		for field, class := range map[string]RateLimitClass{
+			"rate_limit.read":     c.RateLimit.Read,
+			"rate_limit.download": c.RateLimit.Download,
+			"rate_limit.write":    c.RateLimit.Write,
+		} {
+			if class.PerMin < 0 {
+				add(SeverityError, field+".per_min", "must not be negative, got %d", class.PerMin)
+			}
+			if class.PerMin > 0 && class.Burst < 1 {
+				add(SeverityError, field+".burst", "must be at least 1, got %d", class.Burst)
+			}
+		}
+	}
+	if c.Ban.Failures < 0 {
+		add(SeverityError, "ban.failures", "must not be negative, got %d", c.Ban.Failures)
+	}
+	if c.Ban.Failures > 0 {
+		if c.Ban.WindowSec <= 0 {
+			add(SeverityError, "ban.window_sec", "must be positive, got %d", c.Ban.WindowSec)
+		}
+		if c.Ban.DurationSec <= 0 {
+			add(SeverityError, "ban.duration_sec", "must be positive, got %d", c.Ban.DurationSec)
+		}
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		add(SeverityError, "tracing.sample_ratio", "must be between 0 and 1, got %g", c.Tracing.SampleRatio)
+	}
+
+	sortProblems(ps)
+	return ps
+}
+
+// sortProblems orders errors before warnings, then by field, so that output
+// does not depend on map iteration order.
+func sortProblems(ps Problems) {
+	sort.SliceStable(ps, func(i, j int) bool {
+		if ps[i].Severity != ps[j].Severity {
+			return ps[i].Severity == SeverityError
+		}
+		return ps[i].Field < ps[j].Field
+	})
+}
+
+func unknownCipherSuites(list string) []string {
+	known := make(map[string]bool)
+	for _, c := range tls.CipherSuites() {
+		known[c.Name] = true
+	}
+	var unknown []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" && !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown
+}
+
+// Redacted returns a copy of c with secrets replaced, for display. The
+// secrets are those listed by secrets, so a new one is redacted as well.
+func (c Config) Redacted() Config {
+	for _, s := range c.secrets() {
+		if *s.value != "" {
+			*s.value = "[redacted]"
+		}
+	}
+	return c
+}
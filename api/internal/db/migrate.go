@@ -13,15 +13,27 @@ import (
 // RunMigrations applies all up migrations in migrationsDir.
 // Safe to call on every boot.
 func RunMigrations(dbPath, migrationsDir string) {
+	if err := Migrate(dbPath, migrationsDir); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("migrations OK")
+}
+
+// Migrate is RunMigrations without the fatal exit, for databases that are
+// not the live registry (e.g. a snapshot being imported).
+func Migrate(dbPath, migrationsDir string) error {
 	source := fmt.Sprintf("file://%s", filepath.Clean(migrationsDir))
 	dbURL := fmt.Sprintf("sqlite3://%s", filepath.Clean(dbPath))
 
 	m, err := migrate.New(source, dbURL)
 	if err != nil {
-		log.Fatal("migrate init failed:", err)
+		return fmt.Errorf("migrate init failed: %w", err)
 	}
+	defer func() {
+		_, _ = m.Close()
+	}()
 	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		log.Fatal("migrate up failed:", err)
+		return fmt.Errorf("migrate up failed: %w", err)
 	}
-	log.Println("migrations OK")
+	return nil
 }
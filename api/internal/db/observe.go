@@ -0,0 +1,24 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"firmware-registry-api/internal/metrics"
+	"firmware-registry-api/internal/tracing"
+)
+
+// Observe instruments one repository operation. It starts a span as a
+// child of ctx; the returned func ends it and records the query latency
+// metric:
+//
+//	ctx, done := db.Observe(ctx, "firmware.get")
+//	defer done()
+func Observe(ctx context.Context, query string) (context.Context, func()) {
+	start := time.Now()
+	ctx, span := tracing.StartQuery(ctx, query)
+	return ctx, func() {
+		span.End()
+		metrics.ObserveQuery(query, start)
+	}
+}
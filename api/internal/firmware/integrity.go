@@ -0,0 +1,278 @@
+package firmware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"firmware-registry-api/internal/tracing"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// IssueKind classifies a mismatch between the database and Storage.
+type IssueKind string
+
+const (
+	IssueMissingBinary    IssueKind = "missing_binary"    // DB row without firmware.bin
+	IssueOrphanBinary     IssueKind = "orphan_binary"     // firmware.bin without DB row
+	IssueTempFile         IssueKind = "temp_file"         // leftover *.tmp from an interrupted upload
+	IssueSizeMismatch     IssueKind = "size_mismatch"     // file size differs from size_bytes
+	IssueChecksumMismatch IssueKind = "checksum_mismatch" // file hash differs from sha256
+	IssueUnreadable       IssueKind = "unreadable_binary" // firmware.bin exists but cannot be read
+)
+
+// fsckGrace protects files written by uploads that are still in flight:
+// temp files and binaries younger than this are never reported.
+const fsckGrace = 10 * time.Minute
+
+// Issue is a single integrity problem found by Fsck.
+type Issue struct {
+	Kind     IssueKind `json:"kind" example:"missing_binary" doc:"Issue classification"`
+	Type     string    `json:"type,omitempty" example:"esp32-main" doc:"Firmware type, if known"`
+	Version  string    `json:"version,omitempty" example:"1.2.3" doc:"Firmware version, if known"`
+	Path     string    `json:"path,omitempty" doc:"Affected file or directory"`
+	Detail   string    `json:"detail,omitempty" doc:"Human readable description"`
+	Repaired bool      `json:"repaired" doc:"Whether repair mode fixed the issue"`
+}
+
+// FsckReport is the outcome of one integrity check.
+type FsckReport struct {
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	Repair     bool      `json:"repair" doc:"Whether repair mode was enabled"`
+	Checked    int       `json:"checked" doc:"Number of database rows verified"`
+	Issues     []Issue   `json:"issues"`
+}
+
+// Unrepaired returns the number of issues that are still present.
+func (r FsckReport) Unrepaired() int {
+	n := 0
+	for _, i := range r.Issues {
+		if !i.Repaired {
+			n++
+		}
+	}
+	return n
+}
+
+// Fsck cross-checks every database row against Storage and walks Storage
+// for files the database does not know about. With repair enabled, rows
+// without binaries are deleted and orphaned or temporary files are removed.
+// Size and checksum mismatches are only reported: the stored binary is
+// corrupt and has to be re-uploaded. Binaries that cannot be read for other
+// reasons than not existing (permissions, I/O errors) are only reported too,
+// so their rows are never deleted.
+func (s *Service) Fsck(ctx context.Context, repair bool) (FsckReport, error) {
+	ctx, span := tracing.Start(ctx, "firmware.fsck", attribute.Bool("fsck.repair", repair))
+	defer span.End()
+
+	report := FsckReport{
+		StartedAt: time.Now().UTC(),
+		Repair:    repair,
+		Issues:    []Issue{},
+	}
+
+	rows, err := s.Repo.ListAll(ctx)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Fsck failed to list firmware from database")
+		return report, err
+	}
+
+	known := make(map[string]bool, len(rows))
+	for _, f := range rows {
+		known[f.Type+"/"+f.Version] = true
+		report.Checked++
+
+		if issue, ok := s.checkBinary(f); ok {
+			if issue.Kind == IssueMissingBinary && repair {
+				if err := s.Repo.Delete(ctx, f.Type, f.Version); err != nil {
+					issue.Detail += "; repair failed: " + err.Error()
+				} else {
+					issue.Repaired = true
+				}
+			}
+			report.Issues = append(report.Issues, issue)
+		}
+	}
+
+	report.Issues = append(report.Issues, s.scanStorage(ctx, known, repair)...)
+	report.FinishedAt = time.Now().UTC()
+
+	for _, i := range report.Issues {
+		zerolog.Ctx(ctx).Warn().
+			Str("kind", string(i.Kind)).
+			Str("type", i.Type).
+			Str("version", i.Version).
+			Str("path", i.Path).
+			Str("detail", i.Detail).
+			Bool("repaired", i.Repaired).
+			Msg("Storage integrity issue")
+	}
+	zerolog.Ctx(ctx).Info().
+		Int("checked", report.Checked).
+		Int("issues", len(report.Issues)).
+		Int("unrepaired", report.Unrepaired()).
+		Bool("repair", repair).
+		Dur("duration_ms", report.FinishedAt.Sub(report.StartedAt)).
+		Msg("Storage integrity check finished")
+
+	return report, nil
+}
+
+// checkBinary verifies existence, size and checksum of a single firmware file.
+func (s *Service) checkBinary(f Firmware) (Issue, bool) {
+	path := s.Storage.FilePath(f.Type, f.Version)
+	issue := Issue{Type: f.Type, Version: f.Version, Path: path}
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		issue.Kind = IssueMissingBinary
+		issue.Detail = err.Error()
+		return issue, true
+	}
+	if err != nil {
+		issue.Kind = IssueUnreadable
+		issue.Detail = err.Error()
+		return issue, true
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	h := sha256.New()
+	n, err := io.Copy(h, file)
+	if err != nil {
+		issue.Kind = IssueUnreadable
+		issue.Detail = "read failed: " + err.Error()
+		return issue, true
+	}
+	if n != f.SizeBytes {
+		issue.Kind = IssueSizeMismatch
+		issue.Detail = "expected " + strconv.FormatInt(f.SizeBytes, 10) + " bytes, found " + strconv.FormatInt(n, 10)
+		return issue, true
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != f.SHA256 {
+		issue.Kind = IssueChecksumMismatch
+		issue.Detail = "expected sha256 " + f.SHA256 + ", found " + sum
+		return issue, true
+	}
+	return issue, false
+}
+
+// scanStorage walks {BaseDir}/{type}/{version} looking for binaries without a
+// database row and for leftover temp files.
+func (s *Service) scanStorage(ctx context.Context, known map[string]bool, repair bool) []Issue {
+	var issues []Issue
+	cutoff := time.Now().Add(-fsckGrace)
+
+	types, err := os.ReadDir(s.Storage.BaseDir)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Str("dir", s.Storage.BaseDir).Msg("Fsck failed to read storage directory")
+		return issues
+	}
+
+	for _, t := range types {
+		if !t.IsDir() {
+			continue
+		}
+		versions, err := os.ReadDir(filepath.Join(s.Storage.BaseDir, t.Name()))
+		if err != nil {
+			continue
+		}
+		for _, v := range versions {
+			if !v.IsDir() {
+				continue
+			}
+			dir := s.Storage.Dir(t.Name(), v.Name())
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				continue
+			}
+
+			for _, e := range entries {
+				if !strings.HasSuffix(e.Name(), ".tmp") || !olderThan(e, cutoff) {
+					continue
+				}
+				issue := Issue{
+					Kind:    IssueTempFile,
+					Type:    t.Name(),
+					Version: v.Name(),
+					Path:    filepath.Join(dir, e.Name()),
+					Detail:  "leftover temporary file from an interrupted upload",
+				}
+				if repair {
+					issue.Repaired = os.Remove(issue.Path) == nil
+				}
+				issues = append(issues, issue)
+			}
+
+			if known[t.Name()+"/"+v.Name()] {
+				continue
+			}
+			bin := s.Storage.FilePath(t.Name(), v.Name())
+			info, err := os.Stat(bin)
+			if err != nil || info.ModTime().After(cutoff) {
+				continue
+			}
+			issue := Issue{
+				Kind:    IssueOrphanBinary,
+				Type:    t.Name(),
+				Version: v.Name(),
+				Path:    dir,
+				Detail:  "binary has no database row",
+			}
+			if repair {
+				issue.Repaired = os.RemoveAll(dir) == nil
+			}
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+func olderThan(e os.DirEntry, cutoff time.Time) bool {
+	info, err := e.Info()
+	return err == nil && info.ModTime().Before(cutoff)
+}
+
+// Scrubber runs Fsck periodically in the background.
+type Scrubber struct {
+	Service  *Service
+	Interval time.Duration
+	Repair   bool
+	// OnReport is called after every run that found at least one issue.
+	OnReport func(context.Context, FsckReport)
+}
+
+// Run blocks until ctx is cancelled.
+func (s *Scrubber) Run(ctx context.Context) {
+	zerolog.Ctx(ctx).Info().
+		Dur("interval", s.Interval).
+		Bool("repair", s.Repair).
+		Msg("Background storage scrub enabled")
+
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := s.Service.Fsck(ctx, s.Repair)
+			if err != nil || len(report.Issues) == 0 || s.OnReport == nil {
+				continue
+			}
+			s.OnReport(ctx, report)
+		}
+	}
+}
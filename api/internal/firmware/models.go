@@ -9,6 +9,8 @@ type Firmware struct {
 	Filename  string
 	SizeBytes int64
 	SHA256    string
+	Channel   string
+	Labels    []string
 	CreatedAt time.Time
 }
 
@@ -19,18 +21,33 @@ type FirmwareDTO struct {
 	Filename    string    `json:"filename" example:"firmware.bin" doc:"Original filename"`
 	SizeBytes   int64     `json:"sizeBytes" example:"524288" doc:"File size in bytes"`
 	SHA256      string    `json:"sha256" example:"abc123..." doc:"SHA256 checksum"`
+	Channel     string    `json:"channel" example:"stable" doc:"Release channel"`
+	Labels      []string  `json:"labels" example:"nightly,qa-passed" doc:"Free-form release labels"`
 	CreatedAt   time.Time `json:"createdAt" example:"2024-01-15T10:30:00Z" doc:"Upload timestamp"`
 	DownloadURL string    `json:"downloadUrl,omitempty" example:"http://localhost:8080/api/firmware/esp32-main/1.2.3" doc:"Direct download URL"`
 }
 
 func (f Firmware) ToDTO(downloadURL string) FirmwareDTO {
+	labels := f.Labels
+	if labels == nil {
+		labels = []string{}
+	}
 	return FirmwareDTO{
 		Type:        f.Type,
 		Version:     f.Version,
 		Filename:    f.Filename,
 		SizeBytes:   f.SizeBytes,
 		SHA256:      f.SHA256,
+		Channel:     f.Channel,
+		Labels:      labels,
 		CreatedAt:   f.CreatedAt,
 		DownloadURL: downloadURL,
 	}
 }
+
+// TypeInventory is the number of releases and their total size for one type.
+type TypeInventory struct {
+	Type  string
+	Count int
+	Bytes int64
+}
@@ -0,0 +1,97 @@
+package firmware
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"time"
+)
+
+// DefaultChannel is assigned to uploads that do not name a channel.
+const DefaultChannel = "stable"
+
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// ErrInvalidQuery is returned for malformed filters or cursors.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// SortOrder selects the ordering of List results.
+type SortOrder string
+
+const (
+	SortVersionDesc SortOrder = "version_desc" // newest semver first (default)
+	SortVersionAsc  SortOrder = "version_asc"
+	SortCreatedDesc SortOrder = "created_desc"
+	SortCreatedAsc  SortOrder = "created_asc"
+)
+
+// ParseSortOrder validates a user supplied sort order; empty means version_desc.
+func ParseSortOrder(s string) (SortOrder, bool) {
+	switch SortOrder(s) {
+	case "":
+		return SortVersionDesc, true
+	case SortVersionDesc, SortVersionAsc, SortCreatedDesc, SortCreatedAsc:
+		return SortOrder(s), true
+	}
+	return "", false
+}
+
+// ListQuery filters and pages through the releases of one type.
+// Zero values mean "no filter".
+type ListQuery struct {
+	Type       string
+	MinVersion string // inclusive, semver only
+	MaxVersion string // inclusive, semver only
+	Since      time.Time
+	Until      time.Time
+	Channel    string
+	Label      string
+	Sort       SortOrder
+	Limit      int
+	Cursor     string // opaque, from a previous ListPage.NextCursor
+}
+
+// ListPage is one page of results plus the total matching the filters.
+type ListPage struct {
+	Items      []Firmware
+	Total      int
+	NextCursor string // empty on the last page
+}
+
+// cursor is the keyset position after the last item of a page.
+type cursor struct {
+	Sort      SortOrder `json:"s"`
+	Major     int       `json:"ma"`
+	Minor     int       `json:"mi"`
+	Patch     int       `json:"pa"`
+	Version   string    `json:"v"`
+	CreatedAt string    `json:"c"`
+}
+
+func (c cursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string, sort SortOrder) (cursor, error) {
+	var c cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidQuery
+	}
+	if err := json.Unmarshal(b, &c); err != nil || c.Sort != sort {
+		return c, ErrInvalidQuery
+	}
+	return c, nil
+}
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// ValidName reports whether s is usable as a channel or label.
+func ValidName(s string) bool {
+	return namePattern.MatchString(s)
+}
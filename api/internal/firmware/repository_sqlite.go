@@ -1,9 +1,16 @@
 package firmware
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"strings"
 	"time"
 
+	"firmware-registry-api/internal/db"
+	"firmware-registry-api/internal/util"
+
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
@@ -12,36 +19,79 @@ type SQLiteRepo struct {
 	DB *sql.DB
 }
 
-func (r *SQLiteRepo) Upsert(f Firmware) error {
-	_, err := r.DB.Exec(`
-INSERT INTO firmwares(type, version, filename, size_bytes, sha256, created_at)
-VALUES(?,?,?,?,?,?)
+// firmwareColumns is shared by every query that scans into Firmware.
+// Labels are folded into a comma separated list (labels cannot contain commas).
+const firmwareColumns = `
+f.type, f.version, f.filename, f.size_bytes, f.sha256, f.channel, f.created_at,
+COALESCE((SELECT group_concat(l.label, ',') FROM firmware_labels l
+          WHERE l.type = f.type AND l.version = f.version), ''),
+COALESCE(f.ver_major, -1), COALESCE(f.ver_minor, -1), COALESCE(f.ver_patch, -1)`
+
+const semverOrder = `f.ver_major %[1]s, f.ver_minor %[1]s, f.ver_patch %[1]s, f.version %[1]s`
+
+func (r *SQLiteRepo) Upsert(ctx context.Context, f Firmware) error {
+	ctx, done := db.Observe(ctx, "firmware.upsert")
+	defer done()
+
+	if f.Channel == "" {
+		f.Channel = DefaultChannel
+	}
+	key := versionKey(f.Version)
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(ctx, `
+INSERT INTO firmwares(type, version, filename, size_bytes, sha256, channel, created_at, ver_major, ver_minor, ver_patch)
+VALUES(?,?,?,?,?,?,?,?,?,?)
 ON CONFLICT(type, version) DO UPDATE SET
   filename=excluded.filename,
   size_bytes=excluded.size_bytes,
   sha256=excluded.sha256,
-  created_at=excluded.created_at
-`, f.Type, f.Version, f.Filename, f.SizeBytes, f.SHA256, f.CreatedAt.Format(time.RFC3339))
-	return err
+  channel=excluded.channel,
+  created_at=excluded.created_at,
+  ver_major=excluded.ver_major,
+  ver_minor=excluded.ver_minor,
+  ver_patch=excluded.ver_patch
+`, f.Type, f.Version, f.Filename, f.SizeBytes, f.SHA256, f.Channel, f.CreatedAt.Format(time.RFC3339),
+		key[0], key[1], key[2]); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM firmware_labels WHERE type=? AND version=?`, f.Type, f.Version); err != nil {
+		return err
+	}
+	for _, l := range f.Labels {
+		if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO firmware_labels(type, version, label) VALUES(?,?,?)`,
+			f.Type, f.Version, l); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
-func (r *SQLiteRepo) Get(typeName, version string) (Firmware, error) {
-	var f Firmware
-	var created string
-	err := r.DB.QueryRow(`
-SELECT type, version, filename, size_bytes, sha256, created_at
-FROM firmwares WHERE type=? AND version=?
-`, typeName, version).Scan(
-		&f.Type, &f.Version, &f.Filename, &f.SizeBytes, &f.SHA256, &created,
-	)
+func (r *SQLiteRepo) Get(ctx context.Context, typeName, version string) (Firmware, error) {
+	ctx, done := db.Observe(ctx, "firmware.get")
+	defer done()
+
+	f, _, err := scanFirmware(r.DB.QueryRowContext(ctx, `
+SELECT `+firmwareColumns+`
+FROM firmwares f WHERE f.type=? AND f.version=?
+`, typeName, version))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			log.Debug().
+			zerolog.Ctx(ctx).Debug().
 				Str("type", typeName).
 				Str("version", version).
 				Msg("Firmware not found in database")
 		} else {
-			log.Error().
+			zerolog.Ctx(ctx).Error().
 				Err(err).
 				Str("type", typeName).
 				Str("version", version).
@@ -49,15 +99,103 @@ FROM firmwares WHERE type=? AND version=?
 		}
 		return f, err
 	}
-	f.CreatedAt, _ = time.Parse(time.RFC3339, created)
 	return f, nil
 }
 
-func (r *SQLiteRepo) List(typeName string) ([]Firmware, error) {
-	rows, err := r.DB.Query(`
-SELECT type, version, filename, size_bytes, sha256, created_at
-FROM firmwares WHERE type=?
+// Latest returns the highest semver release of a type, optionally
+// restricted to one channel.
+func (r *SQLiteRepo) Latest(ctx context.Context, typeName, channel string) (Firmware, error) {
+	ctx, done := db.Observe(ctx, "firmware.latest")
+	defer done()
+
+	query := `SELECT ` + firmwareColumns + ` FROM firmwares f WHERE f.type=?`
+	args := []any{typeName}
+	if channel != "" {
+		query += ` AND f.channel=?`
+		args = append(args, channel)
+	}
+	query += ` ORDER BY ` + fmt.Sprintf(semverOrder, "DESC") + ` LIMIT 1`
+
+	f, _, err := scanFirmware(r.DB.QueryRowContext(ctx, query, args...))
+	return f, err
+}
+
+func (r *SQLiteRepo) List(ctx context.Context, typeName string) ([]Firmware, error) {
+	ctx, done := db.Observe(ctx, "firmware.list")
+	defer done()
+
+	rows, err := r.DB.QueryContext(ctx, `
+SELECT `+firmwareColumns+`
+FROM firmwares f WHERE f.type=?
 `, typeName)
+	if err != nil {
+		return nil, err
+	}
+	return scanFirmwares(rows)
+}
+
+// ListAll returns every firmware row across all types.
+func (r *SQLiteRepo) ListAll(ctx context.Context) ([]Firmware, error) {
+	ctx, done := db.Observe(ctx, "firmware.list_all")
+	defer done()
+
+	rows, err := r.DB.QueryContext(ctx, `
+SELECT `+firmwareColumns+`
+FROM firmwares f ORDER BY f.type, f.version
+`)
+	if err != nil {
+		return nil, err
+	}
+	return scanFirmwares(rows)
+}
+
+// FindBySHA256 returns every release whose binary has the given checksum.
+func (r *SQLiteRepo) FindBySHA256(ctx context.Context, sum string) ([]Firmware, error) {
+	ctx, done := db.Observe(ctx, "firmware.find_by_sha256")
+	defer done()
+
+	rows, err := r.DB.QueryContext(ctx, `
+SELECT `+firmwareColumns+`
+FROM firmwares f WHERE f.sha256=? ORDER BY f.type, `+fmt.Sprintf(semverOrder, "DESC"),
+		strings.ToLower(sum))
+	if err != nil {
+		return nil, err
+	}
+	return scanFirmwares(rows)
+}
+
+// Search finds releases where every whitespace-separated word of text occurs
+// as a case-insensitive substring of the type, version, filename, channel,
+// labels or checksum, so "32 main" finds esp32-main. Substrings cannot use
+// an index, so every release is scanned; registries hold few enough for that.
+//
+// When allow is not nil, releases of types it rejects are skipped while
+// reading, so a caller scoped to some types still gets up to limit results.
+func (r *SQLiteRepo) Search(ctx context.Context, text string, limit int, allow func(typeName string) bool) ([]Firmware, error) {
+	ctx, done := db.Observe(ctx, "firmware.search")
+	defer done()
+
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return nil, nil
+	}
+	where := make([]string, len(words))
+	args := make([]any, 0, len(words))
+	for i, w := range words {
+		where[i] = `f.search_text LIKE ? ESCAPE '\'`
+		args = append(args, "%"+likeEscaper.Replace(w)+"%")
+	}
+
+	rows, err := r.DB.QueryContext(ctx, `
+SELECT `+firmwareColumns+`
+FROM (
+  SELECT *, type || ' ' || version || ' ' || filename || ' ' || channel || ' ' || sha256 || ' ' ||
+    COALESCE((SELECT group_concat(l.label, ' ') FROM firmware_labels l
+              WHERE l.type = firmwares.type AND l.version = firmwares.version), '') AS search_text
+  FROM firmwares
+) f
+WHERE `+strings.Join(where, " AND ")+`
+ORDER BY f.type, `+fmt.Sprintf(semverOrder, "DESC"), args...)
 	if err != nil {
 		return nil, err
 	}
@@ -65,20 +203,261 @@ FROM firmwares WHERE type=?
 		_ = rows.Close()
 	}(rows)
 
+	var out []Firmware
+	for len(out) < limit && rows.Next() {
+		f, _, err := scanFirmware(rows)
+		if err != nil {
+			return nil, err
+		}
+		if allow == nil || allow(f.Type) {
+			out = append(out, f)
+		}
+	}
+	return out, rows.Err()
+}
+
+// likeEscaper escapes the LIKE wildcards for ESCAPE '\'.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Query pages through one type with filtering and sorting done in SQL.
+// Pagination is keyset based, so deep pages cost the same as the first.
+func (r *SQLiteRepo) Query(ctx context.Context, q ListQuery) (ListPage, error) {
+	ctx, done := db.Observe(ctx, "firmware.query")
+	defer done()
+
+	var page ListPage
+	if q.Sort == "" {
+		q.Sort = SortVersionDesc
+	}
+
+	where := []string{"f.type = ?"}
+	args := []any{q.Type}
+
+	if q.MinVersion != "" {
+		v, ok := util.ParseSemver(q.MinVersion)
+		if !ok {
+			return page, ErrInvalidQuery
+		}
+		where = append(where, "(f.ver_major, f.ver_minor, f.ver_patch) >= (?, ?, ?)")
+		args = append(args, v[0], v[1], v[2])
+	}
+	if q.MaxVersion != "" {
+		v, ok := util.ParseSemver(q.MaxVersion)
+		if !ok {
+			return page, ErrInvalidQuery
+		}
+		where = append(where, "f.ver_major >= 0", "(f.ver_major, f.ver_minor, f.ver_patch) <= (?, ?, ?)")
+		args = append(args, v[0], v[1], v[2])
+	}
+	if !q.Since.IsZero() {
+		where = append(where, "f.created_at >= ?")
+		args = append(args, q.Since.UTC().Format(time.RFC3339))
+	}
+	if !q.Until.IsZero() {
+		where = append(where, "f.created_at <= ?")
+		args = append(args, q.Until.UTC().Format(time.RFC3339))
+	}
+	if q.Channel != "" {
+		where = append(where, "f.channel = ?")
+		args = append(args, q.Channel)
+	}
+	if q.Label != "" {
+		where = append(where, `EXISTS (SELECT 1 FROM firmware_labels l
+  WHERE l.type = f.type AND l.version = f.version AND l.label = ?)`)
+		args = append(args, q.Label)
+	}
+
+	if err := r.DB.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM firmwares f WHERE `+strings.Join(where, " AND "), args...,
+	).Scan(&page.Total); err != nil {
+		return page, err
+	}
+
+	dir, cmp := "DESC", "<"
+	if q.Sort == SortVersionAsc || q.Sort == SortCreatedAsc {
+		dir, cmp = "ASC", ">"
+	}
+	byCreated := q.Sort == SortCreatedDesc || q.Sort == SortCreatedAsc
+
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor, q.Sort)
+		if err != nil {
+			return page, err
+		}
+		if byCreated {
+			where = append(where, "(f.created_at, f.version) "+cmp+" (?, ?)")
+			args = append(args, c.CreatedAt, c.Version)
+		} else {
+			where = append(where, "(f.ver_major, f.ver_minor, f.ver_patch, f.version) "+cmp+" (?, ?, ?, ?)")
+			args = append(args, c.Major, c.Minor, c.Patch, c.Version)
+		}
+	}
+
+	order := fmt.Sprintf(semverOrder, dir)
+	if byCreated {
+		order = "f.created_at " + dir + ", f.version " + dir
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	rows, err := r.DB.QueryContext(ctx, `SELECT `+firmwareColumns+` FROM firmwares f WHERE `+
+		strings.Join(where, " AND ")+` ORDER BY `+order+` LIMIT ?`, append(args, limit+1)...)
+	if err != nil {
+		return page, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var last [3]int
+	for rows.Next() {
+		if len(page.Items) == limit {
+			f := page.Items[len(page.Items)-1]
+			page.NextCursor = cursor{
+				Sort:      q.Sort,
+				Major:     last[0],
+				Minor:     last[1],
+				Patch:     last[2],
+				Version:   f.Version,
+				CreatedAt: f.CreatedAt.UTC().Format(time.RFC3339),
+			}.encode()
+			break
+		}
+		f, key, err := scanFirmware(rows)
+		if err != nil {
+			return page, err
+		}
+		page.Items = append(page.Items, f)
+		last = key
+	}
+	return page, rows.Err()
+}
+
+// Inventory returns the number of releases and total binary size per type.
+func (r *SQLiteRepo) Inventory(ctx context.Context) ([]TypeInventory, error) {
+	ctx, done := db.Observe(ctx, "firmware.inventory")
+	defer done()
+
+	rows, err := r.DB.QueryContext(ctx, `SELECT type, COUNT(*), COALESCE(SUM(size_bytes), 0) FROM firmwares GROUP BY type`)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var out []TypeInventory
+	for rows.Next() {
+		var t TypeInventory
+		if err := rows.Scan(&t.Type, &t.Count, &t.Bytes); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// BackfillVersionKeys computes semver sort keys for rows written before
+// they existed. Cheap no-op once every row has been processed.
+func (r *SQLiteRepo) BackfillVersionKeys() error {
+	rows, err := r.DB.Query(`SELECT type, version FROM firmwares WHERE ver_major IS NULL`)
+	if err != nil {
+		return err
+	}
+	var pending [][2]string
+	for rows.Next() {
+		var t, v string
+		if err := rows.Scan(&t, &v); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		pending = append(pending, [2]string{t, v})
+	}
+	_ = rows.Close()
+
+	for _, p := range pending {
+		key := versionKey(p[1])
+		if _, err := r.DB.Exec(`UPDATE firmwares SET ver_major=?, ver_minor=?, ver_patch=? WHERE type=? AND version=?`,
+			key[0], key[1], key[2], p[0], p[1]); err != nil {
+			return err
+		}
+	}
+	if len(pending) > 0 {
+		log.Info().Int("rows", len(pending)).Msg("Backfilled firmware version sort keys")
+	}
+	return nil
+}
+
+func (r *SQLiteRepo) Delete(ctx context.Context, typeName, version string) error {
+	ctx, done := db.Observe(ctx, "firmware.delete")
+	defer done()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM firmware_labels WHERE type=? AND version=?`, typeName, version); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM firmwares WHERE type=? AND version=?`, typeName, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanFirmware reads one row selected with firmwareColumns and also returns
+// its semver sort key.
+func scanFirmware(row rowScanner) (Firmware, [3]int, error) {
+	var f Firmware
+	var key [3]int
+	var created, labels string
+	err := row.Scan(&f.Type, &f.Version, &f.Filename, &f.SizeBytes, &f.SHA256, &f.Channel, &created,
+		&labels, &key[0], &key[1], &key[2])
+	if err != nil {
+		return f, key, err
+	}
+	f.CreatedAt, _ = time.Parse(time.RFC3339, created)
+	if labels != "" {
+		f.Labels = strings.Split(labels, ",")
+	}
+	return f, key, nil
+}
+
+func scanFirmwares(rows *sql.Rows) ([]Firmware, error) {
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
 	var out []Firmware
 	for rows.Next() {
-		var f Firmware
-		var created string
-		if err := rows.Scan(&f.Type, &f.Version, &f.Filename, &f.SizeBytes, &f.SHA256, &created); err != nil {
-			continue
+		f, _, err := scanFirmware(rows)
+		if err != nil {
+			return nil, err
 		}
-		f.CreatedAt, _ = time.Parse(time.RFC3339, created)
 		out = append(out, f)
 	}
-	return out, nil
+	return out, rows.Err()
 }
 
-func (r *SQLiteRepo) Delete(typeName, version string) error {
-	_, err := r.DB.Exec(`DELETE FROM firmwares WHERE type=? AND version=?`, typeName, version)
-	return err
+// versionKey maps a version to its sort key; non-semver versions sort
+// below every semver release.
+func versionKey(version string) [3]int {
+	if v, ok := util.ParseSemver(version); ok {
+		return v
+	}
+	return [3]int{-1, -1, -1}
 }
@@ -1,22 +1,34 @@
 package firmware
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"strings"
 	"time"
 
-	"github.com/rs/zerolog/log"
+	"firmware-registry-api/internal/tracing"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Repository persists firmware metadata.
 type Repository interface {
-	Upsert(Firmware) error
-	Get(typeName, version string) (Firmware, error)
-	List(typeName string) ([]Firmware, error)
-	Delete(typeName, version string) error
+	Upsert(ctx context.Context, f Firmware) error
+	Get(ctx context.Context, typeName, version string) (Firmware, error)
+	List(ctx context.Context, typeName string) ([]Firmware, error)
+	ListAll(ctx context.Context) ([]Firmware, error)
+	Query(ctx context.Context, q ListQuery) (ListPage, error)
+	Latest(ctx context.Context, typeName, channel string) (Firmware, error)
+	FindBySHA256(ctx context.Context, sum string) ([]Firmware, error)
+	Search(ctx context.Context, text string, limit int, allow func(typeName string) bool) ([]Firmware, error)
+	Inventory(ctx context.Context) ([]TypeInventory, error)
+	Delete(ctx context.Context, typeName, version string) error
 }
 
 // Service holds business logic only.
@@ -26,18 +38,55 @@ type Service struct {
 	PublicBase string
 }
 
+// ErrInvalidRelease is returned by SaveFirmware for unusable metadata.
+var ErrInvalidRelease = errors.New("invalid release metadata")
+
+// ReleaseInfo is the optional metadata supplied with an upload.
+type ReleaseInfo struct {
+	Channel string
+	Labels  []string
+}
+
 // SaveFirmware reads the uploaded binary, computes SHA256,
 // writes to disk atomically, and upserts metadata.
-func (s *Service) SaveFirmware(typeName, version, filename string, r io.Reader) (Firmware, error) {
-	log.Info().
+func (s *Service) SaveFirmware(ctx context.Context, typeName, version, filename string, info ReleaseInfo, r io.Reader) (_ Firmware, err error) {
+	ctx, span := tracing.Start(ctx, "firmware.save",
+		attribute.String("firmware.type", typeName),
+		attribute.String("firmware.version", version),
+	)
+	defer func() {
+		if err != nil {
+			tracing.Fail(span, err)
+		}
+		span.End()
+	}()
+
+	zerolog.Ctx(ctx).Info().
 		Str("type", typeName).
 		Str("version", version).
 		Str("filename", filename).
+		Str("channel", info.Channel).
+		Strs("labels", info.Labels).
 		Msg("Starting firmware upload")
 
+	if info.Channel == "" {
+		info.Channel = DefaultChannel
+	}
+	if !ValidName(info.Channel) {
+		return Firmware{}, fmt.Errorf("%w: channel %q", ErrInvalidRelease, info.Channel)
+	}
+	for _, l := range info.Labels {
+		if !ValidName(l) {
+			return Firmware{}, fmt.Errorf("%w: label %q", ErrInvalidRelease, l)
+		}
+	}
+
+	_, readSpan := tracing.Start(ctx, "firmware.read")
 	data, err := io.ReadAll(r)
+	readSpan.SetAttributes(attribute.Int("firmware.size_bytes", len(data)))
+	readSpan.End()
 	if err != nil {
-		log.Error().
+		zerolog.Ctx(ctx).Error().
 			Err(err).
 			Str("type", typeName).
 			Str("version", version).
@@ -45,82 +94,129 @@ func (s *Service) SaveFirmware(typeName, version, filename string, r io.Reader)
 		return Firmware{}, err
 	}
 
+	_, hashSpan := tracing.Start(ctx, "firmware.hash")
 	sum := sha256.Sum256(data)
 	shaHex := hex.EncodeToString(sum[:])
+	hashSpan.End()
 
-	log.Debug().
+	zerolog.Ctx(ctx).Debug().
 		Str("type", typeName).
 		Str("version", version).
 		Int64("size_bytes", int64(len(data))).
 		Str("sha256", shaHex).
 		Msg("Firmware SHA256 computed")
 
+	dest := s.Storage.FilePath(typeName, version)
+	if err := s.writeBinary(ctx, typeName, version, data); err != nil {
+		return Firmware{}, err
+	}
+
+	zerolog.Ctx(ctx).Debug().
+		Str("type", typeName).
+		Str("version", version).
+		Str("file", dest).
+		Msg("Firmware file written to storage")
+
+	rec := Firmware{
+		Type:      typeName,
+		Version:   version,
+		Filename:  filename,
+		SizeBytes: int64(len(data)),
+		SHA256:    shaHex,
+		Channel:   info.Channel,
+		Labels:    info.Labels,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err := s.Repo.Upsert(ctx, rec); err != nil {
+		zerolog.Ctx(ctx).Error().
+			Err(err).
+			Str("type", typeName).
+			Str("version", version).
+			Msg("Failed to upsert firmware metadata to database")
+		return Firmware{}, err
+	}
+
+	zerolog.Ctx(ctx).Info().
+		Str("type", typeName).
+		Str("version", version).
+		Str("filename", filename).
+		Int64("size_bytes", rec.SizeBytes).
+		Str("sha256", rec.SHA256).
+		Msg("Firmware uploaded successfully")
+
+	return rec, nil
+}
+
+// writeBinary stores data atomically (temporary file, then rename).
+func (s *Service) writeBinary(ctx context.Context, typeName, version string, data []byte) error {
+	_, span := tracing.Start(ctx, "firmware.write")
+	defer span.End()
+
 	dir := s.Storage.Dir(typeName, version)
 	if err := os.MkdirAll(dir, 0o755); err != nil {
-		log.Error().
+		zerolog.Ctx(ctx).Error().
 			Err(err).
 			Str("type", typeName).
 			Str("version", version).
 			Str("dir", dir).
 			Msg("Failed to create storage directory")
-		return Firmware{}, err
+		return err
 	}
 
 	dest := s.Storage.FilePath(typeName, version)
 	tmp := dest + ".tmp"
 	if err := os.WriteFile(tmp, data, 0o644); err != nil {
-		log.Error().
+		zerolog.Ctx(ctx).Error().
 			Err(err).
 			Str("type", typeName).
 			Str("version", version).
 			Str("tmp_file", tmp).
 			Msg("Failed to write temporary firmware file")
-		return Firmware{}, err
+		return err
 	}
 	if err := os.Rename(tmp, dest); err != nil {
-		log.Error().
+		zerolog.Ctx(ctx).Error().
 			Err(err).
 			Str("type", typeName).
 			Str("version", version).
 			Str("tmp_file", tmp).
 			Str("dest_file", dest).
 			Msg("Failed to rename firmware file (atomic write)")
-		return Firmware{}, err
+		return err
 	}
+	return nil
+}
 
-	log.Debug().
-		Str("type", typeName).
-		Str("version", version).
-		Str("file", dest).
-		Msg("Firmware file written to storage")
-
-	rec := Firmware{
-		Type:      typeName,
-		Version:   version,
-		Filename:  filename,
-		SizeBytes: int64(len(data)),
-		SHA256:    shaHex,
-		CreatedAt: time.Now().UTC(),
+// DeleteFirmware removes the metadata row first and the binary second, so a
+// failure half way leaves an orphaned file (which Fsck can clean up) rather
+// than a row pointing at nothing.
+func (s *Service) DeleteFirmware(ctx context.Context, typeName, version string) error {
+	if err := s.Repo.Delete(ctx, typeName, version); err != nil {
+		zerolog.Ctx(ctx).Error().
+			Err(err).
+			Str("type", typeName).
+			Str("version", version).
+			Msg("Failed to delete firmware metadata from database")
+		return err
 	}
 
-	if err := s.Repo.Upsert(rec); err != nil {
-		log.Error().
+	dir := s.Storage.Dir(typeName, version)
+	if err := os.RemoveAll(dir); err != nil {
+		zerolog.Ctx(ctx).Error().
 			Err(err).
 			Str("type", typeName).
 			Str("version", version).
-			Msg("Failed to upsert firmware metadata to database")
-		return Firmware{}, err
+			Str("dir", dir).
+			Msg("Failed to remove firmware binary")
+		return err
 	}
 
-	log.Info().
+	zerolog.Ctx(ctx).Info().
 		Str("type", typeName).
 		Str("version", version).
-		Str("filename", filename).
-		Int64("size_bytes", rec.SizeBytes).
-		Str("sha256", rec.SHA256).
-		Msg("Firmware uploaded successfully")
-
-	return rec, nil
+		Msg("Firmware deleted")
+	return nil
 }
 
 func (s *Service) DownloadPath(typeName, version string) string {
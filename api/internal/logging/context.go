@@ -0,0 +1,45 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// RequestIDHeader is accepted from clients and echoed on every response.
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDPattern limits client supplied IDs to something safe to log
+// and echo back.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type requestIDKey struct{}
+
+// RequestID returns the ID of the request ctx belongs to, if any.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// With adds fields to the logger in ctx. The request logger installed by
+// HTTPLogger is updated in place, so the fields also appear on the request
+// completion line; outside a request a derived logger is stored in the
+// returned context instead.
+func With(ctx context.Context, update func(zerolog.Context) zerolog.Context) context.Context {
+	if l := zerolog.Ctx(ctx); l != zerolog.DefaultContextLogger && l.GetLevel() != zerolog.Disabled {
+		l.UpdateContext(update)
+		return ctx
+	}
+	l := update(log.Logger.With()).Logger()
+	return l.WithContext(ctx)
+}
@@ -53,6 +53,8 @@ func Setup(cfg config.Config) error {
 
 	// Set global logger
 	log.Logger = zerolog.New(writer).With().Timestamp().Caller().Logger()
+	// zerolog.Ctx falls back to the global logger outside of requests
+	zerolog.DefaultContextLogger = &log.Logger
 
 	log.Info().
 		Str("level", cfg.Logging.Level).
@@ -63,6 +65,17 @@ func Setup(cfg config.Config) error {
 	return nil
 }
 
+// SetLevel changes the global log level at runtime. Output and format are
+// fixed at startup.
+func SetLevel(level string) error {
+	l, err := parseLevel(level)
+	if err != nil {
+		return fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+	zerolog.SetGlobalLevel(l)
+	return nil
+}
+
 func parseLevel(level string) (zerolog.Level, error) {
 	switch strings.ToLower(level) {
 	case "trace":
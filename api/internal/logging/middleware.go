@@ -1,10 +1,19 @@
 package logging
 
 import (
+	"context"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
+	"firmware-registry-api/internal/clientip"
+	"firmware-registry-api/internal/metrics"
+	"firmware-registry-api/internal/presign"
+
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // responseWriter wraps http.ResponseWriter to capture status code
@@ -25,11 +34,33 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
-// HTTPLogger logs HTTP requests with response status, duration, and size
+// HTTPLogger logs HTTP requests with response status, duration, and size,
+// and records them in the HTTP request metrics.
+//
+// Every request gets an ID, taken from X-Request-ID if the client sent a
+// usable one and generated otherwise, which is echoed in the response. A
+// logger carrying the ID is stored in the request context; code handling
+// the request should log through zerolog.Ctx(ctx).
 func HTTPLogger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
+		id := r.Header.Get(RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		logCtx := log.Logger.With().Str("request_id", id)
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			logCtx = logCtx.Str("trace_id", sc.TraceID().String())
+		}
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		r = r.WithContext(logCtx.Logger().WithContext(ctx))
+		// The logger stored in the context, so fields added while handling
+		// the request (see With) also end up on the completion line.
+		logger := zerolog.Ctx(r.Context())
+
 		// Wrap response writer to capture status
 		wrapped := &responseWriter{
 			ResponseWriter: w,
@@ -38,11 +69,12 @@ func HTTPLogger(next http.Handler) http.Handler {
 		}
 
 		// Log request start
-		log.Debug().
+		logger.Debug().
 			Str("method", r.Method).
 			Str("path", r.URL.Path).
-			Str("query", r.URL.RawQuery).
+			Str("query", loggedQuery(r)).
 			Str("remote_addr", r.RemoteAddr).
+			Str("client_ip", clientip.String(r)).
 			Str("user_agent", r.UserAgent()).
 			Msg("HTTP request received")
 
@@ -51,25 +83,53 @@ func HTTPLogger(next http.Handler) http.Handler {
 
 		// Calculate duration
 		duration := time.Since(start)
+		route := metrics.Route(r.URL.Path)
+		metrics.ObserveHTTP(route, r.Method, wrapped.statusCode, duration)
 
 		// Determine log level based on status code
-		event := log.Info()
+		event := logger.Info()
 		if wrapped.statusCode >= 500 {
-			event = log.Error()
+			event = logger.Error()
 		} else if wrapped.statusCode >= 400 {
-			event = log.Warn()
+			event = logger.Warn()
 		}
 
 		// Log request completion
 		event.
 			Str("method", r.Method).
 			Str("path", r.URL.Path).
-			Str("query", r.URL.RawQuery).
+			Str("route", route).
+			Str("query", loggedQuery(r)).
 			Int("status", wrapped.statusCode).
 			Dur("duration_ms", duration).
 			Int64("bytes", wrapped.written).
 			Str("remote_addr", r.RemoteAddr).
+			Str("client_ip", clientip.String(r)).
 			Str("user_agent", r.UserAgent()).
 			Msg("HTTP request completed")
 	})
 }
+
+// redactedParams are query parameters that work as credentials: a logged
+// pre-signed URL could be replayed until it expires.
+var redactedParams = map[string]bool{
+	presign.ParamSignature: true,
+	presign.ParamNonce:     true,
+}
+
+// loggedQuery returns the raw query of r with the values of redactedParams
+// replaced, keeping everything else as sent.
+func loggedQuery(r *http.Request) string {
+	raw := r.URL.RawQuery
+	if raw == "" {
+		return ""
+	}
+	parts := strings.Split(raw, "&")
+	for i, part := range parts {
+		key, _, _ := strings.Cut(part, "=")
+		if k, err := url.QueryUnescape(key); err == nil && redactedParams[k] {
+			parts[i] = key + "=REDACTED"
+		}
+	}
+	return strings.Join(parts, "&")
+}
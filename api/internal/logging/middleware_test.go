@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func TestHTTPLoggerRedactsPresignParams(t *testing.T) {
+	var buf bytes.Buffer
+	prevLogger, prevLevel := log.Logger, zerolog.GlobalLevel()
+	log.Logger = zerolog.New(&buf)
+	zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	t.Cleanup(func() {
+		log.Logger = prevLogger
+		zerolog.SetGlobalLevel(prevLevel)
+	})
+
+	const (
+		sig   = "LIosVpZh9O_G3P4W6mhpk3_D47Q5wbWLh8SEmZ2-zGg"
+		nonce = "94FfQwAlX8EiiR5z5oVlww"
+	)
+	h := HTTPLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet,
+		"/api/firmware/esp32-main/1.2.3?expires=1767225600&nonce="+nonce+"&sig="+sig+"&device_id=dev-1", nil))
+
+	out := buf.String()
+	if strings.Count(out, "HTTP request") != 2 {
+		t.Fatalf("want the request and completion lines, got:\n%s", out)
+	}
+	for _, secret := range []string{sig, nonce} {
+		if strings.Contains(out, secret) {
+			t.Errorf("log output contains %q:\n%s", secret, out)
+		}
+	}
+	if want := "expires=1767225600&nonce=REDACTED&sig=REDACTED&device_id=dev-1"; strings.Count(out, want) != 2 {
+		t.Errorf("log output does not contain query %q twice:\n%s", want, out)
+	}
+}
+
+func TestLoggedQuery(t *testing.T) {
+	tests := []struct{ query, want string }{
+		{"", ""},
+		{"limit=10&sort=version", "limit=10&sort=version"},
+		{"sig=abc", "sig=REDACTED"},
+		{"%73ig=abc&x=1", "%73ig=REDACTED&x=1"},
+		{"sig", "sig=REDACTED"},
+		{"signature=abc", "signature=abc"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+		if got := loggedQuery(r); got != tt.want {
+			t.Errorf("loggedQuery(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+// TypeInventory is the number of releases and their total size for one type.
+type TypeInventory struct {
+	Type  string
+	Count int
+	Bytes int64
+}
+
+var (
+	firmwareCountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "firmware_count"),
+		"Number of stored firmware versions, by firmware type.",
+		[]string{"type"}, nil,
+	)
+	storageBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "storage_bytes"),
+		"Total size of stored firmware binaries, by firmware type.",
+		[]string{"type"}, nil,
+	)
+)
+
+// inventoryCollector queries the database on every scrape, so the values
+// are always current without any bookkeeping on upload or delete.
+type inventoryCollector struct {
+	load func() ([]TypeInventory, error)
+}
+
+// RegisterInventory exposes per-type firmware count and storage size.
+func RegisterInventory(load func() ([]TypeInventory, error)) {
+	Registry.MustRegister(inventoryCollector{load: load})
+}
+
+func (c inventoryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- firmwareCountDesc
+	ch <- storageBytesDesc
+}
+
+func (c inventoryCollector) Collect(ch chan<- prometheus.Metric) {
+	inv, err := c.load()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to collect firmware inventory metrics")
+		return
+	}
+	for _, t := range inv {
+		ch <- prometheus.MustNewConstMetric(firmwareCountDesc, prometheus.GaugeValue, float64(t.Count), t.Type)
+		ch <- prometheus.MustNewConstMetric(storageBytesDesc, prometheus.GaugeValue, float64(t.Bytes), t.Type)
+	}
+}
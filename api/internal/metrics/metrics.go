@@ -0,0 +1,178 @@
+// Package metrics exposes registry metrics in Prometheus exposition format.
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "firmware_registry"
+
+// Registry holds every collector of this process. A private registry keeps
+// the output free of anything third-party packages register globally.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route template, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route template, method and status code.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"route", "method", "status"})
+
+	uploadBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upload_bytes_total",
+		Help:      "Bytes of firmware accepted by uploads, by firmware type.",
+	}, []string{"type"})
+
+	downloadBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "download_bytes_total",
+		Help:      "Bytes of firmware served by downloads, by firmware type.",
+	}, []string{"type"})
+
+	webhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Finished webhook deliveries by event and outcome (success, failure).",
+	}, []string{"event", "outcome"})
+
+	webhookRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_retries_total",
+		Help:      "Webhook delivery attempts after the first, by event.",
+	}, []string{"event"})
+
+	authFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_failures_total",
+		Help:      "Rejected requests by required role and presented credential type (api_key, jwt, none).",
+	}, []string{"role", "method"})
+
+	authDenials = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_denials_total",
+		Help:      "Authenticated requests refused for lack of permission, by required action.",
+	}, []string{"action"})
+
+	rateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "Requests refused with 429, by route class and limit (ip, identity or ban).",
+	}, []string{"class", "by"})
+
+	authBans = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_bans_total",
+		Help:      "Client IPs banned after repeated authentication failures.",
+	})
+
+	dbDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "SQLite query latency by repository operation.",
+		Buckets:   []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, 1},
+	}, []string{"query"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration,
+		uploadBytes, downloadBytes,
+		webhookDeliveries, webhookRetries,
+		authFailures,
+		authDenials,
+		rateLimited, authBans,
+		dbDuration,
+	)
+}
+
+// ObserveHTTP records one finished HTTP request.
+func ObserveHTTP(route, method string, status int, d time.Duration) {
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(route, method, code).Inc()
+	httpDuration.WithLabelValues(route, method, code).Observe(d.Seconds())
+}
+
+// AddUploadBytes counts the size of an accepted upload.
+func AddUploadBytes(typeName string, n int64) {
+	uploadBytes.WithLabelValues(typeName).Add(float64(n))
+}
+
+// AddDownloadBytes counts bytes written to a download response.
+func AddDownloadBytes(typeName string, n int64) {
+	downloadBytes.WithLabelValues(typeName).Add(float64(n))
+}
+
+// WebhookDelivered records the final outcome of a webhook delivery.
+func WebhookDelivered(event string, ok bool) {
+	outcome := "failure"
+	if ok {
+		outcome = "success"
+	}
+	webhookDeliveries.WithLabelValues(event, outcome).Inc()
+}
+
+// WebhookRetried records a delivery attempt after the first one.
+func WebhookRetried(event string) {
+	webhookRetries.WithLabelValues(event).Inc()
+}
+
+// AuthFailed records a rejected request.
+func AuthFailed(role, method string) {
+	authFailures.WithLabelValues(role, method).Inc()
+}
+
+// AuthDenied records an authenticated request without the permission it
+// needed.
+func AuthDenied(action string) {
+	authDenials.WithLabelValues(action).Inc()
+}
+
+// RateLimited records a request refused with 429.
+func RateLimited(class, by string) {
+	rateLimited.WithLabelValues(class, by).Inc()
+}
+
+// AuthBanned records a client IP banned for repeated authentication failures.
+func AuthBanned() {
+	authBans.Inc()
+}
+
+// ObserveQuery records the latency of a repository operation; use as
+// `defer metrics.ObserveQuery("firmware.get", time.Now())`.
+func ObserveQuery(query string, start time.Time) {
+	dbDuration.WithLabelValues(query).Observe(time.Since(start).Seconds())
+}
+
+// Handler serves the exposition format. With a non-empty token, scrapers
+// must send `Authorization: Bearer <token>`.
+func Handler(token string) http.Handler {
+	h := promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+	if token == "" {
+		return h
+	}
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
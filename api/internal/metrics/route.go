@@ -0,0 +1,89 @@
+package metrics
+
+import "strings"
+
+// Route maps a request path onto its route template so that metric labels
+// stay low-cardinality (no firmware types, versions or IDs).
+func Route(path string) string {
+	parts := strings.FieldsFunc(path, func(r rune) bool { return r == '/' })
+	if len(parts) == 0 {
+		return "other"
+	}
+
+	switch parts[0] {
+	case "metrics":
+		return "/metrics"
+	case "swagger":
+		return "/swagger/"
+	case "api":
+	default:
+		return "other"
+	}
+	if len(parts) == 1 {
+		return "other"
+	}
+
+	rest := parts[2:]
+	switch parts[1] {
+	case "health", "search", "whoami":
+		if len(rest) == 0 {
+			return "/api/" + parts[1]
+		}
+	case "firmware":
+		switch {
+		case len(rest) == 2 && rest[0] == "by-sha256":
+			return "/api/firmware/by-sha256/{hash}"
+		case len(rest) == 1:
+			return "/api/firmware/{type}"
+		case len(rest) == 2 && rest[1] == "latest":
+			return "/api/firmware/{type}/latest"
+		case len(rest) == 2:
+			return "/api/firmware/{type}/{version}"
+		case len(rest) == 3 && rest[2] == "presign":
+			return "/api/firmware/{type}/{version}/presign"
+		}
+	case "webhooks":
+		switch {
+		case len(rest) == 0:
+			return "/api/webhooks"
+		case len(rest) == 1:
+			return "/api/webhooks/{id}"
+		case len(rest) == 2 && rest[1] == "deliveries":
+			return "/api/webhooks/{id}/deliveries"
+		case len(rest) == 4 && rest[1] == "deliveries" && rest[3] == "redeliver":
+			return "/api/webhooks/{id}/deliveries/{deliveryId}/redeliver"
+		}
+	case "stats":
+		switch {
+		case len(rest) == 1:
+			return "/api/stats/{type}"
+		case len(rest) == 2 && rest[1] == "daily":
+			return "/api/stats/{type}/daily"
+		}
+	case "audit":
+		switch {
+		case len(rest) == 0:
+			return "/api/audit"
+		case len(rest) == 1 && rest[0] == "export":
+			return "/api/audit/export"
+		}
+	case "keys":
+		switch len(rest) {
+		case 0:
+			return "/api/keys"
+		case 1:
+			return "/api/keys/{id}"
+		}
+	case "admin":
+		if len(rest) == 1 {
+			switch rest[0] {
+			case "fsck", "export", "import", "config", "bans":
+				return "/api/admin/" + rest[0]
+			}
+		}
+		if len(rest) == 2 && rest[0] == "bans" {
+			return "/api/admin/bans/{ip}"
+		}
+	}
+	return "other"
+}
@@ -0,0 +1,50 @@
+// Package presign mints and verifies HMAC-signed, expiring download URLs
+// for bootloaders and flashing tools that cannot send credentials in
+// headers.
+package presign
+
+import (
+	"errors"
+	"time"
+)
+
+// Query parameters of a pre-signed URL.
+const (
+	ParamExpires   = "expires" // Unix time
+	ParamNonce     = "nonce"   // single-use links only
+	ParamIP        = "ip"      // IP-bound links only
+	ParamSignature = "sig"
+)
+
+var (
+	ErrInvalidRequest = errors.New("invalid pre-sign request")
+	ErrInvalid        = errors.New("invalid download link")
+	ErrExpired        = errors.New("download link expired")
+	ErrUsed           = errors.New("download link already used")
+	ErrWrongIP        = errors.New("download link is bound to another client IP")
+)
+
+// Link is the signed content of a pre-signed URL.
+type Link struct {
+	Type    string
+	Version string
+	Expires time.Time
+	Nonce   string // non-empty for single-use links
+	IP      string // non-empty for links bound to a client IP
+}
+
+// CreateRequest is the body of POST /api/firmware/{type}/{version}/presign.
+// Every field is optional.
+type CreateRequest struct {
+	TTLSec    int    `json:"ttlSec" example:"900" doc:"Lifetime in seconds; default FW_PRESIGN_DEFAULT_TTL_SEC, at most FW_PRESIGN_MAX_TTL_SEC"`
+	SingleUse bool   `json:"singleUse" example:"true" doc:"Accept the URL for one download only"`
+	IP        string `json:"ip,omitempty" example:"203.0.113.7" doc:"Only accept the URL from this client IP"`
+}
+
+// URL is a minted pre-signed download URL.
+type URL struct {
+	URL       string    `json:"url" example:"https://fw.example.com/api/firmware/esp32-main/1.2.3?expires=1767225600&sig=..." doc:"Absolute if FW_PUBLIC_BASE_URL is set, otherwise a path"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	SingleUse bool      `json:"singleUse"`
+	IP        string    `json:"ip,omitempty" example:"203.0.113.7"`
+}
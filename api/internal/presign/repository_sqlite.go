@@ -0,0 +1,52 @@
+package presign
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"firmware-registry-api/internal/db"
+)
+
+// Repository remembers the nonces of used single-use links.
+type Repository interface {
+	// UseNonce marks nonce as used until expires and reports whether it was
+	// unused. Nonces past their expiry are forgotten.
+	UseNonce(ctx context.Context, nonce string, expires time.Time) (bool, error)
+	// ReleaseNonce makes nonce unused again.
+	ReleaseNonce(ctx context.Context, nonce string) error
+}
+
+// SQLiteRepo implements Repository over SQLite.
+type SQLiteRepo struct {
+	DB *sql.DB
+}
+
+func (r *SQLiteRepo) UseNonce(ctx context.Context, nonce string, expires time.Time) (bool, error) {
+	ctx, done := db.Observe(ctx, "presign.use_nonce")
+	defer done()
+
+	// Expired links are rejected before their nonce is checked, so their
+	// nonces are no longer needed
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := r.DB.ExecContext(ctx, `DELETE FROM presign_nonces WHERE expires_at < ?`, now); err != nil {
+		return false, err
+	}
+	res, err := r.DB.ExecContext(ctx, `
+INSERT INTO presign_nonces(nonce, expires_at) VALUES(?,?)
+ON CONFLICT(nonce) DO NOTHING
+`, nonce, expires.UTC().Format(time.RFC3339))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (r *SQLiteRepo) ReleaseNonce(ctx context.Context, nonce string) error {
+	ctx, done := db.Observe(ctx, "presign.release_nonce")
+	defer done()
+
+	_, err := r.DB.ExecContext(ctx, `DELETE FROM presign_nonces WHERE nonce = ?`, nonce)
+	return err
+}
@@ -0,0 +1,143 @@
+package presign
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Service mints and verifies pre-signed download URLs.
+type Service struct {
+	Secret     []byte
+	Repo       Repository
+	DefaultTTL time.Duration
+	MaxTTL     time.Duration
+	// InListings makes firmware listings carry pre-signed download URLs
+	// valid for DefaultTTL.
+	InListings bool
+}
+
+// Create signs a link to typeName/version as requested.
+func (s *Service) Create(req CreateRequest, typeName, version string, now time.Time) (Link, error) {
+	ttl := s.DefaultTTL
+	if req.TTLSec != 0 {
+		ttl = time.Duration(req.TTLSec) * time.Second
+	}
+	if ttl <= 0 || ttl > s.MaxTTL {
+		return Link{}, fmt.Errorf("%w: ttlSec must be between 1 and %d", ErrInvalidRequest, int(s.MaxTTL.Seconds()))
+	}
+	l := Link{Type: typeName, Version: version, Expires: now.Add(ttl).Truncate(time.Second)}
+	if req.IP != "" {
+		ip := net.ParseIP(req.IP)
+		if ip == nil {
+			return Link{}, fmt.Errorf("%w: invalid ip %q", ErrInvalidRequest, req.IP)
+		}
+		l.IP = ip.String()
+	}
+	if req.SingleUse {
+		raw := make([]byte, 16)
+		if _, err := rand.Read(raw); err != nil {
+			return Link{}, err
+		}
+		l.Nonce = base64.RawURLEncoding.EncodeToString(raw)
+	}
+	return l, nil
+}
+
+// Listing returns the link used as downloadUrl in listings: valid for
+// DefaultTTL, reusable and not bound to an IP. Listings are plain reads, so
+// they never mint single-use nonces.
+func (s *Service) Listing(typeName, version string, now time.Time) Link {
+	return Link{Type: typeName, Version: version, Expires: now.Add(s.DefaultTTL).Truncate(time.Second)}
+}
+
+// Query returns the signed query parameters of l.
+func (s *Service) Query(l Link) url.Values {
+	q := url.Values{}
+	q.Set(ParamExpires, strconv.FormatInt(l.Expires.Unix(), 10))
+	if l.Nonce != "" {
+		q.Set(ParamNonce, l.Nonce)
+	}
+	if l.IP != "" {
+		q.Set(ParamIP, l.IP)
+	}
+	q.Set(ParamSignature, s.sign(l))
+	return q
+}
+
+// Verify checks a pre-signed request for typeName/version from clientIP.
+// It does not use up single-use links; call Use once the download can start.
+func (s *Service) Verify(typeName, version string, q url.Values, clientIP net.IP, now time.Time) (Link, error) {
+	exp, err := strconv.ParseInt(q.Get(ParamExpires), 10, 64)
+	if err != nil {
+		return Link{}, ErrInvalid
+	}
+	l := Link{
+		Type:    typeName,
+		Version: version,
+		Expires: time.Unix(exp, 0),
+		Nonce:   q.Get(ParamNonce),
+		IP:      q.Get(ParamIP),
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(q.Get(ParamSignature))
+	if err != nil {
+		return Link{}, ErrInvalid
+	}
+	want, _ := base64.RawURLEncoding.DecodeString(s.sign(l))
+	if !hmac.Equal(sig, want) {
+		return Link{}, ErrInvalid
+	}
+	if !now.Before(l.Expires) {
+		return Link{}, ErrExpired
+	}
+	if l.IP != "" && !net.ParseIP(l.IP).Equal(clientIP) {
+		return Link{}, ErrWrongIP
+	}
+	return l, nil
+}
+
+// Use marks a verified single-use link as used, or returns ErrUsed if it
+// already was. Links without a nonce can be used any number of times.
+func (s *Service) Use(ctx context.Context, l Link) error {
+	if l.Nonce == "" {
+		return nil
+	}
+	unused, err := s.Repo.UseNonce(ctx, l.Nonce, l.Expires)
+	if err != nil {
+		return err
+	}
+	if !unused {
+		return ErrUsed
+	}
+	return nil
+}
+
+// Release undoes Use for a download that could not be served.
+func (s *Service) Release(ctx context.Context, l Link) error {
+	if l.Nonce == "" {
+		return nil
+	}
+	return s.Repo.ReleaseNonce(ctx, l.Nonce)
+}
+
+// sign returns the HMAC-SHA256 of every field of l, base64url encoded. Each
+// field is prefixed with its length, so no two different links share the
+// same signed bytes.
+func (s *Service) sign(l Link) string {
+	mac := hmac.New(sha256.New, s.Secret)
+	for _, field := range []string{
+		"v2", l.Type, l.Version, strconv.FormatInt(l.Expires.Unix(), 10), l.Nonce, l.IP,
+	} {
+		mac.Write(binary.BigEndian.AppendUint32(nil, uint32(len(field))))
+		mac.Write([]byte(field))
+	}
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
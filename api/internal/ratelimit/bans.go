@@ -0,0 +1,139 @@
+package ratelimit
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Ban is a client IP refused because of repeated authentication failures.
+type Ban struct {
+	IP       string    `json:"ip" example:"203.0.113.7"`
+	Since    time.Time `json:"since"`
+	Until    time.Time `json:"until"`
+	Failures int       `json:"failures" example:"10" doc:"authentication failures that triggered the ban"`
+}
+
+// Bans counts authentication failures per client IP and bans an IP for
+// Duration once it fails Failures times within Window. Bans are kept in
+// memory only.
+type Bans struct {
+	Failures int
+	Window   time.Duration
+	Duration time.Duration
+
+	mu        sync.Mutex
+	sources   map[string]*source
+	lastSweep time.Time
+}
+
+type source struct {
+	failures    int
+	windowStart time.Time
+	ban         *Ban
+}
+
+// NewBans returns Bans with the given policy; failures <= 0 disables bans.
+func NewBans(failures int, window, duration time.Duration) *Bans {
+	return &Bans{Failures: failures, Window: window, Duration: duration, sources: make(map[string]*source)}
+}
+
+// Failure records an authentication failure from ip and returns the ban it
+// triggered, if any.
+func (b *Bans) Failure(ip string, now time.Time) (Ban, bool) {
+	if b == nil || b.Failures <= 0 || ip == "" {
+		return Ban{}, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sweep(now)
+
+	s, ok := b.sources[ip]
+	if !ok || now.Sub(s.windowStart) > b.Window {
+		if ok && s.ban != nil && now.Before(s.ban.Until) {
+			return Ban{}, false // already banned
+		}
+		s = &source{windowStart: now}
+		b.sources[ip] = s
+	}
+	s.failures++
+	if s.failures < b.Failures || (s.ban != nil && now.Before(s.ban.Until)) {
+		return Ban{}, false
+	}
+	s.ban = &Ban{IP: ip, Since: now, Until: now.Add(b.Duration), Failures: s.failures}
+	return *s.ban, true
+}
+
+// Banned returns the remaining ban time of ip, if it is banned.
+func (b *Bans) Banned(ip string, now time.Time) (time.Duration, bool) {
+	if b == nil {
+		return 0, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if s, ok := b.sources[ip]; ok && s.ban != nil && now.Before(s.ban.Until) {
+		return s.ban.Until.Sub(now), true
+	}
+	return 0, false
+}
+
+// List returns the active bans, oldest first.
+func (b *Bans) List(now time.Time) []Ban {
+	if b == nil {
+		return nil
+	}
+	out := []Ban{}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, s := range b.sources {
+		if s.ban != nil && now.Before(s.ban.Until) {
+			out = append(out, *s.ban)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Since.Before(out[j].Since) })
+	return out
+}
+
+// Clear lifts the ban on ip and forgets its failures. It reports whether ip
+// was banned.
+func (b *Bans) Clear(ip string, now time.Time) bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.sources[ip]
+	delete(b.sources, ip)
+	return ok && s.ban != nil && now.Before(s.ban.Until)
+}
+
+// ClearAll lifts every ban and forgets all failures. It returns the number
+// of bans lifted.
+func (b *Bans) ClearAll(now time.Time) int {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for _, s := range b.sources {
+		if s.ban != nil && now.Before(s.ban.Until) {
+			n++
+		}
+	}
+	b.sources = make(map[string]*source)
+	return n
+}
+
+// sweep drops sources whose window and ban are over. b.mu must be held.
+func (b *Bans) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < sweepInterval {
+		return
+	}
+	b.lastSweep = now
+	for ip, s := range b.sources {
+		if now.Sub(s.windowStart) > b.Window && (s.ban == nil || !now.Before(s.ban.Until)) {
+			delete(b.sources, ip)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBans(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	const ip = "203.0.113.7"
+
+	// Every test uses 3 failures within a minute for a 10 minute ban
+	type step struct {
+		at      time.Duration // since start
+		fail    bool          // record a failure, otherwise check Banned
+		banned  bool          // Failure started a ban, or Banned reports one
+		remains time.Duration // Banned only
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "threshold",
+			steps: []step{
+				{at: 0, fail: true},
+				{at: 10 * time.Second, fail: true},
+				{at: 10 * time.Second},
+				{at: 20 * time.Second, fail: true, banned: true},
+				{at: 20 * time.Second, banned: true, remains: 10 * time.Minute},
+			},
+		},
+		{
+			name: "expiry",
+			steps: []step{
+				{at: 0, fail: true},
+				{at: 0, fail: true},
+				{at: 0, fail: true, banned: true},
+				{at: 10*time.Minute - time.Second, banned: true, remains: time.Second},
+				{at: 10 * time.Minute},
+			},
+		},
+		{
+			name: "failures outside the window do not add up",
+			steps: []step{
+				{at: 0, fail: true},
+				{at: 30 * time.Second, fail: true},
+				{at: 61 * time.Second, fail: true},
+				{at: 62 * time.Second, fail: true},
+				{at: 62 * time.Second},
+				{at: 63 * time.Second, fail: true, banned: true},
+			},
+		},
+		{
+			name: "failures while banned do not extend the ban",
+			steps: []step{
+				{at: 0, fail: true},
+				{at: 0, fail: true},
+				{at: 0, fail: true, banned: true},
+				{at: 5 * time.Minute, fail: true},
+				{at: 5 * time.Minute, fail: true},
+				{at: 5 * time.Minute, fail: true},
+				{at: 5 * time.Minute, banned: true, remains: 5 * time.Minute},
+			},
+		},
+		{
+			name: "banned again after expiry",
+			steps: []step{
+				{at: 0, fail: true},
+				{at: 0, fail: true},
+				{at: 0, fail: true, banned: true},
+				{at: 11 * time.Minute, fail: true},
+				{at: 11 * time.Minute, fail: true},
+				{at: 11 * time.Minute, fail: true, banned: true},
+				{at: 12 * time.Minute, banned: true, remains: 9 * time.Minute},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBans(3, time.Minute, 10*time.Minute)
+			for i, s := range tt.steps {
+				now := start.Add(s.at)
+				if s.fail {
+					ban, banned := b.Failure(ip, now)
+					if banned != s.banned {
+						t.Fatalf("step %d at +%v: Failure banned = %v, want %v", i, s.at, banned, s.banned)
+					}
+					if banned && (ban.IP != ip || !ban.Since.Equal(now) || !ban.Until.Equal(now.Add(10*time.Minute))) {
+						t.Errorf("step %d: ban = %+v", i, ban)
+					}
+					continue
+				}
+				remains, banned := b.Banned(ip, now)
+				if banned != s.banned || remains != s.remains {
+					t.Fatalf("step %d at +%v: Banned = (%v, %v), want (%v, %v)", i, s.at, remains, banned, s.remains, s.banned)
+				}
+				if n := len(b.List(now)); (n == 1) != s.banned {
+					t.Errorf("step %d at +%v: List has %d bans", i, s.at, n)
+				}
+			}
+		})
+	}
+}
+
+func TestBansClear(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := NewBans(1, time.Minute, time.Hour)
+	b.Failure("a", now)
+	b.Failure("b", now.Add(time.Second))
+
+	if got := b.List(now.Add(time.Second)); len(got) != 2 || got[0].IP != "a" || got[1].IP != "b" {
+		t.Fatalf("List = %+v, want a then b", got)
+	}
+	if !b.Clear("a", now) || b.Clear("a", now) {
+		t.Error("Clear should lift the ban on a once")
+	}
+	if n := b.ClearAll(now); n != 1 {
+		t.Errorf("ClearAll lifted %d bans, want 1", n)
+	}
+	if _, banned := b.Banned("b", now); banned {
+		t.Error("b is still banned after ClearAll")
+	}
+}
+
+func TestBansDisabled(t *testing.T) {
+	now := time.Now()
+	for name, b := range map[string]*Bans{"nil": nil, "zero failures": NewBans(0, time.Minute, time.Hour)} {
+		for i := 0; i < 10; i++ {
+			if _, banned := b.Failure("a", now); banned {
+				t.Fatalf("%s: Failure banned", name)
+			}
+		}
+		if _, banned := b.Banned("a", now); banned {
+			t.Errorf("%s: Banned", name)
+		}
+		if got := b.List(now); len(got) != 0 {
+			t.Errorf("%s: List = %+v", name, got)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Package ratelimit implements token-bucket rate limits by route class and
+// temporary bans of clients that keep failing authentication.
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"firmware-registry-api/internal/metrics"
+)
+
+// Class groups routes that share a rate limit.
+type Class string
+
+const (
+	ClassRead     Class = "read"     // GET/HEAD API requests other than downloads, and pre-signing
+	ClassDownload Class = "download" // GET/HEAD /api/firmware/{type}/{version}
+	ClassWrite    Class = "write"    // every other method: uploads, deletes, admin
+	// ClassAudit limits audit log writes for refused requests; it is not
+	// a route class and Classify never returns it.
+	ClassAudit Class = "audit"
+)
+
+// Classify returns the class of r, or "" for requests that are not limited
+// (health checks, metrics, Swagger UI and CORS preflights).
+func Classify(r *http.Request) Class {
+	if !strings.HasPrefix(r.URL.Path, "/api/") {
+		return ""
+	}
+	route := metrics.Route(r.URL.Path)
+	switch {
+	case route == "/api/health", r.Method == http.MethodOptions:
+		return ""
+	case route == "/api/firmware/{type}/{version}/presign":
+		return ClassRead // minting a link is part of reading
+	case r.Method != http.MethodGet && r.Method != http.MethodHead:
+		return ClassWrite
+	case route == "/api/firmware/{type}/{version}":
+		return ClassDownload
+	default:
+		return ClassRead
+	}
+}
+
+// Limit is a token bucket: PerMin tokens are added per minute, up to Burst.
+// PerMin 0 means unlimited.
+type Limit struct {
+	PerMin int
+	Burst  int
+}
+
+// sweepInterval is how often idle buckets and expired bans are dropped.
+const sweepInterval = time.Minute
+
+// Limiter keeps one bucket per class and key (client IP or identity).
+type Limiter struct {
+	limits map[Class]Limit
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+}
+
+type bucketKey struct {
+	class Class
+	key   string
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a Limiter enforcing limits; classes without a limit
+// are not limited.
+func NewLimiter(limits map[Class]Limit) *Limiter {
+	return &Limiter{limits: limits, buckets: make(map[bucketKey]*bucket)}
+}
+
+// Allow takes a token from the bucket of key in class. If the bucket is
+// empty it returns false and the time until the next token.
+func (l *Limiter) Allow(class Class, key string, now time.Time) (bool, time.Duration) {
+	lim, ok := l.limits[class]
+	if !ok || lim.PerMin <= 0 {
+		return true, 0
+	}
+	burst := float64(max(lim.Burst, 1))
+	perSec := float64(lim.PerMin) / 60
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	k := bucketKey{class, key}
+	b, ok := l.buckets[k]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[k] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*perSec)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / perSec * float64(time.Second))
+}
+
+// sweep drops buckets that have refilled completely; a new bucket starts
+// full, so nothing is lost. l.mu must be held.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for k, b := range l.buckets {
+		lim := l.limits[k.class]
+		refill := time.Duration(float64(max(lim.Burst, 1)) / float64(lim.PerMin) * float64(time.Minute))
+		if now.Sub(b.last) >= refill {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// RetryAfter formats d as a Retry-After value: whole seconds, at least 1.
+func RetryAfter(d time.Duration) string {
+	return strconv.FormatInt(max(int64(math.Ceil(d.Seconds())), 1), 10)
+}
@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	type step struct {
+		at   time.Duration // since start
+		key  string
+		want bool
+		wait time.Duration
+	}
+	tests := []struct {
+		name  string
+		limit Limit
+		steps []step
+	}{
+		{
+			name:  "burst then refill",
+			limit: Limit{PerMin: 60, Burst: 3},
+			steps: []step{
+				{at: 0, want: true},
+				{at: 0, want: true},
+				{at: 0, want: true},
+				{at: 0, want: false, wait: time.Second},
+				{at: 500 * time.Millisecond, want: false, wait: 500 * time.Millisecond},
+				{at: time.Second, want: true},
+				{at: time.Second, want: false, wait: time.Second},
+			},
+		},
+		{
+			name:  "refill is capped at burst",
+			limit: Limit{PerMin: 60, Burst: 2},
+			steps: []step{
+				{at: 0, want: true},
+				{at: 0, want: true},
+				{at: time.Hour, want: true},
+				{at: time.Hour, want: true},
+				{at: time.Hour, want: false, wait: time.Second},
+			},
+		},
+		{
+			name:  "burst below one allows one",
+			limit: Limit{PerMin: 30},
+			steps: []step{
+				{at: 0, want: true},
+				{at: 0, want: false, wait: 2 * time.Second},
+				{at: 2 * time.Second, want: true},
+			},
+		},
+		{
+			name:  "keys have separate buckets",
+			limit: Limit{PerMin: 60, Burst: 1},
+			steps: []step{
+				{at: 0, key: "a", want: true},
+				{at: 0, key: "a", want: false, wait: time.Second},
+				{at: 0, key: "b", want: true},
+			},
+		},
+		{
+			name:  "zero rate is unlimited",
+			limit: Limit{PerMin: 0, Burst: 1},
+			steps: []step{
+				{at: 0, want: true},
+				{at: 0, want: true},
+				{at: 0, want: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLimiter(map[Class]Limit{ClassRead: tt.limit})
+			for i, s := range tt.steps {
+				ok, wait := l.Allow(ClassRead, s.key, start.Add(s.at))
+				if ok != s.want || wait != s.wait {
+					t.Errorf("step %d at +%v: got (%v, %v), want (%v, %v)", i, s.at, ok, wait, s.want, s.wait)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterUnlimitedClass(t *testing.T) {
+	l := NewLimiter(map[Class]Limit{ClassWrite: {PerMin: 1, Burst: 1}})
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		if ok, _ := l.Allow(ClassDownload, "a", now); !ok {
+			t.Fatalf("request %d to a class without a limit was refused", i)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "1"},
+		{300 * time.Millisecond, "1"},
+		{time.Second, "1"},
+		{1500 * time.Millisecond, "2"},
+		{time.Minute, "60"},
+	}
+	for _, tt := range tests {
+		if got := RetryAfter(tt.d); got != tt.want {
+			t.Errorf("RetryAfter(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
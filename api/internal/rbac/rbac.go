@@ -0,0 +1,169 @@
+// Package rbac evaluates permission grants keyed on firmware type patterns,
+// e.g. "esp32-*:upload".
+package rbac
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Action is an operation that can be granted.
+type Action string
+
+const (
+	Upload         Action = "upload"
+	Delete         Action = "delete"
+	Read           Action = "read"            // list, search and download
+	WebhooksManage Action = "webhooks:manage" // not tied to a firmware type
+)
+
+// typed reports whether grants for a are scoped to firmware types.
+func (a Action) typed() bool {
+	return a == Upload || a == Delete || a == Read
+}
+
+// Grant allows Action on every firmware type matching Pattern (path.Match
+// syntax; "*" matches all types).
+type Grant struct {
+	Pattern string
+	Action  Action
+}
+
+// String formats g as ParseGrant accepts it; grants on all types are bare.
+func (g Grant) String() string {
+	if !g.Action.typed() || g.Pattern == "*" {
+		return string(g.Action)
+	}
+	return g.Pattern + ":" + string(g.Action)
+}
+
+// ParseGrant parses "pattern:action". A bare action applies to all types;
+// webhooks:manage is always bare.
+func ParseGrant(s string) (Grant, error) {
+	s = strings.TrimSpace(s)
+	switch a := Action(s); a {
+	case Upload, Delete, Read, WebhooksManage:
+		return Grant{Pattern: "*", Action: a}, nil
+	}
+
+	i := strings.LastIndex(s, ":")
+	if i <= 0 {
+		return Grant{}, fmt.Errorf("invalid grant %q: want <type-pattern>:<action> or a bare action", s)
+	}
+	g := Grant{Pattern: s[:i], Action: Action(s[i+1:])}
+	if !g.Action.typed() {
+		return Grant{}, fmt.Errorf("invalid grant %q: action must be upload, delete or read", s)
+	}
+	if _, err := path.Match(g.Pattern, ""); err != nil {
+		return Grant{}, fmt.Errorf("invalid grant %q: bad pattern: %w", s, err)
+	}
+	return g, nil
+}
+
+// ParseGrants parses every entry of list.
+func ParseGrants(list []string) ([]Grant, error) {
+	out := make([]Grant, 0, len(list))
+	for _, s := range list {
+		g, err := ParseGrant(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, g)
+	}
+	return out, nil
+}
+
+// Roles maps role names to their grants.
+type Roles map[string][]Grant
+
+// ParseRoles parses the role definitions from the config.
+func ParseRoles(defs map[string][]string) (Roles, error) {
+	roles := make(Roles, len(defs))
+	for name, list := range defs {
+		grants, err := ParseGrants(list)
+		if err != nil {
+			return nil, fmt.Errorf("role %q: %w", name, err)
+		}
+		roles[name] = grants
+	}
+	return roles, nil
+}
+
+// Permissions are the effective rights of a caller.
+type Permissions struct {
+	Admin  bool // everything, including endpoints no grant covers
+	Grants []Grant
+	Roles  []string // RBAC roles the grants came from, for display
+}
+
+// All is what admins get.
+var All = Permissions{Admin: true}
+
+// Add appends grants (from a key or token) and the grants of every known
+// role in names. Unknown role names are ignored.
+func (p *Permissions) Add(roles Roles, names []string, grants ...Grant) {
+	p.Grants = append(p.Grants, grants...)
+	for _, name := range names {
+		if g, ok := roles[name]; ok {
+			p.Grants = append(p.Grants, g...)
+			p.Roles = append(p.Roles, name)
+		}
+	}
+}
+
+// Empty reports whether p allows nothing.
+func (p Permissions) Empty() bool {
+	return !p.Admin && len(p.Grants) == 0
+}
+
+// Allows reports whether a is permitted on firmware type typ.
+func (p Permissions) Allows(a Action, typ string) bool {
+	if p.Admin {
+		return true
+	}
+	for _, g := range p.Grants {
+		if g.Action != a {
+			continue
+		}
+		if !a.typed() {
+			return true
+		}
+		if ok, _ := path.Match(g.Pattern, typ); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsAny reports whether a is permitted on at least one type. Endpoints
+// spanning types use it and then filter with Allows.
+func (p Permissions) AllowsAny(a Action) bool {
+	if p.Admin {
+		return true
+	}
+	for _, g := range p.Grants {
+		if g.Action == a {
+			return true
+		}
+	}
+	return false
+}
+
+// Strings lists the grants, sorted and without duplicates; admins get "*".
+func (p Permissions) Strings() []string {
+	if p.Admin {
+		return []string{"*"}
+	}
+	seen := make(map[string]bool)
+	out := []string{}
+	for _, g := range p.Grants {
+		if s := g.String(); !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,53 @@
+package stats
+
+import "time"
+
+// DownloadEvent is one firmware download, recorded when the response ends.
+type DownloadEvent struct {
+	Type      string
+	Version   string
+	DeviceID  string // from X-Device-ID or ?device_id=, empty if unknown
+	ClientIP  string
+	Bytes     int64
+	Completed bool // false if the client went away before the whole binary was sent
+	Time      time.Time
+}
+
+// Counts is the aggregate shared by all statistics endpoints.
+type Counts struct {
+	Downloads     int     `json:"downloads" example:"1200" doc:"Download attempts"`
+	Completed     int     `json:"completed" example:"1150" doc:"Downloads that transferred the whole binary"`
+	Aborted       int     `json:"aborted" example:"50" doc:"Downloads the client abandoned"`
+	AbortRate     float64 `json:"abortRate" example:"0.0417" doc:"Aborted / downloads"`
+	UniqueDevices int     `json:"uniqueDevices" example:"800" doc:"Distinct device IDs (downloads without an ID are not counted)"`
+	BytesServed   int64   `json:"bytesServed" example:"629145600"`
+}
+
+// VersionStats aggregates one version over the requested period.
+type VersionStats struct {
+	Version string `json:"version" example:"1.2.3"`
+	Counts
+}
+
+// Summary aggregates one firmware type over the requested period.
+type Summary struct {
+	Type     string         `json:"type" example:"esp32-main"`
+	Since    time.Time      `json:"since"`
+	Until    time.Time      `json:"until"`
+	Total    Counts         `json:"total"`
+	Versions []VersionStats `json:"versions"`
+}
+
+// DailyStats aggregates one version on one UTC day.
+type DailyStats struct {
+	Day     string `json:"day" example:"2024-01-15"`
+	Version string `json:"version" example:"1.2.3"`
+	Counts
+}
+
+func (c *Counts) finish() {
+	c.Aborted = c.Downloads - c.Completed
+	if c.Downloads > 0 {
+		c.AbortRate = float64(c.Aborted) / float64(c.Downloads)
+	}
+}
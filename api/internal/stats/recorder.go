@@ -0,0 +1,120 @@
+package stats
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Recorder buffers download events and writes them in batches so the
+// download path never waits on the database.
+type Recorder struct {
+	repo       Repository
+	events     chan DownloadEvent
+	batchSize  int
+	flushEvery time.Duration
+
+	// mu guards closed; Record holds it for reading while it sends, so
+	// Close cannot close events under it.
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+}
+
+// NewRecorder starts the background writer. Events are flushed when
+// batchSize are pending or every flushEvery, whichever comes first.
+func NewRecorder(repo Repository, batchSize int, flushEvery time.Duration) *Recorder {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	if flushEvery <= 0 {
+		flushEvery = 5 * time.Second
+	}
+	r := &Recorder{
+		repo:       repo,
+		events:     make(chan DownloadEvent, batchSize*10),
+		batchSize:  batchSize,
+		flushEvery: flushEvery,
+		done:       make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Record queues an event. If the buffer is full the event is dropped
+// rather than slowing down the download that produced it. After Close,
+// events are dropped: downloads cut off at the shutdown deadline may still
+// finish.
+func (r *Recorder) Record(e DownloadEvent) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		log.Warn().
+			Str("type", e.Type).
+			Str("version", e.Version).
+			Msg("Download statistics recorder closed, dropping event")
+		return
+	}
+	select {
+	case r.events <- e:
+	default:
+		log.Warn().
+			Str("type", e.Type).
+			Str("version", e.Version).
+			Msg("Download statistics buffer full, dropping event")
+	}
+}
+
+// Close flushes pending events and stops the writer. It is safe to call
+// more than once.
+func (r *Recorder) Close() {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.events)
+	}
+	r.mu.Unlock()
+	<-r.done
+}
+
+func (r *Recorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.flushEvery)
+	defer ticker.Stop()
+
+	batch := make([]DownloadEvent, 0, r.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := r.repo.InsertBatch(context.Background(), batch); err != nil {
+			log.Error().
+				Err(err).
+				Int("events", len(batch)).
+				Msg("Failed to write download statistics")
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case e, ok := <-r.events:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, e)
+			if len(batch) >= r.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
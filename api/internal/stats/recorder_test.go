@@ -0,0 +1,77 @@
+package stats
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memRepo keeps inserted events in memory.
+type memRepo struct {
+	mu     sync.Mutex
+	events []DownloadEvent
+}
+
+func (m *memRepo) InsertBatch(_ context.Context, events []DownloadEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, events...)
+	return nil
+}
+
+func (m *memRepo) Summary(context.Context, string, time.Time, time.Time) (Summary, error) {
+	return Summary{}, nil
+}
+
+func (m *memRepo) Daily(context.Context, string, string, time.Time, time.Time) ([]DailyStats, error) {
+	return nil, nil
+}
+
+func (m *memRepo) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.events)
+}
+
+func TestRecorderCloseFlushes(t *testing.T) {
+	repo := &memRepo{}
+	r := NewRecorder(repo, 100, time.Hour)
+	for i := 0; i < 3; i++ {
+		r.Record(DownloadEvent{Type: "esp32-main", Version: "1.0.0"})
+	}
+	r.Close()
+	if n := repo.count(); n != 3 {
+		t.Errorf("%d events written at Close, want 3", n)
+	}
+}
+
+func TestRecorderRecordAfterClose(t *testing.T) {
+	repo := &memRepo{}
+	r := NewRecorder(repo, 100, time.Hour)
+	r.Close()
+	r.Close()
+
+	// A download handler finishing after the shutdown deadline
+	r.Record(DownloadEvent{Type: "esp32-main", Version: "1.0.0"})
+	if n := repo.count(); n != 0 {
+		t.Errorf("%d events written after Close, want 0", n)
+	}
+}
+
+func TestRecorderRecordDuringClose(t *testing.T) {
+	r := NewRecorder(&memRepo{}, 10, time.Hour)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				r.Record(DownloadEvent{Type: "esp32-main", Version: "1.0.0"})
+			}
+		}()
+	}
+	r.Close()
+	wg.Wait()
+}
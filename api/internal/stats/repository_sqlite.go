@@ -0,0 +1,135 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"firmware-registry-api/internal/db"
+)
+
+// Repository persists download events and answers aggregate queries.
+type Repository interface {
+	InsertBatch(ctx context.Context, events []DownloadEvent) error
+	Summary(ctx context.Context, typeName string, since, until time.Time) (Summary, error)
+	Daily(ctx context.Context, typeName, version string, since, until time.Time) ([]DailyStats, error)
+}
+
+// SQLiteRepo implements Repository over SQLite.
+type SQLiteRepo struct {
+	DB *sql.DB
+}
+
+// aggregateColumns computes Counts (minus the derived fields) for a GROUP BY.
+const aggregateColumns = `
+COUNT(*),
+COALESCE(SUM(completed), 0),
+COUNT(DISTINCT NULLIF(device_id, '')),
+COALESCE(SUM(bytes), 0)`
+
+// InsertBatch writes all events in one transaction with a prepared statement.
+func (r *SQLiteRepo) InsertBatch(ctx context.Context, events []DownloadEvent) error {
+	ctx, done := db.Observe(ctx, "stats.insert_batch")
+	defer done()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, `
+INSERT INTO download_events(type, version, device_id, client_ip, bytes, completed, created_at)
+VALUES(?,?,?,?,?,?,?)
+`)
+	if err != nil {
+		return err
+	}
+	defer func(stmt *sql.Stmt) {
+		_ = stmt.Close()
+	}(stmt)
+
+	for _, e := range events {
+		if _, err := stmt.ExecContext(ctx, e.Type, e.Version, e.DeviceID, e.ClientIP, e.Bytes, e.Completed,
+			e.Time.UTC().Format(time.RFC3339)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *SQLiteRepo) Summary(ctx context.Context, typeName string, since, until time.Time) (Summary, error) {
+	ctx, done := db.Observe(ctx, "stats.summary")
+	defer done()
+
+	s := Summary{Type: typeName, Since: since, Until: until, Versions: []VersionStats{}}
+	from, to := since.UTC().Format(time.RFC3339), until.UTC().Format(time.RFC3339)
+
+	var total Counts
+	if err := r.DB.QueryRowContext(ctx, `
+SELECT `+aggregateColumns+`
+FROM download_events WHERE type=? AND created_at >= ? AND created_at <= ?
+`, typeName, from, to).Scan(&total.Downloads, &total.Completed, &total.UniqueDevices, &total.BytesServed); err != nil {
+		return s, err
+	}
+	total.finish()
+	s.Total = total
+
+	rows, err := r.DB.QueryContext(ctx, `
+SELECT version, `+aggregateColumns+`
+FROM download_events WHERE type=? AND created_at >= ? AND created_at <= ?
+GROUP BY version ORDER BY COUNT(*) DESC
+`, typeName, from, to)
+	if err != nil {
+		return s, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	for rows.Next() {
+		var v VersionStats
+		if err := rows.Scan(&v.Version, &v.Downloads, &v.Completed, &v.UniqueDevices, &v.BytesServed); err != nil {
+			return s, err
+		}
+		v.finish()
+		s.Versions = append(s.Versions, v)
+	}
+	return s, rows.Err()
+}
+
+func (r *SQLiteRepo) Daily(ctx context.Context, typeName, version string, since, until time.Time) ([]DailyStats, error) {
+	ctx, done := db.Observe(ctx, "stats.daily")
+	defer done()
+
+	query := `
+SELECT substr(created_at, 1, 10) AS day, version, ` + aggregateColumns + `
+FROM download_events WHERE type=? AND created_at >= ? AND created_at <= ?`
+	args := []any{typeName, since.UTC().Format(time.RFC3339), until.UTC().Format(time.RFC3339)}
+	if version != "" {
+		query += ` AND version=?`
+		args = append(args, version)
+	}
+	query += ` GROUP BY day, version ORDER BY day, version`
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	out := []DailyStats{}
+	for rows.Next() {
+		var d DailyStats
+		if err := rows.Scan(&d.Day, &d.Version, &d.Downloads, &d.Completed, &d.UniqueDevices, &d.BytesServed); err != nil {
+			return nil, err
+		}
+		d.finish()
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,28 @@
+package tlsconfig
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// RedirectHandler answers every plain HTTP request with a permanent redirect
+// to the same host and path on the HTTPS listener at httpsAddr.
+func RedirectHandler(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := strings.Trim(r.Host, "[]")
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		// 308 keeps the method and body, so a misdirected upload is not
+		// silently turned into a GET.
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,103 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Reloader serves the certificate in CertFile/KeyFile and picks up a new
+// pair when either file changes, so short-lived certificates can be rotated
+// without a restart. A pair that fails to load is logged and the previous
+// certificate stays in use.
+type Reloader struct {
+	CertFile string
+	KeyFile  string
+	Interval time.Duration // how often the files are checked
+
+	cert  atomic.Pointer[tls.Certificate]
+	stamp string
+}
+
+// NewReloader loads the initial certificate; a broken pair at startup is an
+// error rather than a warning.
+func NewReloader(certFile, keyFile string, interval time.Duration) (*Reloader, error) {
+	r := &Reloader{CertFile: certFile, KeyFile: keyFile, Interval: interval}
+	r.stamp = r.fileStamp()
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// Run polls the files until ctx is cancelled.
+func (r *Reloader) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stamp := r.fileStamp()
+			if stamp == r.stamp {
+				continue
+			}
+			// Remember the stamp even on failure so a half-written pair is
+			// retried only once the files change again.
+			r.stamp = stamp
+			if err := r.load(); err != nil {
+				zerolog.Ctx(ctx).Error().
+					Err(err).
+					Str("cert_file", r.CertFile).
+					Msg("TLS certificate reload failed, keeping previous certificate")
+			}
+		}
+	}
+}
+
+func (r *Reloader) load() error {
+	cert, err := tls.LoadX509KeyPair(r.CertFile, r.KeyFile)
+	if err != nil {
+		return err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return err
+	}
+	cert.Leaf = leaf
+	r.cert.Store(&cert)
+
+	log.Info().
+		Str("cert_file", r.CertFile).
+		Str("subject", leaf.Subject.String()).
+		Time("not_after", leaf.NotAfter).
+		Msg("TLS certificate loaded")
+	return nil
+}
+
+// fileStamp changes whenever either file is replaced or rewritten.
+func (r *Reloader) fileStamp() string {
+	var stamp string
+	for _, name := range []string{r.CertFile, r.KeyFile} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			stamp += "missing;"
+			continue
+		}
+		stamp += fi.ModTime().String() + "/" + strconv.FormatInt(fi.Size(), 10) + ";"
+	}
+	return stamp
+}
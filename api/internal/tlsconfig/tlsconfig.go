@@ -0,0 +1,70 @@
+// Package tlsconfig builds the server TLS configuration and keeps the
+// certificate current when the files on disk are replaced.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	"firmware-registry-api/internal/config"
+)
+
+var versions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseVersion maps "1.0" .. "1.3" to the crypto/tls constant.
+func ParseVersion(s string) (uint16, error) {
+	v, ok := versions[strings.TrimPrefix(strings.TrimSpace(s), "TLS")]
+	if !ok {
+		return 0, fmt.Errorf("unsupported TLS version %q (use 1.0, 1.1, 1.2 or 1.3)", s)
+	}
+	return v, nil
+}
+
+// ParseCipherSuites resolves a comma-separated list of IANA suite names
+// (e.g. TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256). Only suites Go considers
+// secure are accepted. An empty list means the Go defaults.
+func ParseCipherSuites(s string) ([]uint16, error) {
+	known := make(map[string]uint16)
+	for _, c := range tls.CipherSuites() {
+		known[c.Name] = c.ID
+	}
+
+	var ids []uint16
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Server returns the listener configuration for cfg.TLS, serving
+// certificates from r. Cipher suites only apply to TLS 1.2 and below;
+// TLS 1.3 suites are not configurable.
+func Server(cfg config.Config, r *Reloader) (*tls.Config, error) {
+	minVersion, err := ParseVersion(cfg.TLS.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	suites, err := ParseCipherSuites(cfg.TLS.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   suites,
+		GetCertificate: r.GetCertificate,
+	}, nil
+}
@@ -0,0 +1,123 @@
+// Package tracing sets up OpenTelemetry trace export and provides the
+// spans shared by the HTTP, service, database and webhook layers.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"firmware-registry-api/internal/config"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "firmware-registry-api"
+
+// Setup installs the W3C trace context propagator and, when tracing is
+// enabled, a tracer provider exporting over OTLP/HTTP. The returned function
+// flushes pending spans and must be called before exit. With tracing
+// disabled every span is a no-op.
+func Setup(cfg config.Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if !cfg.Tracing.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	// Accept the collector base URL as well as the full traces URL.
+	endpoint := strings.TrimRight(cfg.Tracing.Endpoint, "/")
+	if !strings.HasSuffix(endpoint, "/v1/traces") {
+		endpoint += "/v1/traces"
+	}
+	exporter, err := otlptracehttp.New(context.Background(),
+		otlptracehttp.WithEndpointURL(endpoint),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating OTLP exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", cfg.Tracing.ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("building trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.Tracing.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	log.Info().
+		Str("endpoint", endpoint).
+		Str("service_name", cfg.Tracing.ServiceName).
+		Float64("sample_ratio", cfg.Tracing.SampleRatio).
+		Msg("OpenTelemetry tracing enabled")
+
+	return provider.Shutdown, nil
+}
+
+// Start starts a span as a child of the span in ctx, if any.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartQuery starts a client span for one repository operation.
+func StartQuery(ctx context.Context, query string) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, query,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system.name", "sqlite"),
+			attribute.String("db.operation.name", query),
+		),
+	)
+}
+
+// StartClient starts a client span for an outgoing HTTP request and writes
+// its traceparent into the request headers so the receiver can continue
+// the trace.
+func StartClient(ctx context.Context, name string, header map[string][]string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+	return ctx, span
+}
+
+// Traceparent returns the W3C traceparent of the span in ctx, or "" if
+// there is none, for work that continues the trace later.
+func Traceparent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// Resume returns ctx with the remote span described by traceparent as its
+// parent; an empty or malformed traceparent leaves ctx unchanged.
+func Resume(ctx context.Context, traceparent string) context.Context {
+	if traceparent == "" {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier{"traceparent": traceparent})
+}
+
+// Fail marks span as failed with err.
+func Fail(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,135 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"firmware-registry-api/internal/config"
+	"firmware-registry-api/internal/db"
+	"firmware-registry-api/internal/firmware"
+	"firmware-registry-api/internal/tracing"
+	"firmware-registry-api/internal/webhook"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// TestUploadTrace follows one upload from the HTTP middleware through
+// SaveFirmware and its queries to the webhook delivery it triggers, using
+// an in-memory span recorder instead of a collector.
+func TestUploadTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	// Installs the W3C propagator; export itself stays off
+	if _, err := tracing.Setup(config.Config{}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "registry.db")
+	if err := db.Migrate(dbPath, "../../migrations"); err != nil {
+		t.Fatal(err)
+	}
+	database := db.OpenSQLite(dbPath)
+	t.Cleanup(func() { _ = database.Close() })
+
+	traceparents := make(chan string, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case traceparents <- r.Header.Get("traceparent"):
+		default:
+		}
+	}))
+	t.Cleanup(receiver.Close)
+
+	whRepo := &webhook.SQLiteRepo{DB: database}
+	if _, err := whRepo.Create(context.Background(), webhook.Webhook{
+		URL: receiver.URL, Events: []string{"firmware.uploaded"}, Enabled: true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	whSvc := &webhook.Service{Repo: whRepo, Settings: webhook.Settings{TimeoutSec: 5}, Workers: 1}
+	whSvc.Start()
+	t.Cleanup(func() { _ = whSvc.Close(context.Background()) })
+
+	fwSvc := &firmware.Service{
+		Repo:    &firmware.SQLiteRepo{DB: database},
+		Storage: firmware.Storage{BaseDir: filepath.Join(dir, "fw")},
+	}
+	handler := tracing.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fw, err := fwSvc.SaveFirmware(r.Context(), "esp32-main", "1.0.0", "app.bin",
+			firmware.ReleaseInfo{}, r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		whSvc.Dispatch(r.Context(), "firmware.uploaded", fw)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/firmware/esp32-main/1.0.0",
+		strings.NewReader("firmware image")))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("upload: %d %s", rec.Code, rec.Body)
+	}
+
+	var traceparent string
+	select {
+	case traceparent = <-traceparents:
+	case <-time.After(10 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+	if err := whSvc.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		if _, ok := spans[s.Name()]; !ok {
+			spans[s.Name()] = s
+		}
+	}
+	server := spans["POST /api/firmware/{type}/{version}"]
+	if server == nil {
+		t.Fatalf("no server span; got %v", names(recorder.Ended()))
+	}
+	traceID := server.SpanContext().TraceID()
+	for _, name := range []string{"firmware.save", "firmware.upsert", "webhook.list", "webhook.enqueue", "webhook.deliver"} {
+		s := spans[name]
+		if s == nil {
+			t.Errorf("no %s span; got %v", name, names(recorder.Ended()))
+			continue
+		}
+		if s.SpanContext().TraceID() != traceID {
+			t.Errorf("%s is in trace %s, want %s", name, s.SpanContext().TraceID(), traceID)
+		}
+	}
+
+	// traceparent: version-traceid-spanid-flags, naming the delivery span
+	deliver := spans["webhook.deliver"]
+	if deliver == nil {
+		return
+	}
+	want := "00-" + traceID.String() + "-" + deliver.SpanContext().SpanID().String() + "-01"
+	if traceparent != want {
+		t.Errorf("webhook traceparent = %q, want %q", traceparent, want)
+	}
+}
+
+func names(spans []sdktrace.ReadOnlySpan) []string {
+	out := make([]string, len(spans))
+	for i, s := range spans {
+		out[i] = s.Name()
+	}
+	return out
+}
@@ -5,29 +5,28 @@ import (
     "strings"
 )
 
-// CompareSemver compares a and b as major.minor.patch.
-// Returns 1 if a>b, -1 if a<b, 0 if equal.
-func CompareSemver(a, b string) int {
-    parse := func(v string) ([]int, bool) {
-        parts := strings.Split(v, ".")
-        if len(parts) < 1 || len(parts) > 3 {
-            return nil, false
-        }
-        out := make([]int, 3)
-        for i := 0; i < 3; i++ {
-            if i < len(parts) {
-                n, err := strconv.Atoi(parts[i])
-                if err != nil {
-                    return nil, false
-                }
-                out[i] = n
-            }
+// ParseSemver parses major[.minor[.patch]] into its numeric parts.
+// Missing parts are zero; ok is false if v is not numeric semver.
+func ParseSemver(v string) (parts [3]int, ok bool) {
+    fields := strings.Split(v, ".")
+    if len(fields) < 1 || len(fields) > 3 {
+        return parts, false
+    }
+    for i, f := range fields {
+        n, err := strconv.Atoi(f)
+        if err != nil || n < 0 {
+            return parts, false
         }
-        return out, true
+        parts[i] = n
     }
+    return parts, true
+}
 
-    pa, oka := parse(a)
-    pb, okb := parse(b)
+// CompareSemver compares a and b as major.minor.patch.
+// Returns 1 if a>b, -1 if a<b, 0 if equal.
+func CompareSemver(a, b string) int {
+    pa, oka := ParseSemver(a)
+    pb, okb := ParseSemver(b)
     if !oka || !okb {
         if a == b {
             return 0
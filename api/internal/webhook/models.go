@@ -1,5 +1,16 @@
 package webhook
 
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+	ErrDisabled = errors.New("webhook is disabled")
+)
+
 // Webhook is stored in DB.
 type Webhook struct {
 	ID      int64
@@ -16,8 +27,128 @@ type WebhookDTO struct {
 	Enabled bool     `json:"enabled" example:"true" doc:"Whether webhook is active"`
 }
 
+// WebhookDetailDTO is a webhook together with its delivery health.
+type WebhookDetailDTO struct {
+	WebhookDTO
+	Health Health `json:"health"`
+}
+
 type EventPayload struct {
 	Event string `json:"event" example:"firmware.uploaded" doc:"Event type"`
 	Data  any    `json:"data" doc:"Event-specific payload data"`
 	Time  string `json:"time" example:"2024-01-15T10:30:00Z" doc:"Event timestamp in RFC3339 format"`
 }
+
+// Delivery states.
+const (
+	StatusPending    = "pending"     // waiting for its next attempt
+	StatusInProgress = "in_progress" // claimed by a worker
+	StatusDelivered  = "delivered"
+	StatusFailed     = "failed" // retries exhausted
+)
+
+// Delivery is one event queued for one webhook. Its retry state is stored
+// with it, so pending deliveries survive a restart.
+type Delivery struct {
+	ID           int64
+	WebhookID    int64
+	URL          string // the webhook's current URL; set by ClaimDue
+	Event        string
+	Payload      []byte
+	Traceparent  string // trace of the request that raised the event
+	Status       string
+	Attempts     int
+	NextAttempt  time.Time
+	LastError    string
+	RedeliveryOf int64 // the delivery this one resends, if any
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	History      []Attempt // filled in by Deliveries
+}
+
+// Attempt is the record of one HTTP request made for a delivery.
+type Attempt struct {
+	ID             int64             `json:"id" example:"17"`
+	DeliveryID     int64             `json:"-"`
+	WebhookID      int64             `json:"-"`
+	Attempt        int               `json:"attempt" example:"1"`
+	URL            string            `json:"url" example:"https://example.com/webhook"`
+	RequestHeaders map[string]string `json:"requestHeaders"`
+	ResponseStatus int               `json:"responseStatus,omitempty" example:"200" doc:"Omitted if no response was received"`
+	ResponseBody   string            `json:"responseBody,omitempty" doc:"First 4 KiB of the response body"`
+	DurationMs     float64           `json:"durationMs" example:"84.5"`
+	Error          string            `json:"error,omitempty" example:"non-2xx status: 503 Service Unavailable" doc:"Empty if the receiver answered 2xx"`
+	Time           time.Time         `json:"time"`
+}
+
+// DeliveryDTO is a delivery with its attempts, as listed over the API.
+type DeliveryDTO struct {
+	ID            int64           `json:"id" example:"42"`
+	WebhookID     int64           `json:"webhookId" example:"1"`
+	Event         string          `json:"event" example:"firmware.uploaded"`
+	Status        string          `json:"status" example:"delivered" doc:"pending, in_progress, delivered or failed"`
+	NextAttemptAt *time.Time      `json:"nextAttemptAt,omitempty" doc:"Pending deliveries only"`
+	RedeliveryOf  int64           `json:"redeliveryOf,omitempty" example:"41" doc:"Delivery this one resends"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	UpdatedAt     time.Time       `json:"updatedAt"`
+	RequestBody   json.RawMessage `json:"requestBody" swaggertype:"object" doc:"Event payload sent with every attempt"`
+	Attempts      []Attempt       `json:"attempts" doc:"Oldest first"`
+}
+
+// DTO returns d as sent over the API.
+func (d Delivery) DTO() DeliveryDTO {
+	dto := DeliveryDTO{
+		ID:           d.ID,
+		WebhookID:    d.WebhookID,
+		Event:        d.Event,
+		Status:       d.Status,
+		RedeliveryOf: d.RedeliveryOf,
+		CreatedAt:    d.CreatedAt,
+		UpdatedAt:    d.UpdatedAt,
+		RequestBody:  json.RawMessage(d.Payload),
+		Attempts:     d.History,
+	}
+	if d.Status == StatusPending {
+		next := d.NextAttempt
+		dto.NextAttemptAt = &next
+	}
+	if dto.Attempts == nil {
+		dto.Attempts = []Attempt{}
+	}
+	return dto
+}
+
+// DeliveryFilter selects deliveries of one webhook, newest first. Zero
+// values mean "no filter".
+type DeliveryFilter struct {
+	Status   string
+	BeforeID int64 // keyset cursor: only deliveries with a smaller ID
+	Limit    int
+}
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+// Health summarizes how deliveries to a webhook have been going.
+type Health struct {
+	Status              string     `json:"status" example:"healthy" doc:"healthy, failing (the last attempt failed) or unknown (no attempts recorded)"`
+	Pending             int        `json:"pending" example:"0" doc:"Deliveries queued or waiting for a retry"`
+	Delivered24h        int        `json:"delivered24h" example:"12"`
+	Failed24h           int        `json:"failed24h" example:"0" doc:"Deliveries that ran out of retries in the last 24 hours"`
+	Attempts24h         int        `json:"attempts24h" example:"13"`
+	FailedAttempts24h   int        `json:"failedAttempts24h" example:"1"`
+	AvgDurationMs24h    float64    `json:"avgDurationMs24h" example:"84.5"`
+	ConsecutiveFailures int        `json:"consecutiveFailures" example:"0" doc:"Failed attempts since the last successful one"`
+	LastSuccessAt       *time.Time `json:"lastSuccessAt,omitempty"`
+	LastFailureAt       *time.Time `json:"lastFailureAt,omitempty"`
+	LastError           string     `json:"lastError,omitempty" example:"non-2xx status: 503 Service Unavailable"`
+}
+
+// Health states.
+const (
+	HealthHealthy = "healthy"
+	HealthFailing = "failing"
+	HealthUnknown = "unknown"
+)
@@ -1,16 +1,53 @@
 package webhook
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"firmware-registry-api/internal/db"
 )
 
-// Repository persists webhooks.
+// Repository persists webhooks and their delivery queue.
 type Repository interface {
-	List() ([]Webhook, error)
-	Create(h Webhook) (int64, error)
-	Update(id int64, h Webhook) error
-	Delete(id int64) error
+	List(ctx context.Context) ([]Webhook, error)
+	// Get returns one webhook or ErrNotFound.
+	Get(ctx context.Context, id int64) (Webhook, error)
+	Create(ctx context.Context, h Webhook) (int64, error)
+	Update(ctx context.Context, id int64, h Webhook) error
+	// Delete removes the webhook together with its deliveries and their
+	// attempts.
+	Delete(ctx context.Context, id int64) error
+
+	// Enqueue stores ds as pending deliveries.
+	Enqueue(ctx context.Context, ds []Delivery) error
+	// ClaimDue marks the longest-waiting pending delivery of an enabled
+	// webhook that is due at now as in progress and returns it. ok is false
+	// when nothing is due.
+	ClaimDue(ctx context.Context, now time.Time) (d Delivery, ok bool, err error)
+	// SaveAttempt stores the status, attempts, next attempt and last error
+	// of a claimed delivery, and a if it is not nil.
+	SaveAttempt(ctx context.Context, d Delivery, a *Attempt) error
+	// ResetInProgress returns deliveries claimed by a process that is gone
+	// to pending and reports how many there were.
+	ResetInProgress(ctx context.Context) (int64, error)
+	// PruneDeliveries deletes delivered and failed deliveries last updated
+	// before t, with their attempts.
+	PruneDeliveries(ctx context.Context, before time.Time) (int64, error)
+
+	// Deliveries returns a page of the deliveries of a webhook matching f,
+	// newest first and with their attempts, and the number of matches.
+	Deliveries(ctx context.Context, webhookID int64, f DeliveryFilter) ([]Delivery, int, error)
+	// Redeliver queues a copy of a delivery of webhookID as a new pending
+	// delivery. It returns ErrNotFound if there is no such delivery and
+	// ErrDisabled if the webhook is disabled.
+	Redeliver(ctx context.Context, webhookID, deliveryID int64, traceparent string, now time.Time) (Delivery, error)
+	// Health summarizes the deliveries of a webhook; counts cover the time
+	// since since.
+	Health(ctx context.Context, webhookID int64, since time.Time) (Health, error)
 }
 
 // SQLiteRepo implements Repository over SQLite.
@@ -18,8 +55,11 @@ type SQLiteRepo struct {
 	DB *sql.DB
 }
 
-func (r *SQLiteRepo) List() ([]Webhook, error) {
-	rows, err := r.DB.Query(`SELECT id, url, events, enabled FROM webhooks`)
+func (r *SQLiteRepo) List(ctx context.Context) ([]Webhook, error) {
+	ctx, done := db.Observe(ctx, "webhook.list")
+	defer done()
+
+	rows, err := r.DB.QueryContext(ctx, `SELECT id, url, events, enabled FROM webhooks`)
 	if err != nil {
 		return nil, err
 	}
@@ -40,9 +80,30 @@ func (r *SQLiteRepo) List() ([]Webhook, error) {
 	return out, nil
 }
 
-func (r *SQLiteRepo) Create(h Webhook) (int64, error) {
+func (r *SQLiteRepo) Get(ctx context.Context, id int64) (Webhook, error) {
+	ctx, done := db.Observe(ctx, "webhook.get")
+	defer done()
+
+	h := Webhook{ID: id}
+	var eventsJSON string
+	err := r.DB.QueryRowContext(ctx, `SELECT url, events, enabled FROM webhooks WHERE id=?`, id).
+		Scan(&h.URL, &eventsJSON, &h.Enabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return h, ErrNotFound
+	}
+	if err != nil {
+		return h, err
+	}
+	_ = json.Unmarshal([]byte(eventsJSON), &h.Events)
+	return h, nil
+}
+
+func (r *SQLiteRepo) Create(ctx context.Context, h Webhook) (int64, error) {
+	ctx, done := db.Observe(ctx, "webhook.create")
+	defer done()
+
 	ev, _ := json.Marshal(h.Events)
-	res, err := r.DB.Exec(
+	res, err := r.DB.ExecContext(ctx,
 		`INSERT INTO webhooks(url, events, enabled) VALUES(?,?,?)`,
 		h.URL, string(ev), h.Enabled,
 	)
@@ -52,16 +113,369 @@ func (r *SQLiteRepo) Create(h Webhook) (int64, error) {
 	return res.LastInsertId()
 }
 
-func (r *SQLiteRepo) Update(id int64, h Webhook) error {
+func (r *SQLiteRepo) Update(ctx context.Context, id int64, h Webhook) error {
+	ctx, done := db.Observe(ctx, "webhook.update")
+	defer done()
+
 	ev, _ := json.Marshal(h.Events)
-	_, err := r.DB.Exec(
+	_, err := r.DB.ExecContext(ctx,
 		`UPDATE webhooks SET url=?, events=?, enabled=? WHERE id=?`,
 		h.URL, string(ev), h.Enabled, id,
 	)
 	return err
 }
 
-func (r *SQLiteRepo) Delete(id int64) error {
-	_, err := r.DB.Exec(`DELETE FROM webhooks WHERE id=?`, id)
-	return err
+func (r *SQLiteRepo) Delete(ctx context.Context, id int64) error {
+	ctx, done := db.Observe(ctx, "webhook.delete")
+	defer done()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, table := range []string{"webhook_delivery_attempts", "webhook_deliveries"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE webhook_id=?`, id); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM webhooks WHERE id=?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *SQLiteRepo) Enqueue(ctx context.Context, ds []Delivery) error {
+	ctx, done := db.Observe(ctx, "webhook.enqueue")
+	defer done()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	stmt, err := tx.PrepareContext(ctx, `
+INSERT INTO webhook_deliveries(webhook_id, event, payload, traceparent, status, attempts, next_attempt_at, created_at, updated_at)
+VALUES(?,?,?,?,?,0,?,?,?)
+`)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stmt.Close() }()
+	for _, d := range ds {
+		created := formatTime(d.CreatedAt)
+		if _, err := stmt.ExecContext(ctx, d.WebhookID, d.Event, d.Payload, d.Traceparent,
+			StatusPending, formatTime(d.NextAttempt), created, created); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *SQLiteRepo) ClaimDue(ctx context.Context, now time.Time) (Delivery, bool, error) {
+	ctx, done := db.Observe(ctx, "webhook.claim_due")
+	defer done()
+
+	// A single statement, so two workers never claim the same row
+	row := r.DB.QueryRowContext(ctx, `
+UPDATE webhook_deliveries SET status=?, updated_at=?
+WHERE id = (
+    SELECT d.id FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+    WHERE d.status=? AND d.next_attempt_at <= ? AND w.enabled
+    ORDER BY d.next_attempt_at, d.id LIMIT 1
+)
+RETURNING `+deliveryColumns+`,
+    (SELECT url FROM webhooks WHERE webhooks.id = webhook_deliveries.webhook_id)
+`, StatusInProgress, formatTime(now), StatusPending, formatTime(now))
+
+	var d Delivery
+	err := scanDelivery(row, &d, &d.URL)
+	if err == sql.ErrNoRows {
+		return Delivery{}, false, nil
+	}
+	if err != nil {
+		return Delivery{}, false, err
+	}
+	return d, true, nil
+}
+
+const deliveryColumns = `id, webhook_id, event, payload, traceparent, status, attempts, next_attempt_at,
+    last_error, COALESCE(redelivery_of, 0), created_at, updated_at`
+
+// scanDelivery scans deliveryColumns, followed by extra, into d.
+func scanDelivery(row interface{ Scan(...any) error }, d *Delivery, extra ...any) error {
+	var next, created, updated string
+	dest := append([]any{&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Traceparent, &d.Status, &d.Attempts,
+		&next, &d.LastError, &d.RedeliveryOf, &created, &updated}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+	d.NextAttempt, _ = time.Parse(time.RFC3339, next)
+	d.CreatedAt, _ = time.Parse(time.RFC3339, created)
+	d.UpdatedAt, _ = time.Parse(time.RFC3339, updated)
+	return nil
+}
+
+func (r *SQLiteRepo) SaveAttempt(ctx context.Context, d Delivery, a *Attempt) error {
+	ctx, done := db.Observe(ctx, "webhook.save_attempt")
+	defer done()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx, `
+UPDATE webhook_deliveries SET status=?, attempts=?, next_attempt_at=?, last_error=?, updated_at=?
+WHERE id=?
+`, d.Status, d.Attempts, formatTime(d.NextAttempt), d.LastError, formatTime(d.UpdatedAt), d.ID); err != nil {
+		return err
+	}
+	if a != nil {
+		// The webhook may have been deleted, taking its deliveries along,
+		// while the attempt ran; its record would be left orphaned
+		headers, _ := json.Marshal(a.RequestHeaders)
+		if _, err := tx.ExecContext(ctx, `
+INSERT INTO webhook_delivery_attempts(delivery_id, webhook_id, attempt, url, request_headers,
+  response_status, response_body, duration_ms, error, created_at)
+SELECT ?,?,?,?,?,?,?,?,?,?
+WHERE EXISTS (SELECT 1 FROM webhook_deliveries WHERE id=? AND webhook_id=?)
+`, d.ID, d.WebhookID, a.Attempt, a.URL, string(headers), a.ResponseStatus, a.ResponseBody,
+			a.DurationMs, a.Error, formatTime(a.Time), d.ID, d.WebhookID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *SQLiteRepo) ResetInProgress(ctx context.Context) (int64, error) {
+	ctx, done := db.Observe(ctx, "webhook.reset_in_progress")
+	defer done()
+
+	res, err := r.DB.ExecContext(ctx, `UPDATE webhook_deliveries SET status=? WHERE status=?`,
+		StatusPending, StatusInProgress)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *SQLiteRepo) PruneDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	ctx, done := db.Observe(ctx, "webhook.prune_deliveries")
+	defer done()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	const finished = `status IN (?,?) AND updated_at < ?`
+	args := []any{StatusDelivered, StatusFailed, formatTime(before)}
+	if _, err := tx.ExecContext(ctx, `
+DELETE FROM webhook_delivery_attempts
+WHERE delivery_id IN (SELECT id FROM webhook_deliveries WHERE `+finished+`)
+`, args...); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE `+finished, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+func (r *SQLiteRepo) Deliveries(ctx context.Context, webhookID int64, f DeliveryFilter) ([]Delivery, int, error) {
+	ctx, done := db.Observe(ctx, "webhook.deliveries")
+	defer done()
+
+	where := []string{"webhook_id = ?"}
+	args := []any{webhookID}
+	if f.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, f.Status)
+	}
+
+	var total int
+	if err := r.DB.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM webhook_deliveries WHERE `+strings.Join(where, " AND "), args...,
+	).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	if f.BeforeID > 0 {
+		where = append(where, "id < ?")
+		args = append(args, f.BeforeID)
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	rows, err := r.DB.QueryContext(ctx, `SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE `+
+		strings.Join(where, " AND ")+` ORDER BY id DESC LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	out := []Delivery{}
+	index := map[int64]int{}
+	for rows.Next() {
+		var d Delivery
+		if err := scanDelivery(rows, &d); err != nil {
+			return nil, 0, err
+		}
+		index[d.ID] = len(out)
+		out = append(out, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if len(out) == 0 {
+		return out, total, nil
+	}
+
+	// Attempts of the whole page in one query
+	rows, err = r.DB.QueryContext(ctx, `
+SELECT id, delivery_id, webhook_id, attempt, url, request_headers, response_status, response_body,
+  duration_ms, error, created_at
+FROM webhook_delivery_attempts
+WHERE webhook_id = ? AND delivery_id BETWEEN ? AND ?
+ORDER BY id
+`, webhookID, out[len(out)-1].ID, out[0].ID)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	for rows.Next() {
+		var a Attempt
+		var headers, created string
+		if err := rows.Scan(&a.ID, &a.DeliveryID, &a.WebhookID, &a.Attempt, &a.URL, &headers,
+			&a.ResponseStatus, &a.ResponseBody, &a.DurationMs, &a.Error, &created); err != nil {
+			return nil, 0, err
+		}
+		i, ok := index[a.DeliveryID]
+		if !ok {
+			continue // filtered out by status
+		}
+		_ = json.Unmarshal([]byte(headers), &a.RequestHeaders)
+		a.Time, _ = time.Parse(time.RFC3339, created)
+		out[i].History = append(out[i].History, a)
+	}
+	return out, total, rows.Err()
+}
+
+func (r *SQLiteRepo) Redeliver(ctx context.Context, webhookID, deliveryID int64, traceparent string, now time.Time) (Delivery, error) {
+	ctx, done := db.Observe(ctx, "webhook.redeliver")
+	defer done()
+
+	var enabled bool
+	err := r.DB.QueryRowContext(ctx, `SELECT enabled FROM webhooks WHERE id=?`, webhookID).Scan(&enabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Delivery{}, ErrNotFound
+	}
+	if err != nil {
+		return Delivery{}, err
+	}
+	if !enabled {
+		return Delivery{}, ErrDisabled
+	}
+
+	var d Delivery
+	err = scanDelivery(r.DB.QueryRowContext(ctx, `
+INSERT INTO webhook_deliveries(webhook_id, event, payload, traceparent, status, attempts, next_attempt_at,
+  created_at, updated_at, redelivery_of)
+SELECT webhook_id, event, payload, ?, ?, 0, ?, ?, ?, id FROM webhook_deliveries WHERE id=? AND webhook_id=?
+RETURNING `+deliveryColumns+`
+`, traceparent, StatusPending, formatTime(now), formatTime(now), formatTime(now), deliveryID, webhookID), &d)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Delivery{}, ErrNotFound
+	}
+	return d, err
+}
+
+func (r *SQLiteRepo) Health(ctx context.Context, webhookID int64, since time.Time) (Health, error) {
+	ctx, done := db.Observe(ctx, "webhook.health")
+	defer done()
+
+	var h Health
+	if err := r.DB.QueryRowContext(ctx, `
+SELECT
+  COALESCE(SUM(status IN (?,?)), 0),
+  COALESCE(SUM(status = ? AND updated_at >= ?), 0),
+  COALESCE(SUM(status = ? AND updated_at >= ?), 0)
+FROM webhook_deliveries WHERE webhook_id = ?
+`, StatusPending, StatusInProgress, StatusDelivered, formatTime(since), StatusFailed, formatTime(since), webhookID,
+	).Scan(&h.Pending, &h.Delivered24h, &h.Failed24h); err != nil {
+		return h, err
+	}
+
+	if err := r.DB.QueryRowContext(ctx, `
+SELECT COUNT(*), COALESCE(SUM(error != ''), 0), COALESCE(AVG(duration_ms), 0)
+FROM webhook_delivery_attempts WHERE webhook_id = ? AND created_at >= ?
+`, webhookID, formatTime(since)).Scan(&h.Attempts24h, &h.FailedAttempts24h, &h.AvgDurationMs24h); err != nil {
+		return h, err
+	}
+
+	var lastOK sql.NullInt64
+	var lastSuccess sql.NullString
+	if err := r.DB.QueryRowContext(ctx, `
+SELECT MAX(id), MAX(created_at) FROM webhook_delivery_attempts WHERE webhook_id = ? AND error = ''
+`, webhookID).Scan(&lastOK, &lastSuccess); err != nil {
+		return h, err
+	}
+	h.LastSuccessAt = parseNullTime(lastSuccess)
+
+	var lastFailure sql.NullString
+	err := r.DB.QueryRowContext(ctx, `
+SELECT created_at, error FROM webhook_delivery_attempts WHERE webhook_id = ? AND error != ''
+ORDER BY id DESC LIMIT 1
+`, webhookID).Scan(&lastFailure, &h.LastError)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return h, err
+	}
+	h.LastFailureAt = parseNullTime(lastFailure)
+
+	if err := r.DB.QueryRowContext(ctx, `
+SELECT COUNT(*) FROM webhook_delivery_attempts WHERE webhook_id = ? AND error != '' AND id > ?
+`, webhookID, lastOK.Int64).Scan(&h.ConsecutiveFailures); err != nil {
+		return h, err
+	}
+
+	switch {
+	case h.ConsecutiveFailures > 0:
+		h.Status = HealthFailing
+	case h.LastSuccessAt != nil:
+		h.Status = HealthHealthy
+	default:
+		h.Status = HealthUnknown
+	}
+	return h, nil
+}
+
+func parseNullTime(s sql.NullString) *time.Time {
+	if !s.Valid {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, s.String)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
 }
@@ -2,28 +2,134 @@ package webhook
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
+	"firmware-registry-api/internal/metrics"
+	"firmware-registry-api/internal/tracing"
+
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
-// Service dispatches webhook events to subscribed URLs.
-type Service struct {
-	Repo       Repository
+// Settings control how events are delivered; they can be changed at runtime
+// with Service.Update.
+type Settings struct {
 	Secret     string
 	TimeoutSec int
 	Retries    int
 }
 
-func (s *Service) Dispatch(event string, data any) {
-	hooks, err := s.Repo.List()
+const (
+	// pollInterval is how often idle workers look for retries that became
+	// due; new events wake them at once.
+	pollInterval = time.Second
+	// maxBackoff caps the wait between attempts, which doubles from one
+	// second after every failure.
+	maxBackoff = time.Hour
+	// pruneInterval is how often finished deliveries past Retention are
+	// deleted.
+	pruneInterval = time.Hour
+	// maxResponseBody is how much of each response is recorded.
+	maxResponseBody = 4 << 10
+)
+
+// Service queues webhook events in the database and delivers them to
+// subscribed URLs from a fixed pool of workers. Retry state is stored with
+// every attempt, so deliveries still pending at shutdown or after a crash
+// resume on the next Start.
+type Service struct {
+	Repo     Repository
+	Settings Settings // initial settings; guarded by mu afterwards
+	// Workers caps the number of concurrent outgoing requests; default 4.
+	Workers int
+	// Retention is how long delivered and failed deliveries are kept; 0
+	// keeps them forever.
+	Retention time.Duration
+
+	mu        sync.Mutex
+	startOnce sync.Once
+	closeOnce sync.Once
+	running   sync.WaitGroup
+	wake      chan struct{}
+	quit      chan struct{}
+	// abort cancels attempts still running when Close gives up.
+	initOnce sync.Once
+	abortCtx context.Context
+	abort    context.CancelFunc
+}
+
+// Update replaces the delivery settings. They apply from the next attempt;
+// attempts already running keep the settings they started with.
+func (s *Service) Update(settings Settings) {
+	s.mu.Lock()
+	s.Settings = settings
+	s.mu.Unlock()
+}
+
+func (s *Service) settings() Settings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Settings
+}
+
+func (s *Service) init() {
+	s.initOnce.Do(func() {
+		if s.Workers <= 0 {
+			s.Workers = 4
+		}
+		s.wake = make(chan struct{}, s.Workers)
+		s.quit = make(chan struct{})
+		s.abortCtx, s.abort = context.WithCancel(context.Background())
+	})
+}
+
+// Start returns deliveries left in progress by a previous run to the queue
+// and starts the workers. Events dispatched before Start are queued and
+// delivered once it runs.
+func (s *Service) Start() {
+	s.init()
+	s.startOnce.Do(func() {
+		n, err := s.Repo.ResetInProgress(context.Background())
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to requeue interrupted webhook deliveries")
+		} else if n > 0 {
+			log.Info().Int64("deliveries", n).Msg("Requeued webhook deliveries interrupted by the last shutdown")
+		}
+
+		s.running.Add(s.Workers)
+		for i := 0; i < s.Workers; i++ {
+			go s.work()
+		}
+		if s.Retention > 0 {
+			s.running.Add(1)
+			go s.prune()
+		}
+		log.Info().
+			Int("workers", s.Workers).
+			Dur("retention", s.Retention).
+			Msg("Webhook delivery workers started")
+	})
+}
+
+// Dispatch queues event for every enabled webhook subscribed to it. The
+// deliveries continue the trace in ctx but run in the background.
+func (s *Service) Dispatch(ctx context.Context, event string, data any) {
+	s.init()
+	hooks, err := s.Repo.List(ctx)
 	if err != nil {
-		log.Error().
+		zerolog.Ctx(ctx).Error().
 			Err(err).
 			Str("event", event).
 			Msg("Failed to list webhooks for event dispatch")
@@ -38,108 +144,284 @@ func (s *Service) Dispatch(event string, data any) {
 
 	body, err := json.Marshal(payload)
 	if err != nil {
-		log.Error().
+		zerolog.Ctx(ctx).Error().
 			Err(err).
 			Str("event", event).
 			Msg("Failed to marshal webhook payload")
 		return
 	}
 
-	dispatchCount := 0
+	now := time.Now().UTC()
+	traceparent := tracing.Traceparent(ctx)
+	var deliveries []Delivery
 	for _, h := range hooks {
 		if !h.Enabled || !contains(h.Events, event) {
 			continue
 		}
-		dispatchCount++
-		go s.deliver(h.URL, body, event)
+		deliveries = append(deliveries, Delivery{
+			WebhookID:   h.ID,
+			Event:       event,
+			Payload:     body,
+			Traceparent: traceparent,
+			NextAttempt: now,
+			CreatedAt:   now,
+		})
 	}
 
-	if dispatchCount > 0 {
-		log.Info().
-			Str("event", event).
-			Int("webhook_count", dispatchCount).
-			Msg("Dispatching webhook event")
-	} else {
-		log.Debug().
+	if len(deliveries) == 0 {
+		zerolog.Ctx(ctx).Debug().
 			Str("event", event).
 			Msg("No webhooks configured for event")
+		return
 	}
+	if err := s.Repo.Enqueue(ctx, deliveries); err != nil {
+		zerolog.Ctx(ctx).Error().
+			Err(err).
+			Str("event", event).
+			Int("webhook_count", len(deliveries)).
+			Msg("Failed to queue webhook deliveries, dropping event")
+		return
+	}
+	zerolog.Ctx(ctx).Info().
+		Str("event", event).
+		Int("webhook_count", len(deliveries)).
+		Msg("Dispatching webhook event")
+
+	s.notify(len(deliveries))
 }
 
-func (s *Service) deliver(url string, body []byte, event string) {
-	timeout := time.Duration(s.TimeoutSec) * time.Second
-	retries := s.Retries
-	if retries < 0 {
-		retries = 0
+// Redeliver queues the payload of a delivery of webhookID again as a new
+// delivery, with a fresh set of retries. See Repository.Redeliver for the
+// errors.
+func (s *Service) Redeliver(ctx context.Context, webhookID, deliveryID int64) (Delivery, error) {
+	s.init()
+	d, err := s.Repo.Redeliver(ctx, webhookID, deliveryID, tracing.Traceparent(ctx), time.Now().UTC())
+	if err != nil {
+		return d, err
 	}
+	zerolog.Ctx(ctx).Info().
+		Int64("webhook_id", webhookID).
+		Int64("delivery_id", d.ID).
+		Int64("redelivery_of", deliveryID).
+		Msg("Queued webhook redelivery")
+	s.notify(1)
+	return d, nil
+}
 
-	log.Debug().
-		Str("url", url).
-		Str("event", event).
-		Int("max_retries", retries).
-		Msg("Starting webhook delivery")
-
-	for attempt := 0; attempt <= retries; attempt++ {
-		req, _ := http.NewRequest("POST", url, bytes.NewReader(body))
-		req.Header.Set("Content-Type", "application/json")
-		if s.Secret != "" {
-			req.Header.Set("X-Webhook-Signature", hmacHex([]byte(s.Secret), body))
+// notify wakes up to n idle workers.
+func (s *Service) notify(n int) {
+	for i := 0; i < n; i++ {
+		select {
+		case s.wake <- struct{}{}:
+		default: // every worker has been woken already
+			return
 		}
+	}
+}
 
-		client := &http.Client{Timeout: timeout}
-		resp, err := client.Do(req)
-
-		if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
-			log.Info().
-				Str("url", url).
-				Str("event", event).
-				Int("status", resp.StatusCode).
-				Int("attempt", attempt+1).
-				Msg("Webhook delivered successfully")
-			_ = resp.Body.Close()
+// work delivers due deliveries one at a time. After Close it keeps going
+// until nothing is due or Close gives up, so the queue is flushed on
+// shutdown.
+func (s *Service) work() {
+	defer s.running.Done()
+	for s.abortCtx.Err() == nil {
+		d, ok, err := s.Repo.ClaimDue(context.Background(), time.Now().UTC())
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to claim webhook delivery")
+		}
+		if ok {
+			s.attempt(d)
+			continue
+		}
+
+		select {
+		case <-s.quit:
+			return
+		default:
+		}
+		timer := time.NewTimer(pollInterval)
+		select {
+		case <-s.quit:
+			timer.Stop()
 			return
+		case <-s.wake:
+		case <-timer.C:
 		}
+		timer.Stop()
+	}
+}
 
-		// Log the failure
-		if err != nil {
-			log.Warn().
-				Err(err).
-				Str("url", url).
-				Str("event", event).
-				Int("attempt", attempt+1).
-				Int("max_attempts", retries+1).
-				Msg("Webhook delivery failed with error")
-		} else {
-			log.Warn().
-				Str("url", url).
-				Str("event", event).
-				Int("status", resp.StatusCode).
-				Int("attempt", attempt+1).
-				Int("max_attempts", retries+1).
-				Msg("Webhook delivery failed with non-2xx status")
-			_ = resp.Body.Close()
+// attempt makes one delivery attempt of the claimed d and stores its
+// outcome: delivered, failed for good, or pending until the backoff is over.
+func (s *Service) attempt(d Delivery) {
+	settings := s.settings()
+	retries := max(settings.Retries, 0)
+	logger := log.With().
+		Int64("delivery_id", d.ID).
+		Int64("webhook_id", d.WebhookID).
+		Str("url", d.URL).
+		Str("event", d.Event).
+		Int("attempt", d.Attempts+1).
+		Int("max_attempts", retries+1).
+		Logger()
+	ctx := logger.WithContext(tracing.Resume(s.abortCtx, d.Traceparent))
+
+	if d.Attempts > 0 {
+		metrics.WebhookRetried(d.Event)
+	}
+	rec, err := s.post(ctx, d, settings)
+	status := rec.ResponseStatus
+
+	if err != nil && s.abortCtx.Err() != nil {
+		// Cut off by shutdown; the attempt does not count
+		d.Status = StatusPending
+		d.UpdatedAt = time.Now().UTC()
+		if err := s.Repo.SaveAttempt(context.Background(), d, nil); err != nil {
+			logger.Error().Err(err).Msg("Failed to requeue interrupted webhook delivery")
 		}
+		logger.Warn().Msg("Webhook delivery interrupted at shutdown, resuming after restart")
+		return
+	}
 
-		if resp != nil {
-			_ = resp.Body.Close()
+	now := time.Now().UTC()
+	d.Attempts++
+	d.UpdatedAt = now
+	d.LastError = ""
+	switch {
+	case err == nil:
+		d.Status = StatusDelivered
+		metrics.WebhookDelivered(d.Event, true)
+		logger.Info().
+			Int("status", status).
+			Msg("Webhook delivered successfully")
+	case d.Attempts > retries:
+		d.Status = StatusFailed
+		d.LastError = err.Error()
+		metrics.WebhookDelivered(d.Event, false)
+		logger.Error().
+			Err(err).
+			Int("status", status).
+			Msg("Webhook delivery failed after all retries")
+	default:
+		d.Status = StatusPending
+		d.LastError = err.Error()
+		d.NextAttempt = now.Add(backoff(d.Attempts))
+		logger.Warn().
+			Err(err).
+			Int("status", status).
+			Time("next_attempt_at", d.NextAttempt).
+			Msg("Webhook delivery failed, will retry")
+	}
+	if err := s.Repo.SaveAttempt(context.Background(), d, &rec); err != nil {
+		logger.Error().Err(err).Msg("Failed to save webhook delivery attempt")
+	}
+}
+
+// post sends d once and returns the record of the attempt. The error, also
+// stored in the record, is set unless the receiver answered 2xx.
+func (s *Service) post(ctx context.Context, d Delivery, settings Settings) (a Attempt, err error) {
+	a = Attempt{Attempt: d.Attempts + 1, URL: d.URL, Time: time.Now().UTC()}
+	start := time.Now()
+	defer func() {
+		a.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+		if err != nil {
+			a.Error = err.Error()
 		}
+	}()
 
-		// Wait before retry (exponential backoff)
-		if attempt < retries {
-			backoff := time.Duration(attempt+1) * 500 * time.Millisecond
-			log.Debug().
-				Str("url", url).
-				Dur("backoff_ms", backoff).
-				Msg("Waiting before webhook retry")
-			time.Sleep(backoff)
+	req, err := http.NewRequest(http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return a, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if settings.Secret != "" {
+		req.Header.Set("X-Webhook-Signature", hmacHex([]byte(settings.Secret), d.Payload))
+	}
+
+	ctx, span := tracing.StartClient(ctx, "webhook.deliver", req.Header,
+		attribute.String("webhook.event", d.Event),
+		attribute.String("url.full", d.URL),
+		attribute.Int64("webhook.delivery_id", d.ID),
+		attribute.Int("webhook.attempt", a.Attempt),
+	)
+	defer span.End()
+	a.RequestHeaders = make(map[string]string, len(req.Header))
+	for k := range req.Header {
+		a.RequestHeaders[k] = req.Header.Get(k)
+	}
+
+	client := &http.Client{Timeout: time.Duration(settings.TimeoutSec) * time.Second}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		tracing.Fail(span, err)
+		return a, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	a.ResponseStatus = resp.StatusCode
+	a.ResponseBody = strings.ToValidUTF8(string(body), "\uFFFD")
+
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		span.SetStatus(codes.Error, resp.Status)
+		return a, fmt.Errorf("non-2xx status: %s", resp.Status)
+	}
+	return a, nil
+}
+
+// backoff returns the wait after the n-th failed attempt: 1s, 2s, 4s, ...
+// up to maxBackoff.
+func backoff(n int) time.Duration {
+	if n > 12 {
+		return maxBackoff
+	}
+	return min(time.Second<<(n-1), maxBackoff)
+}
+
+// prune deletes finished deliveries older than Retention until Close.
+func (s *Service) prune() {
+	defer s.running.Done()
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		n, err := s.Repo.PruneDeliveries(context.Background(), time.Now().Add(-s.Retention))
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to prune webhook deliveries")
+		} else if n > 0 {
+			log.Debug().Int64("deliveries", n).Msg("Pruned finished webhook deliveries")
+		}
+		select {
+		case <-s.quit:
+			return
+		case <-ticker.C:
 		}
 	}
+}
 
-	log.Error().
-		Str("url", url).
-		Str("event", event).
-		Int("attempts", retries+1).
-		Msg("Webhook delivery failed after all retries")
+// Close lets the workers send every delivery that is already due and then
+// stops them, waiting until ctx expires. Attempts still running then are
+// cancelled, their deliveries requeued and ctx's error is returned.
+// Deliveries not sent by then, including retries whose backoff has not
+// elapsed, stay queued for the next Start.
+func (s *Service) Close(ctx context.Context) error {
+	s.init()
+	s.closeOnce.Do(func() { close(s.quit) })
+
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.abort()
+		<-done
+		return ctx.Err()
+	}
 }
 
 func hmacHex(secret, data []byte) string {
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_firmware_labels_label;
+DROP INDEX IF EXISTS idx_firmwares_type_channel_semver;
+DROP INDEX IF EXISTS idx_firmwares_type_created;
+DROP INDEX IF EXISTS idx_firmwares_type_semver;
+
+DROP TABLE IF EXISTS firmware_labels;
+
+ALTER TABLE firmwares DROP COLUMN channel;
+ALTER TABLE firmwares DROP COLUMN ver_patch;
+ALTER TABLE firmwares DROP COLUMN ver_minor;
+ALTER TABLE firmwares DROP COLUMN ver_major;
@@ -0,0 +1,23 @@
+-- Numeric semver sort keys, filled in by the application on upsert.
+-- NULL means "not computed yet"; -1 marks a non-semver version.
+ALTER TABLE firmwares ADD COLUMN ver_major INTEGER;
+ALTER TABLE firmwares ADD COLUMN ver_minor INTEGER;
+ALTER TABLE firmwares ADD COLUMN ver_patch INTEGER;
+
+ALTER TABLE firmwares ADD COLUMN channel TEXT NOT NULL DEFAULT 'stable';
+
+CREATE TABLE IF NOT EXISTS firmware_labels (
+    type TEXT NOT NULL,
+    version TEXT NOT NULL,
+    label TEXT NOT NULL,
+    PRIMARY KEY (type, version, label)
+);
+
+CREATE INDEX IF NOT EXISTS idx_firmwares_type_semver
+    ON firmwares (type, ver_major, ver_minor, ver_patch, version);
+CREATE INDEX IF NOT EXISTS idx_firmwares_type_created
+    ON firmwares (type, created_at, version);
+CREATE INDEX IF NOT EXISTS idx_firmwares_type_channel_semver
+    ON firmwares (type, channel, ver_major, ver_minor, ver_patch, version);
+CREATE INDEX IF NOT EXISTS idx_firmware_labels_label
+    ON firmware_labels (label, type, version);
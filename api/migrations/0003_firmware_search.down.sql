@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS firmware_search;
+DROP INDEX IF EXISTS idx_firmwares_sha256;
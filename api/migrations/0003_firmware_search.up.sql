@@ -0,0 +1,16 @@
+CREATE INDEX IF NOT EXISTS idx_firmwares_sha256 ON firmwares (sha256);
+
+-- Full-text index for global search, maintained by the application in the
+-- same transaction as firmwares/firmware_labels. FTS4 ships with the default
+-- go-sqlite3 build; prefix indexes make "term*" queries cheap.
+CREATE VIRTUAL TABLE IF NOT EXISTS firmware_search USING fts4(
+    type, version, filename, channel, labels, sha256,
+    tokenize=unicode61, prefix="2,4"
+);
+
+INSERT INTO firmware_search(type, version, filename, channel, labels, sha256)
+SELECT f.type, f.version, f.filename, f.channel,
+       COALESCE((SELECT group_concat(l.label, ' ') FROM firmware_labels l
+                 WHERE l.type = f.type AND l.version = f.version), ''),
+       f.sha256
+FROM firmwares f;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_download_events_type_version_created;
+DROP INDEX IF EXISTS idx_download_events_type_created;
+DROP TABLE IF EXISTS download_events;
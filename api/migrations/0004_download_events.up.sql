@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS download_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    type TEXT NOT NULL,
+    version TEXT NOT NULL,
+    device_id TEXT NOT NULL DEFAULT '',
+    client_ip TEXT NOT NULL DEFAULT '',
+    bytes INTEGER NOT NULL,
+    completed BOOLEAN NOT NULL,
+    created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_download_events_type_created
+    ON download_events (type, created_at);
+CREATE INDEX IF NOT EXISTS idx_download_events_type_version_created
+    ON download_events (type, version, created_at);
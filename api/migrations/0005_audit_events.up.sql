@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at TEXT NOT NULL,
+    actor TEXT NOT NULL DEFAULT '',
+    actor_type TEXT NOT NULL DEFAULT '',
+    actor_email TEXT NOT NULL DEFAULT '',
+    role TEXT NOT NULL DEFAULT '',
+    action TEXT NOT NULL,
+    target_type TEXT NOT NULL DEFAULT '',
+    target_version TEXT NOT NULL DEFAULT '',
+    webhook_id INTEGER,
+    outcome TEXT NOT NULL,
+    client_ip TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT '',
+    detail TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_created ON audit_events (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events (actor, id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events (action, id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events (target_type, target_version, id);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    secret_hash TEXT NOT NULL UNIQUE,
+    scopes TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    created_by TEXT NOT NULL DEFAULT '',
+    expires_at TEXT,
+    last_used_at TEXT,
+    revoked_at TEXT
+);
+
+-- Names identify keys in logs and the audit trail, so they are unique among
+-- keys that can still be used. A revoked key's name may be reused.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_active_name ON api_keys (name) WHERE revoked_at IS NULL;
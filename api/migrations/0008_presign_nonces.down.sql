@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_presign_nonces_expires_at;
+DROP TABLE IF EXISTS presign_nonces;
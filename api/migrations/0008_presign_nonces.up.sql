@@ -0,0 +1,7 @@
+-- Nonces of single-use pre-signed download URLs, kept until the URL expires.
+CREATE TABLE IF NOT EXISTS presign_nonces (
+    nonce TEXT PRIMARY KEY,
+    expires_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_presign_nonces_expires_at ON presign_nonces (expires_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_webhook_deliveries_webhook;
+DROP INDEX IF EXISTS idx_webhook_deliveries_due;
+DROP TABLE IF EXISTS webhook_deliveries;
@@ -0,0 +1,4 @@
+ALTER TABLE webhook_deliveries DROP COLUMN redelivery_of;
+DROP INDEX IF EXISTS idx_webhook_delivery_attempts_webhook;
+DROP INDEX IF EXISTS idx_webhook_delivery_attempts_delivery;
+DROP TABLE IF EXISTS webhook_delivery_attempts;
@@ -0,0 +1,21 @@
+-- One row per HTTP request made for a webhook delivery; pruned together with
+-- the delivery.
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    delivery_id INTEGER NOT NULL,
+    webhook_id INTEGER NOT NULL,
+    attempt INTEGER NOT NULL,
+    url TEXT NOT NULL,
+    request_headers TEXT NOT NULL,
+    response_status INTEGER NOT NULL DEFAULT 0,
+    response_body TEXT NOT NULL DEFAULT '',
+    duration_ms REAL NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_attempts_delivery ON webhook_delivery_attempts (delivery_id, id);
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_attempts_webhook ON webhook_delivery_attempts (webhook_id, id);
+
+-- Set on deliveries queued by a manual redelivery.
+ALTER TABLE webhook_deliveries ADD COLUMN redelivery_of INTEGER;
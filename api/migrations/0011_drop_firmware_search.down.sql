@@ -0,0 +1,12 @@
+-- Recreates the FTS4 index of 0003_firmware_search from the current rows.
+CREATE VIRTUAL TABLE IF NOT EXISTS firmware_search USING fts4(
+    type, version, filename, channel, labels, sha256,
+    tokenize=unicode61, prefix="2,4"
+);
+
+INSERT INTO firmware_search(type, version, filename, channel, labels, sha256)
+SELECT f.type, f.version, f.filename, f.channel,
+       COALESCE((SELECT group_concat(l.label, ' ') FROM firmware_labels l
+                 WHERE l.type = f.type AND l.version = f.version), ''),
+       f.sha256
+FROM firmwares f;
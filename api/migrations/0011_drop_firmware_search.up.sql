@@ -0,0 +1,3 @@
+-- Search matches substrings, which the FTS4 token index cannot answer, so it
+-- scans firmwares and firmware_labels directly and the index is dropped.
+DROP TABLE IF EXISTS firmware_search;